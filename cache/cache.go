@@ -0,0 +1,51 @@
+// Package cache byte value caches behind one interface, an in-process LRU
+// with per entry expiry and a no-op cache when caching is disabled
+package cache
+
+import (
+	"context"
+)
+
+// Cache values by key. Values are opaque bytes so a networked cache such
+// as redis fits behind it; such a backend reports its failures as misses,
+// the caller then falls back to the store.
+type Cache interface {
+	// Get value of key, false when missing or expired
+	Get(ctx context.Context, key string) ([]byte, bool)
+	// Set value of key, replacing any previous value
+	Set(ctx context.Context, key string, value []byte)
+	// Delete keys, missing keys are ignored
+	Delete(ctx context.Context, keys ...string)
+	// Stats counters since the cache was created
+	Stats() Stats
+}
+
+// Stats cache counters
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+// Nop cache that stores nothing, every Get misses
+type Nop struct {
+	misses counter
+}
+
+// Get always a miss
+func (n *Nop) Get(ctx context.Context, key string) ([]byte, bool) {
+	n.misses.add()
+	return nil, false
+}
+
+// Set discard value
+func (n *Nop) Set(ctx context.Context, key string, value []byte) {}
+
+// Delete nothing to delete
+func (n *Nop) Delete(ctx context.Context, keys ...string) {}
+
+// Stats only misses
+func (n *Nop) Stats() Stats {
+	return Stats{Misses: n.misses.load()}
+}
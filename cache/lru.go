@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counter uint64 counter safe for concurrent use
+type counter struct {
+	n uint64
+}
+
+func (c *counter) add() {
+	atomic.AddUint64(&c.n, 1)
+}
+
+func (c *counter) load() uint64 {
+	return atomic.LoadUint64(&c.n)
+}
+
+// entry element value of the recency list
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU in-process cache holding at most size entries, each expiring ttl
+// after it was set. The least recently used entry is evicted first.
+type LRU struct {
+	size int
+	ttl  time.Duration
+
+	mu sync.Mutex
+	// recency most recently used at the front
+	recency *list.List
+	entries map[string]*list.Element
+
+	hits      counter
+	misses    counter
+	evictions counter
+}
+
+// NewLRU create LRU cache of size entries expiring after ttl
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		size:    size,
+		ttl:     ttl,
+		recency: list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get value of key, an expired entry is dropped and misses
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.add()
+		return nil, false
+	}
+	e := element.Value.(*entry)
+	if !time.Now().Before(e.expiresAt) {
+		c.remove(element)
+		c.misses.add()
+		return nil, false
+	}
+	c.recency.MoveToFront(element)
+	c.hits.add()
+	return e.value, true
+}
+
+// Set value of key, evicting the least recently used entry when full
+func (c *LRU) Set(ctx context.Context, key string, value []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := time.Now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.value, e.expiresAt = value, expiresAt
+		c.recency.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.recency.PushFront(&entry{key: key, value: value, expiresAt: expiresAt})
+	for c.recency.Len() > c.size {
+		c.remove(c.recency.Back())
+		c.evictions.add()
+	}
+}
+
+// Delete keys
+func (c *LRU) Delete(ctx context.Context, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+		}
+	}
+}
+
+// Stats counters and current number of entries, expired entries count
+// until they are touched or evicted
+func (c *LRU) Stats() Stats {
+	c.mu.Lock()
+	entries := c.recency.Len()
+	c.mu.Unlock()
+	return Stats{
+		Hits:      c.hits.load(),
+		Misses:    c.misses.load(),
+		Evictions: c.evictions.load(),
+		Entries:   entries,
+	}
+}
+
+// remove element, caller holds the lock
+func (c *LRU) remove(element *list.Element) {
+	c.recency.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
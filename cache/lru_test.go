@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2, time.Minute)
+	c.Set(ctx, "a", []byte("1"))
+	c.Set(ctx, "b", []byte("2"))
+	// reading a makes b the least recently used
+	if v, ok := c.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("a: %q %v", v, ok)
+	}
+	c.Set(ctx, "c", []byte("3"))
+	if _, ok := c.Get(ctx, "b"); ok {
+		t.Fatal("b survived the eviction")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(ctx, key); !ok {
+			t.Fatalf("%s evicted", key)
+		}
+	}
+
+	// replacing a value refreshes it without evicting
+	c.Set(ctx, "a", []byte("4"))
+	c.Set(ctx, "d", []byte("5"))
+	if v, ok := c.Get(ctx, "a"); !ok || string(v) != "4" {
+		t.Fatalf("a after replacing: %q %v", v, ok)
+	}
+	if _, ok := c.Get(ctx, "c"); ok {
+		t.Fatal("c survived the eviction")
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10, 20*time.Millisecond)
+	c.Set(ctx, "a", []byte("1"))
+	if _, ok := c.Get(ctx, "a"); !ok {
+		t.Fatal("a missing before it expired")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get(ctx, "a"); ok {
+		t.Fatal("a served after it expired")
+	}
+	if entries := c.Stats().Entries; entries != 0 {
+		t.Fatalf("%d entries after the expired one was read", entries)
+	}
+	// setting again starts a new ttl
+	c.Set(ctx, "a", []byte("2"))
+	if v, ok := c.Get(ctx, "a"); !ok || string(v) != "2" {
+		t.Fatalf("a set again: %q %v", v, ok)
+	}
+}
+
+func TestLRUStats(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2, time.Minute)
+	c.Set(ctx, "a", nil)
+	c.Set(ctx, "b", nil)
+	c.Set(ctx, "c", nil)
+	c.Get(ctx, "a")
+	c.Get(ctx, "b")
+	c.Get(ctx, "c")
+	c.Delete(ctx, "b", "missing")
+	want := Stats{Hits: 2, Misses: 1, Evictions: 1, Entries: 1}
+	if got := c.Stats(); got != want {
+		t.Fatalf("stats %+v, want %+v", got, want)
+	}
+
+	var nop Nop
+	nop.Set(ctx, "a", nil)
+	if _, ok := nop.Get(ctx, "a"); ok {
+		t.Fatal("nop cache hit")
+	}
+	if got := nop.Stats(); got != (Stats{Misses: 1}) {
+		t.Fatalf("nop stats %+v", got)
+	}
+}
+
+func TestLRUConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(16, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprint((i + j) % 32)
+				c.Set(ctx, key, []byte(key))
+				if v, ok := c.Get(ctx, key); ok && string(v) != key {
+					t.Errorf("%s: %q", key, v)
+				}
+				c.Delete(ctx, fmt.Sprint(j%32))
+			}
+		}(i)
+	}
+	wg.Wait()
+	s := c.Stats()
+	if s.Entries > 16 || s.Hits+s.Misses != 800 {
+		t.Fatalf("stats %+v", s)
+	}
+}
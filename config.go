@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+)
+
+// configCommand conduit config print [flags]
+func configCommand(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: conduit config print [flags]")
+	}
+	cfg, err := config.Load(flag.NewFlagSet("config print", flag.ContinueOnError), args[1:])
+	if err != nil {
+		return err
+	}
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(out)
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return nil
+}
@@ -0,0 +1,755 @@
+// Package config typed configuration loaded from defaults, a YAML file,
+// environment variables and command line flags, each layer overriding the
+// one before it
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultFile config file loaded when present and no other file is given
+const DefaultFile = "conduit.yaml"
+
+// Config effective configuration
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Store    StoreConfig    `yaml:"store"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Articles ArticlesConfig `yaml:"articles"`
+	Cache    CacheConfig    `yaml:"cache"`
+	Mail     MailConfig     `yaml:"mail"`
+}
+
+// ServerConfig http server
+type ServerConfig struct {
+	Port int `yaml:"port"`
+	// SwaggerURL url of doc.json loaded by the swagger ui
+	SwaggerURL string `yaml:"swaggerURL"`
+	// TrustProxy take the client ip from X-Forwarded-For and X-Real-Ip,
+	// only safe behind a proxy that sets them
+	TrustProxy bool `yaml:"trustProxy"`
+}
+
+// StoreConfig storage backend
+type StoreConfig struct {
+	// Driver one of mongo, memory, sqlite or postgres
+	Driver string `yaml:"driver"`
+	// DatabaseURL dsn of sqlite and postgres
+	DatabaseURL string      `yaml:"databaseURL"`
+	Mongo       MongoConfig `yaml:"mongo"`
+}
+
+// MongoConfig mongodb connection, zero values use driver defaults
+type MongoConfig struct {
+	URI                    string        `yaml:"uri"`
+	Database               string        `yaml:"database"`
+	MaxPoolSize            uint64        `yaml:"maxPoolSize"`
+	MinPoolSize            uint64        `yaml:"minPoolSize"`
+	ConnectTimeout         time.Duration `yaml:"connectTimeout"`
+	ServerSelectionTimeout time.Duration `yaml:"serverSelectionTimeout"`
+	SocketTimeout          time.Duration `yaml:"socketTimeout"`
+	MaxConnIdleTime        time.Duration `yaml:"maxConnIdleTime"`
+	ReadPreference         string        `yaml:"readPreference"`
+}
+
+// AuthConfig authentication
+type AuthConfig struct {
+	// JWTSecret hmac key signing tokens, required without KeyFiles. With
+	// KeyFiles it is unused, tokens it signed are rejected and clients
+	// refresh them.
+	JWTSecret string `yaml:"jwtSecret"`
+	// KeyFiles PEM files of RS256, ES256 or EdDSA keys published in the
+	// jwks. The first signs, the others only verify, so a rotation puts the
+	// new key first and keeps the old one until its tokens expired.
+	KeyFiles []string `yaml:"keyFiles"`
+	// BcryptCost cost of bcrypt hashes, when Password.Algorithm is bcrypt
+	BcryptCost int            `yaml:"bcryptCost"`
+	Password   PasswordConfig `yaml:"password"`
+	// AccessTokenTTL lifetime of jwt access tokens
+	AccessTokenTTL time.Duration `yaml:"accessTokenTTL"`
+	// RefreshTokenTTL lifetime of a refresh token, each refresh issues a
+	// new one
+	RefreshTokenTTL time.Duration       `yaml:"refreshTokenTTL"`
+	PasswordReset   PasswordResetConfig `yaml:"passwordReset"`
+	// EmailVerification links mailed on registration and email changes
+	EmailVerification EmailVerificationConfig `yaml:"emailVerification"`
+	TwoFactor         TwoFactorConfig         `yaml:"twoFactor"`
+	OIDC              OIDCConfig              `yaml:"oidc"`
+	// Lockout throttling of failed logins
+	Lockout LockoutConfig `yaml:"lockout"`
+}
+
+// PasswordConfig password policy and hashing
+type PasswordConfig struct {
+	// MinLength fewest characters of a new password
+	MinLength int `yaml:"minLength"`
+	// MaxLength most characters of a new password, bounding the work of
+	// hashing it. Under bcrypt new passwords are also at most 72 bytes.
+	MaxLength int `yaml:"maxLength"`
+	// BannedFile file of common passwords, one per line, that new
+	// passwords must not be regardless of case. Empty bans none.
+	BannedFile string `yaml:"bannedFile"`
+	// Algorithm of new hashes, bcrypt or argon2id. A login rehashes a
+	// password stored with another algorithm or cost.
+	Algorithm string       `yaml:"algorithm"`
+	Argon2    Argon2Config `yaml:"argon2"`
+}
+
+// Argon2Config cost of argon2id hashes
+type Argon2Config struct {
+	// Time passes over memory
+	Time int `yaml:"time"`
+	// Memory KiB used per hash
+	Memory  int `yaml:"memory"`
+	Threads int `yaml:"threads"`
+}
+
+// LockoutConfig throttling of failed logins per account and per client ip
+type LockoutConfig struct {
+	// Threshold failed logins of one account that lock it, 0 never locks
+	Threshold int `yaml:"threshold"`
+	// IPThreshold failed logins from one client ip that throttle it, 0
+	// never throttles. Addresses may be shared, so there is no backoff.
+	IPThreshold int `yaml:"ipThreshold"`
+	// Duration how long a lock lasts after the last failure, older
+	// failures are forgotten
+	Duration time.Duration `yaml:"duration"`
+	// BackoffBase wait after the first failure of an account, doubling
+	// with every further one up to BackoffMax, 0 disables backoff
+	BackoffBase time.Duration `yaml:"backoffBase"`
+	BackoffMax  time.Duration `yaml:"backoffMax"`
+}
+
+// OIDCConfig login through OpenID Connect providers
+type OIDCConfig struct {
+	// SessionTTL how long a login may take at the provider
+	SessionTTL time.Duration        `yaml:"sessionTTL"`
+	Providers  []OIDCProviderConfig `yaml:"providers"`
+}
+
+// OIDCProviderConfig client registration at an OpenID Connect provider
+type OIDCProviderConfig struct {
+	// Name in the login urls, /api/users/oidc/{name}
+	Name   string `yaml:"name"`
+	Issuer string `yaml:"issuer"`
+	// ClientID, ClientSecret of the registration, no secret for public
+	// clients
+	ClientID     string `yaml:"clientID"`
+	ClientSecret string `yaml:"clientSecret"`
+	// RedirectURL frontend page the provider sends the user back to
+	RedirectURL string `yaml:"redirectURL"`
+	// Scopes requested, openid, email and profile when empty
+	Scopes []string `yaml:"scopes"`
+}
+
+// TwoFactorConfig TOTP second factor
+type TwoFactorConfig struct {
+	// Issuer name authenticator apps show next to the account
+	Issuer string `yaml:"issuer"`
+	// ChallengeTTL how long a login waits for the second factor
+	ChallengeTTL time.Duration `yaml:"challengeTTL"`
+}
+
+// EmailVerificationConfig email verification mails
+type EmailVerificationConfig struct {
+	// Required block CreateArticle and AddComment until the email is
+	// verified
+	Required bool `yaml:"required"`
+	// URL of the verify endpoint, the token is appended
+	URL string `yaml:"url"`
+	// TTL how long a mailed link stays valid
+	TTL time.Duration `yaml:"ttl"`
+	// Limit links resent to one user within Window, further requests are
+	// dropped
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// PasswordResetConfig password reset mails
+type PasswordResetConfig struct {
+	// URL of the reset page, the token is appended
+	URL string `yaml:"url"`
+	// TTL how long a mailed token can reset the password
+	TTL time.Duration `yaml:"ttl"`
+	// Limit resets mailed to one address within Window, further requests
+	// are dropped
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// ArticlesConfig article lifecycle
+type ArticlesConfig struct {
+	// Retention how long a deleted article can be restored before it is
+	// purged for good
+	Retention time.Duration `yaml:"retention"`
+	// PurgeInterval how often serve purges expired articles
+	PurgeInterval time.Duration `yaml:"purgeInterval"`
+	// ArchiveComments keep the comments of removed articles and users in
+	// the comment archive instead of deleting them
+	ArchiveComments bool `yaml:"archiveComments"`
+}
+
+// CacheConfig in-process cache of tags, articles and profiles
+type CacheConfig struct {
+	// Size max entries, 0 disables caching
+	Size int           `yaml:"size"`
+	TTL  time.Duration `yaml:"ttl"`
+}
+
+// MailConfig outgoing mail
+type MailConfig struct {
+	// Driver smtp, or outbox to write .eml files into Outbox
+	Driver string `yaml:"driver"`
+	// From sender address, optionally with a name
+	From   string     `yaml:"from"`
+	Outbox string     `yaml:"outbox"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+// SMTPConfig smtp server of the smtp mail driver
+type SMTPConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// Username, Password PLAIN auth, none without Username
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Default configuration before any file, env or flag is applied
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:       8080,
+			SwaggerURL: "/swagger/doc.json",
+		},
+		Store: StoreConfig{
+			Driver: "mongo",
+			Mongo: MongoConfig{
+				URI:      "mongodb://localhost:27017",
+				Database: "conduit",
+			},
+		},
+		Auth: AuthConfig{
+			BcryptCost:      bcrypt.DefaultCost,
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+			Password: PasswordConfig{
+				MinLength: 8,
+				MaxLength: 64,
+				Algorithm: "argon2id",
+				// the OWASP minimum for argon2id
+				Argon2: Argon2Config{Time: 2, Memory: 19 * 1024, Threads: 1},
+			},
+			PasswordReset: PasswordResetConfig{
+				URL:    "http://localhost:4100/reset-password?token=",
+				TTL:    time.Hour,
+				Limit:  3,
+				Window: time.Hour,
+			},
+			EmailVerification: EmailVerificationConfig{
+				URL:    "http://localhost:8080/api/users/verify?token=",
+				TTL:    48 * time.Hour,
+				Limit:  3,
+				Window: time.Hour,
+			},
+			TwoFactor: TwoFactorConfig{
+				Issuer:       "Conduit",
+				ChallengeTTL: 5 * time.Minute,
+			},
+			OIDC: OIDCConfig{
+				SessionTTL: 10 * time.Minute,
+			},
+			Lockout: LockoutConfig{
+				Threshold:   5,
+				IPThreshold: 50,
+				Duration:    15 * time.Minute,
+				BackoffBase: time.Second,
+				BackoffMax:  time.Minute,
+			},
+		},
+		Articles: ArticlesConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Cache: CacheConfig{
+			Size: 10000,
+			TTL:  5 * time.Minute,
+		},
+		Mail: MailConfig{
+			Driver: "outbox",
+			From:   "Conduit <no-reply@localhost>",
+			Outbox: "outbox",
+			SMTP:   SMTPConfig{Port: 587},
+		},
+	}
+}
+
+// binding env variable and flag setting one field
+type binding struct {
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+func setString(field func(c *Config) *string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+// setList comma separated values, blanks dropped
+func setList(field func(c *Config) *[]string) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		var list []string
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				list = append(list, e)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setUint(field func(c *Config) *uint64) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(c *Config, v string) error {
+	return func(c *Config, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// bindings every field settable from env, those with a flag name also
+// from the command line. Secrets have no flag so they stay out of ps.
+var bindings = []binding{
+	{"PORT", "port", "http port", setInt(func(c *Config) *int { return &c.Server.Port })},
+	{"SWAGGER_URL", "swagger-url", "url of swagger doc.json", setString(func(c *Config) *string { return &c.Server.SwaggerURL })},
+	{"TRUST_PROXY", "trust-proxy", "take the client ip from X-Forwarded-For, only behind a proxy", setBool(func(c *Config) *bool { return &c.Server.TrustProxy })},
+	{"STORE", "store", "storage backend: mongo, memory, sqlite or postgres", setString(func(c *Config) *string { return &c.Store.Driver })},
+	{"DATABASE_URL", "database-url", "sqlite or postgres dsn", setString(func(c *Config) *string { return &c.Store.DatabaseURL })},
+	{"MONGODBURI", "mongodb-uri", "mongodb connection uri", setString(func(c *Config) *string { return &c.Store.Mongo.URI })},
+	{"MONGODB_DATABASE", "mongodb-database", "mongodb database name", setString(func(c *Config) *string { return &c.Store.Mongo.Database })},
+	{"MONGODB_MAX_POOL_SIZE", "", "", setUint(func(c *Config) *uint64 { return &c.Store.Mongo.MaxPoolSize })},
+	{"MONGODB_MIN_POOL_SIZE", "", "", setUint(func(c *Config) *uint64 { return &c.Store.Mongo.MinPoolSize })},
+	{"MONGODB_CONNECT_TIMEOUT", "", "", setDuration(func(c *Config) *time.Duration { return &c.Store.Mongo.ConnectTimeout })},
+	{"MONGODB_SERVER_SELECTION_TIMEOUT", "", "", setDuration(func(c *Config) *time.Duration { return &c.Store.Mongo.ServerSelectionTimeout })},
+	{"MONGODB_SOCKET_TIMEOUT", "", "", setDuration(func(c *Config) *time.Duration { return &c.Store.Mongo.SocketTimeout })},
+	{"MONGODB_MAX_CONN_IDLE_TIME", "", "", setDuration(func(c *Config) *time.Duration { return &c.Store.Mongo.MaxConnIdleTime })},
+	{"MONGODB_READ_PREFERENCE", "", "", setString(func(c *Config) *string { return &c.Store.Mongo.ReadPreference })},
+	{"SECRET", "", "", setString(func(c *Config) *string { return &c.Auth.JWTSecret })},
+	{"JWT_KEY_FILES", "jwt-key-files", "comma separated PEM files of token signing keys, the first signs", setList(func(c *Config) *[]string { return &c.Auth.KeyFiles })},
+	{"BCRYPT_COST", "bcrypt-cost", "bcrypt cost of password hashes", setInt(func(c *Config) *int { return &c.Auth.BcryptCost })},
+	{"PASSWORD_MIN_LENGTH", "password-min-length", "fewest characters of a new password", setInt(func(c *Config) *int { return &c.Auth.Password.MinLength })},
+	{"PASSWORD_MAX_LENGTH", "password-max-length", "most characters of a new password", setInt(func(c *Config) *int { return &c.Auth.Password.MaxLength })},
+	{"PASSWORD_BANNED_FILE", "password-banned-file", "file of banned passwords, one per line", setString(func(c *Config) *string { return &c.Auth.Password.BannedFile })},
+	{"PASSWORD_ALGORITHM", "password-algorithm", "hash of new passwords: bcrypt or argon2id", setString(func(c *Config) *string { return &c.Auth.Password.Algorithm })},
+	{"ARGON2_TIME", "", "", setInt(func(c *Config) *int { return &c.Auth.Password.Argon2.Time })},
+	{"ARGON2_MEMORY", "", "", setInt(func(c *Config) *int { return &c.Auth.Password.Argon2.Memory })},
+	{"ARGON2_THREADS", "", "", setInt(func(c *Config) *int { return &c.Auth.Password.Argon2.Threads })},
+	{"ACCESS_TOKEN_TTL", "access-token-ttl", "lifetime of access tokens", setDuration(func(c *Config) *time.Duration { return &c.Auth.AccessTokenTTL })},
+	{"REFRESH_TOKEN_TTL", "refresh-token-ttl", "lifetime of refresh tokens", setDuration(func(c *Config) *time.Duration { return &c.Auth.RefreshTokenTTL })},
+	{"PASSWORD_RESET_URL", "password-reset-url", "url of the reset page mailed with the token appended", setString(func(c *Config) *string { return &c.Auth.PasswordReset.URL })},
+	{"PASSWORD_RESET_TTL", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.PasswordReset.TTL })},
+	{"PASSWORD_RESET_LIMIT", "", "", setInt(func(c *Config) *int { return &c.Auth.PasswordReset.Limit })},
+	{"PASSWORD_RESET_WINDOW", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.PasswordReset.Window })},
+	{"REQUIRE_VERIFIED_EMAIL", "require-verified-email", "block articles and comments of users with unverified email", setBool(func(c *Config) *bool { return &c.Auth.EmailVerification.Required })},
+	{"EMAIL_VERIFICATION_URL", "email-verification-url", "url of the verify endpoint mailed with the token appended", setString(func(c *Config) *string { return &c.Auth.EmailVerification.URL })},
+	{"EMAIL_VERIFICATION_TTL", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.EmailVerification.TTL })},
+	{"EMAIL_VERIFICATION_LIMIT", "", "", setInt(func(c *Config) *int { return &c.Auth.EmailVerification.Limit })},
+	{"EMAIL_VERIFICATION_WINDOW", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.EmailVerification.Window })},
+	{"TWO_FACTOR_ISSUER", "", "", setString(func(c *Config) *string { return &c.Auth.TwoFactor.Issuer })},
+	{"TWO_FACTOR_CHALLENGE_TTL", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.TwoFactor.ChallengeTTL })},
+	{"OIDC_SESSION_TTL", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.OIDC.SessionTTL })},
+	{"LOGIN_LOCKOUT_THRESHOLD", "login-lockout-threshold", "failed logins that lock an account, 0 never locks", setInt(func(c *Config) *int { return &c.Auth.Lockout.Threshold })},
+	{"LOGIN_IP_THRESHOLD", "", "", setInt(func(c *Config) *int { return &c.Auth.Lockout.IPThreshold })},
+	{"LOGIN_LOCKOUT_DURATION", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.Lockout.Duration })},
+	{"LOGIN_BACKOFF_BASE", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.Lockout.BackoffBase })},
+	{"LOGIN_BACKOFF_MAX", "", "", setDuration(func(c *Config) *time.Duration { return &c.Auth.Lockout.BackoffMax })},
+	{"ARTICLE_RETENTION", "article-retention", "how long deleted articles can be restored", setDuration(func(c *Config) *time.Duration { return &c.Articles.Retention })},
+	{"ARTICLE_PURGE_INTERVAL", "", "", setDuration(func(c *Config) *time.Duration { return &c.Articles.PurgeInterval })},
+	{"ARCHIVE_COMMENTS", "", "", setBool(func(c *Config) *bool { return &c.Articles.ArchiveComments })},
+	{"CACHE_SIZE", "cache-size", "max cached entries, 0 disables the cache", setInt(func(c *Config) *int { return &c.Cache.Size })},
+	{"CACHE_TTL", "", "", setDuration(func(c *Config) *time.Duration { return &c.Cache.TTL })},
+	{"MAIL_DRIVER", "mail-driver", "mail delivery: smtp or outbox", setString(func(c *Config) *string { return &c.Mail.Driver })},
+	{"MAIL_FROM", "mail-from", "sender address of mails", setString(func(c *Config) *string { return &c.Mail.From })},
+	{"MAIL_OUTBOX", "mail-outbox", "directory of the outbox mail driver", setString(func(c *Config) *string { return &c.Mail.Outbox })},
+	{"SMTP_HOST", "smtp-host", "smtp server host", setString(func(c *Config) *string { return &c.Mail.SMTP.Host })},
+	{"SMTP_PORT", "smtp-port", "smtp server port", setInt(func(c *Config) *int { return &c.Mail.SMTP.Port })},
+	{"SMTP_USERNAME", "", "", setString(func(c *Config) *string { return &c.Mail.SMTP.Username })},
+	{"SMTP_PASSWORD", "", "", setString(func(c *Config) *string { return &c.Mail.SMTP.Password })},
+}
+
+// Load register config flags on fs, parse args and build the config from
+// defaults, then the file given by -config or CONDUIT_CONFIG (DefaultFile
+// when present), then env, then flags. The caller validates the result.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	file := fs.String("config", "", "config file, defaults to $CONDUIT_CONFIG or "+DefaultFile)
+	flagValues := make(map[string]*string)
+	for _, b := range bindings {
+		if b.flag != "" {
+			flagValues[b.flag] = fs.String(b.flag, "", b.usage+", overrides $"+b.env)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	c := Default()
+
+	path, required := *file, true
+	if path == "" {
+		path = os.Getenv("CONDUIT_CONFIG")
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+	if err := c.loadFile(path, required); err != nil {
+		return nil, err
+	}
+
+	for _, b := range bindings {
+		if v, ok := os.LookupEnv(b.env); ok && v != "" {
+			if err := b.set(c, v); err != nil {
+				return nil, fmt.Errorf("config: $%s: %w", b.env, err)
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		value, ok := flagValues[f.Name]
+		if !ok || err != nil {
+			return
+		}
+		for _, b := range bindings {
+			if b.flag == f.Name {
+				if setErr := b.set(c, *value); setErr != nil {
+					err = fmt.Errorf("config: -%s: %w", f.Name, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadFile merge yaml file at path over c, a missing optional file is
+// skipped and unknown keys are rejected
+func (c *Config) loadFile(path string, required bool) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate all problems of the config in one error
+func (c *Config) Validate() error {
+	var problems []string
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("server.port %d out of range", c.Server.Port))
+	}
+	if err := c.Store.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if c.Auth.JWTSecret == "" && len(c.Auth.KeyFiles) == 0 {
+		problems = append(problems, "auth.jwtSecret is empty and auth.keyFiles lists no keys, set one in the config file, $SECRET or $JWT_KEY_FILES")
+	}
+	if c.Auth.BcryptCost < bcrypt.MinCost || c.Auth.BcryptCost > bcrypt.MaxCost {
+		problems = append(problems, fmt.Sprintf("auth.bcryptCost %d not within %d and %d", c.Auth.BcryptCost, bcrypt.MinCost, bcrypt.MaxCost))
+	}
+	if err := c.Auth.Password.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.accessTokenTTL %s must be positive", c.Auth.AccessTokenTTL))
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		problems = append(problems, fmt.Sprintf("auth.refreshTokenTTL %s must not be shorter than auth.accessTokenTTL", c.Auth.RefreshTokenTTL))
+	}
+	if reset := c.Auth.PasswordReset; reset.TTL <= 0 || reset.TTL > store.PasswordResetRetention {
+		problems = append(problems, fmt.Sprintf("auth.passwordReset.ttl %s must be positive and at most %s", reset.TTL, store.PasswordResetRetention))
+	}
+	if reset := c.Auth.PasswordReset; reset.Window <= 0 || reset.Window > store.PasswordResetRetention {
+		problems = append(problems, fmt.Sprintf("auth.passwordReset.window %s must be positive and at most %s", reset.Window, store.PasswordResetRetention))
+	}
+	if c.Auth.PasswordReset.Limit < 1 {
+		problems = append(problems, fmt.Sprintf("auth.passwordReset.limit %d must be at least 1", c.Auth.PasswordReset.Limit))
+	}
+	if c.Auth.EmailVerification.TTL <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.emailVerification.ttl %s must be positive", c.Auth.EmailVerification.TTL))
+	}
+	if c.Auth.EmailVerification.Window <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.emailVerification.window %s must be positive", c.Auth.EmailVerification.Window))
+	}
+	if c.Auth.EmailVerification.Limit < 1 {
+		problems = append(problems, fmt.Sprintf("auth.emailVerification.limit %d must be at least 1", c.Auth.EmailVerification.Limit))
+	}
+	if c.Auth.TwoFactor.Issuer == "" {
+		problems = append(problems, "auth.twoFactor.issuer is required")
+	}
+	// user revocations last AccessTokenTTL, a longer challenge would
+	// outlive them
+	if ttl := c.Auth.TwoFactor.ChallengeTTL; ttl <= 0 || ttl > c.Auth.AccessTokenTTL {
+		problems = append(problems, fmt.Sprintf("auth.twoFactor.challengeTTL %s must be positive and at most auth.accessTokenTTL", ttl))
+	}
+	if err := c.Auth.OIDC.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if lockout := c.Auth.Lockout; lockout.Threshold < 0 || lockout.IPThreshold < 0 {
+		problems = append(problems, fmt.Sprintf("auth.lockout.threshold %d and auth.lockout.ipThreshold %d must not be negative", lockout.Threshold, lockout.IPThreshold))
+	}
+	if c.Auth.Lockout.Duration <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.lockout.duration %s must be positive", c.Auth.Lockout.Duration))
+	}
+	// failures are forgotten after Duration, a longer wait would outlive
+	// its reason
+	if lockout := c.Auth.Lockout; lockout.BackoffBase < 0 || lockout.BackoffMax < lockout.BackoffBase || lockout.BackoffMax > lockout.Duration {
+		problems = append(problems, fmt.Sprintf("auth.lockout.backoffBase %s and auth.lockout.backoffMax %s must satisfy 0 <= backoffBase <= backoffMax <= duration", lockout.BackoffBase, lockout.BackoffMax))
+	}
+	if c.Articles.Retention <= 0 {
+		problems = append(problems, fmt.Sprintf("articles.retention %s must be positive", c.Articles.Retention))
+	}
+	if c.Articles.PurgeInterval <= 0 {
+		problems = append(problems, fmt.Sprintf("articles.purgeInterval %s must be positive", c.Articles.PurgeInterval))
+	}
+	if c.Cache.Size < 0 {
+		problems = append(problems, fmt.Sprintf("cache.size %d is negative", c.Cache.Size))
+	}
+	if c.Cache.Size > 0 && c.Cache.TTL <= 0 {
+		problems = append(problems, fmt.Sprintf("cache.ttl %s must be positive", c.Cache.TTL))
+	}
+	if err := c.Mail.Validate(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Validate store settings only, enough for commands that never serve
+func (c *StoreConfig) Validate() error {
+	switch c.Driver {
+	case "memory":
+	case "sqlite", "postgres":
+		if c.DatabaseURL == "" {
+			return fmt.Errorf("store.databaseURL is required by the %s store", c.Driver)
+		}
+	case "mongo":
+		if c.Mongo.URI == "" || c.Mongo.Database == "" {
+			return errors.New("store.mongo.uri and store.mongo.database are required by the mongo store")
+		}
+	default:
+		return fmt.Errorf("store.driver %q is not one of mongo, memory, sqlite or postgres", c.Driver)
+	}
+	return nil
+}
+
+// Validate password policy and hashing settings
+func (c *PasswordConfig) Validate() error {
+	var problems []string
+	if c.MinLength < 1 {
+		problems = append(problems, fmt.Sprintf("auth.password.minLength %d must be at least 1", c.MinLength))
+	}
+	if c.MaxLength < c.MinLength {
+		problems = append(problems, fmt.Sprintf("auth.password.maxLength %d must be at least minLength %d", c.MaxLength, c.MinLength))
+	}
+	if c.Algorithm != "bcrypt" && c.Algorithm != "argon2id" {
+		problems = append(problems, fmt.Sprintf("auth.password.algorithm %q is not one of bcrypt or argon2id", c.Algorithm))
+	}
+	if c.Argon2.Time < 1 {
+		problems = append(problems, fmt.Sprintf("auth.password.argon2.time %d must be at least 1", c.Argon2.Time))
+	}
+	if c.Argon2.Threads < 1 || c.Argon2.Threads > 255 {
+		problems = append(problems, fmt.Sprintf("auth.password.argon2.threads %d not within 1 and 255", c.Argon2.Threads))
+	}
+	// argon2 needs 8 KiB per thread, more than 4 GiB no longer fits
+	if c.Argon2.Memory < 8*c.Argon2.Threads || c.Argon2.Memory > 4*1024*1024 {
+		problems = append(problems, fmt.Sprintf("auth.password.argon2.memory %d KiB not within 8 KiB per thread and 4 GiB", c.Argon2.Memory))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// providerName valid provider names, used in urls
+var providerName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// Validate oidc settings
+func (c *OIDCConfig) Validate() error {
+	var problems []string
+	if c.SessionTTL <= 0 {
+		problems = append(problems, fmt.Sprintf("auth.oidc.sessionTTL %s must be positive", c.SessionTTL))
+	}
+	seen := make(map[string]bool)
+	for i, p := range c.Providers {
+		prefix := fmt.Sprintf("auth.oidc.providers[%d]", i)
+		if !providerName.MatchString(p.Name) {
+			problems = append(problems, fmt.Sprintf("%s.name %q must be lowercase letters, digits and dashes", prefix, p.Name))
+		} else if seen[p.Name] {
+			problems = append(problems, fmt.Sprintf("%s.name %q is used twice", prefix, p.Name))
+		}
+		seen[p.Name] = true
+		if u, err := url.Parse(p.Issuer); err != nil || !u.IsAbs() {
+			problems = append(problems, fmt.Sprintf("%s.issuer %q must be an absolute url", prefix, p.Issuer))
+		}
+		if p.ClientID == "" {
+			problems = append(problems, prefix+".clientID is required")
+		}
+		if u, err := url.Parse(p.RedirectURL); err != nil || !u.IsAbs() {
+			problems = append(problems, fmt.Sprintf("%s.redirectURL %q must be an absolute url", prefix, p.RedirectURL))
+		}
+		if len(p.Scopes) > 0 && !contains(p.Scopes, "openid") {
+			problems = append(problems, prefix+".scopes must include openid")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate mail settings
+func (c *MailConfig) Validate() error {
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("mail.from %q: %v", c.From, err)
+	}
+	switch c.Driver {
+	case "outbox":
+		if c.Outbox == "" {
+			return errors.New("mail.outbox is required by the outbox mail driver")
+		}
+	case "smtp":
+		if c.SMTP.Host == "" {
+			return errors.New("mail.smtp.host is required by the smtp mail driver")
+		}
+		if c.SMTP.Port < 1 || c.SMTP.Port > 65535 {
+			return fmt.Errorf("mail.smtp.port %d out of range", c.SMTP.Port)
+		}
+	default:
+		return fmt.Errorf("mail.driver %q is not one of smtp or outbox", c.Driver)
+	}
+	return nil
+}
+
+// Redacted copy of c safe to print, secrets and url passwords masked
+func (c *Config) Redacted() *Config {
+	r := *c
+	if r.Auth.JWTSecret != "" {
+		r.Auth.JWTSecret = redacted
+	}
+	if r.Mail.SMTP.Password != "" {
+		r.Mail.SMTP.Password = redacted
+	}
+	r.Auth.OIDC.Providers = append([]OIDCProviderConfig(nil), c.Auth.OIDC.Providers...)
+	for i := range r.Auth.OIDC.Providers {
+		if r.Auth.OIDC.Providers[i].ClientSecret != "" {
+			r.Auth.OIDC.Providers[i].ClientSecret = redacted
+		}
+	}
+	r.Store.DatabaseURL = redactURL(r.Store.DatabaseURL)
+	r.Store.Mongo.URI = redactURL(r.Store.Mongo.URI)
+	return &r
+}
+
+const redacted = "REDACTED"
+
+// redactURL mask the password of a url, anything unparsable is masked whole
+// unless it looks like a plain file path
+func redactURL(raw string) string {
+	if raw == "" || !strings.Contains(raw, "://") {
+		if strings.Contains(raw, "password=") {
+			return redacted
+		}
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	query := u.Query()
+	if query.Get("password") != "" {
+		query.Set("password", redacted)
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// YAML config as yaml
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
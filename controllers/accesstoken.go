@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/middlewares"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxAccessTokenName longest name of a personal access token
+const maxAccessTokenName = 100
+
+// errExpiryPast expiry of a new personal access token is not in the future
+var errExpiryPast = errors.New("error: expiresAt must be in the future")
+
+// CreateAccessTokenInput new personal access token post data, without
+// ExpiresAt the token never expires
+type CreateAccessTokenInput struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// validate check name, scopes and expiry of data
+func (data *CreateAccessTokenInput) validate(now time.Time) error {
+	data.Name = strings.TrimSpace(data.Name)
+	if data.Name == "" || len(data.Name) > maxAccessTokenName {
+		return fmt.Errorf("error: name must be 1 to %d characters", maxAccessTokenName)
+	}
+	if len(data.Scopes) == 0 {
+		return errors.New("error: scopes must not be empty")
+	}
+	for _, scope := range data.Scopes {
+		if !models.ValidScope(scope) {
+			return fmt.Errorf("error: unknown scope %q, scopes are %s", scope, strings.Join(models.Scopes, ", "))
+		}
+	}
+	if data.ExpiresAt != nil && !data.ExpiresAt.After(now) {
+		return errExpiryPast
+	}
+	return nil
+}
+
+// GetAccessTokens personal access tokens of the current user, newest first
+func (h *Handler) GetAccessTokens(c *gin.Context) {
+	tokens, err := h.AccessTokens.List(c.Request.Context(), middlewares.CurrentUser(c).ID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"accessTokens": tokens,
+	})
+}
+
+// CreateAccessToken create a personal access token of the current user.
+// The response is the only time the token is shown.
+func (h *Handler) CreateAccessToken(c *gin.Context) {
+	var data CreateAccessTokenInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	now := time.Now()
+	if err := data.validate(now); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ss, err := models.GenerateAccessToken()
+	var token *models.AccessToken
+	if err == nil {
+		token = &models.AccessToken{
+			User:      middlewares.CurrentUser(c).ID,
+			Name:      data.Name,
+			Hash:      models.HashToken(ss),
+			Scopes:    dedupe(data.Scopes),
+			CreatedAt: now,
+			ExpiresAt: data.ExpiresAt,
+		}
+		err = h.AccessTokens.Create(c.Request.Context(), token)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	token.Token = ss
+	c.JSON(http.StatusCreated, gin.H{
+		"accessToken": token,
+	})
+}
+
+// DeleteAccessToken revoke a personal access token of the current user
+func (h *Handler) DeleteAccessToken(c *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := h.AccessTokens.Delete(c.Request.Context(), middlewares.CurrentUser(c).ID, id); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// dedupe values in order of first appearance
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+func TestAccessTokenScopes(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	session := f.register("ada")
+	f.register("bob")
+	read := f.accessToken(session, CreateAccessTokenInput{Name: "reader", Scopes: []string{models.ScopeProfileRead}})
+
+	f.do(http.MethodGet, "/api/user", read.Token, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/feed", read.Token, nil, http.StatusOK, nil)
+	// scopes the token lacks
+	f.do(http.MethodPost, "/api/articles", read.Token, CreateArticleInput{Title: "Hello"}, http.StatusForbidden, nil)
+	f.do(http.MethodPost, "/api/profiles/bob/follow", read.Token, nil, http.StatusForbidden, nil)
+	// routes for sessions only, whatever the scopes
+	all := f.accessToken(session, CreateAccessTokenInput{Name: "all", Scopes: models.Scopes})
+	for _, token := range []string{read.Token, all.Token} {
+		f.do(http.MethodPut, "/api/user", token, UpdateUserInput{Bio: "changed"}, http.StatusForbidden, nil)
+		f.do(http.MethodPost, "/api/user/tokens", token, CreateAccessTokenInput{Name: "more", Scopes: models.Scopes}, http.StatusForbidden, nil)
+		f.do(http.MethodPost, "/api/users/logout/all", token, nil, http.StatusForbidden, nil)
+	}
+	f.do(http.MethodPost, "/api/articles", all.Token, CreateArticleInput{Title: "Hello"}, http.StatusOK, nil)
+	// anonymous routes take a token of any scope
+	f.do(http.MethodGet, "/api/articles/hello", read.Token, nil, http.StatusOK, nil)
+}
+
+func TestAccessTokenExpired(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	session := f.register("ada")
+	f.do(http.MethodPost, "/api/user/tokens", session, CreateAccessTokenInput{
+		Name: "past", Scopes: models.Scopes, ExpiresAt: timePtr(time.Now().Add(-time.Minute)),
+	}, http.StatusUnprocessableEntity, nil)
+
+	// expiring soon is accepted, stored as expired to not wait for it
+	soon := f.accessToken(session, CreateAccessTokenInput{Name: "soon", Scopes: models.Scopes, ExpiresAt: timePtr(time.Now().Add(time.Hour))})
+	f.do(http.MethodGet, "/api/user", soon.Token, nil, http.StatusOK, nil)
+	ctx := context.Background()
+	ss, err := models.GenerateAccessToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := f.h.Users.FindByUsername(ctx, "ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := &models.AccessToken{
+		User: user.ID, Name: "expired", Hash: models.HashToken(ss), Scopes: models.Scopes,
+		CreatedAt: time.Now().Add(-time.Hour), ExpiresAt: timePtr(time.Now().Add(-time.Second)),
+	}
+	if err := f.h.AccessTokens.Create(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	f.do(http.MethodGet, "/api/user", ss, nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodGet, "/api/articles", ss, nil, http.StatusUnauthorized, nil)
+}
+
+func TestAccessTokenRevoked(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	ada, bob := f.register("ada"), f.register("bob")
+	token := f.accessToken(ada, CreateAccessTokenInput{Name: "ci", Scopes: models.Scopes})
+	f.do(http.MethodGet, "/api/user", token.Token, nil, http.StatusOK, nil)
+
+	var list struct {
+		AccessTokens []models.AccessToken `json:"accessTokens"`
+	}
+	f.do(http.MethodGet, "/api/user/tokens", ada, nil, http.StatusOK, &list)
+	if len(list.AccessTokens) != 1 || list.AccessTokens[0].ID != token.ID || list.AccessTokens[0].Token != "" {
+		t.Fatalf("tokens %+v", list.AccessTokens)
+	}
+	path := "/api/user/tokens/" + token.ID.Hex()
+	// only its owner revokes it
+	f.do(http.MethodDelete, path, bob, nil, http.StatusNotFound, nil)
+	f.do(http.MethodGet, "/api/user", token.Token, nil, http.StatusOK, nil)
+	f.do(http.MethodDelete, path, ada, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", token.Token, nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodDelete, path, ada, nil, http.StatusNotFound, nil)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
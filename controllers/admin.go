@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/events"
+	"github.com/jameslahm/conduit-server-gin/middlewares"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// errRoleUnknown role is not one of models.Roles
+	errRoleUnknown = errors.New("error: unknown role")
+	// errOwnRole admins cannot demote themselves, keeping at least one
+	errOwnRole = errors.New("error: cannot change your own role")
+)
+
+// AdminUserJSON user as admins see them
+type AdminUserJSON struct {
+	ID            primitive.ObjectID `json:"id"`
+	Email         string             `json:"email"`
+	Username      string             `json:"username"`
+	Bio           string             `json:"bio"`
+	Image         string             `json:"image"`
+	EmailVerified bool               `json:"emailVerified"`
+	Role          models.Role        `json:"role"`
+}
+
+func adminUser(user *models.User) AdminUserJSON {
+	return AdminUserJSON{
+		ID:            user.ID,
+		Email:         user.Email,
+		Username:      user.Username,
+		Bio:           user.Bio,
+		Image:         user.Image,
+		EmailVerified: user.EmailVerified,
+		Role:          user.Role,
+	}
+}
+
+// audit record action of the current user on target. The action already
+// happened, a failure is only logged.
+func (h *Handler) audit(c *gin.Context, action string, target string, detail string) {
+	actor := middlewares.CurrentUser(c)
+	err := h.Audit.Create(c.Request.Context(), &models.AuditEntry{
+		Actor:         actor.ID,
+		ActorUsername: actor.Username,
+		Action:        action,
+		Target:        target,
+		Detail:        detail,
+		CreatedAt:     time.Now(),
+	})
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// AdminUsersArgs args for listing users
+type AdminUsersArgs struct {
+	// Q part of username or email
+	Q      string      `form:"q"`
+	Role   models.Role `form:"role"`
+	Limit  int         `form:"limit"`
+	Offset int         `form:"offset"`
+}
+
+// AdminGetUsers list and search users
+func (h *Handler) AdminGetUsers(c *gin.Context) {
+	args := AdminUsersArgs{Limit: defaultLimit}
+	if err := c.ShouldBindQuery(&args); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if args.Role != "" && !models.ValidRole(args.Role) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": errRoleUnknown.Error(),
+		})
+		return
+	}
+
+	filter := store.UserFilter{Query: strings.TrimSpace(args.Q), Role: args.Role}
+	filter.Limit, filter.Offset = page(args.Limit, args.Offset)
+	users, counts, err := h.Users.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	usersJSON := make([]AdminUserJSON, len(users))
+	for i, user := range users {
+		usersJSON[i] = adminUser(user)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"users":      usersJSON,
+		"usersCount": counts,
+	})
+}
+
+// SetRoleInput change role put data
+type SetRoleInput struct {
+	Role models.Role `json:"role" binding:"required"`
+}
+
+// AdminSetRole change the role of a user, taking effect on their next
+// request
+func (h *Handler) AdminSetRole(c *gin.Context) {
+	var data SetRoleInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if !models.ValidRole(data.Role) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": errRoleUnknown.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.Users.FindByUsername(ctx, c.Param("username"))
+	if err == nil && user.ID == middlewares.CurrentUser(c).ID {
+		err = errOwnRole
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if err == errOwnRole {
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if user.Role != data.Role {
+		previous := user.Role
+		user, err = h.Users.Update(ctx, user.ID, store.UserUpdate{Role: &data.Role})
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		h.forgetUser(ctx, user.ID)
+		h.audit(c, models.AuditRoleChanged, user.Username, fmt.Sprintf("%s -> %s", previous, user.Role))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user": adminUser(user),
+	})
+}
+
+// AdminUnlockUser clear the failed logins of a user, lifting a lockout
+// and backoff. Failures from client ips stay counted.
+func (h *Handler) AdminUnlockUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	user, err := h.Users.FindByUsername(ctx, c.Param("username"))
+	if err == nil {
+		err = h.LoginAttempts.Reset(ctx, models.AccountAttemptKey(user.ID))
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	h.audit(c, models.AuditUserUnlocked, user.Username, "")
+	c.JSON(http.StatusOK, gin.H{
+		"user": adminUser(user),
+	})
+}
+
+// AdminUpdateArticle update the article of any author
+func (h *Handler) AdminUpdateArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
+
+	var data UpdateArticleInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	found, err := h.Articles.FindBySlugWithAuthor(ctx, c.Param("slug"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	update := articleUpdate(&data)
+	article, err := h.Articles.Update(ctx, found.Slug, found.Author.ID, update)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	h.forgetArticle(ctx, article.Slug)
+	h.Events.Publish(ctx, events.ArticleUpdated{Article: *article})
+
+	var fields []string
+	if update.Title != nil {
+		fields = append(fields, "title")
+	}
+	if update.Description != nil {
+		fields = append(fields, "description")
+	}
+	if update.Body != nil {
+		fields = append(fields, "body")
+	}
+	if update.TagList != nil {
+		fields = append(fields, "tagList")
+	}
+	h.audit(c, models.AuditArticleUpdated, article.Slug,
+		fmt.Sprintf("by %s, changed %s", found.Author.Username, strings.Join(fields, ", ")))
+
+	var articleJSON models.ArticleJSON
+	articleJSON.ArticleBase = article.ArticleBase
+	articleJSON.Author = found.Author.ToProfile(loginUser)
+	c.JSON(http.StatusOK, gin.H{
+		"article": articleJSON,
+	})
+}
+
+// AdminDeleteArticle delete the article of any author for good, its author
+// cannot restore it
+func (h *Handler) AdminDeleteArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	article, err := h.Articles.FindBySlugWithAuthor(ctx, c.Param("slug"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	report, err := h.Articles.Remove(ctx, article.ID, CommentPolicy(h.Config))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	h.forgetArticle(ctx, article.Slug)
+	h.Events.Publish(ctx, events.ArticleDeleted{Slug: article.Slug, Author: article.Author.ID})
+	h.audit(c, models.AuditArticleDeleted, article.Slug, fmt.Sprintf("by %s, %q", article.Author.Username, article.Title))
+	c.JSON(http.StatusOK, gin.H{
+		"removed": report,
+	})
+}
+
+// AdminDeleteComment delete the comment of any author
+func (h *Handler) AdminDeleteComment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	commentID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	article, err := h.Articles.FindBySlug(ctx, c.Param("slug"))
+	var comment *models.Comment
+	if err == nil {
+		comment, err = h.Comments.FindByID(ctx, commentID)
+	}
+	if err == nil && comment.Article != article.ID {
+		err = store.ErrNotFound
+	}
+	var author *models.User
+	if err == nil {
+		author, err = h.Users.FindByID(ctx, comment.Author)
+	}
+	if err == nil {
+		err = h.Comments.Delete(ctx, commentID, comment.Author)
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	h.Events.Publish(ctx, events.CommentDeleted{ID: commentID, Author: comment.Author, ArticleSlug: article.Slug})
+	h.audit(c, models.AuditCommentDeleted, commentID.Hex(), fmt.Sprintf("by %s on %s", author.Username, article.Slug))
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// AdminAuditArgs args for listing the audit log
+type AdminAuditArgs struct {
+	// Actor username of the actor
+	Actor  string `form:"actor"`
+	Action string `form:"action"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+// AdminGetAudit audit log newest first
+func (h *Handler) AdminGetAudit(c *gin.Context) {
+	args := AdminAuditArgs{Limit: defaultLimit}
+	if err := c.ShouldBindQuery(&args); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	filter := store.AuditFilter{Action: args.Action}
+	filter.Limit, filter.Offset = page(args.Limit, args.Offset)
+	if args.Actor != "" {
+		actor, err := h.Users.FindByUsername(ctx, args.Actor)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		filter.Actor = actor.ID
+	}
+	entries, counts, err := h.Audit.List(ctx, filter)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries":      entries,
+		"entriesCount": counts,
+	})
+}
+
+// AdminGetCacheStats hit, miss and eviction counters of the cache
+func (h *Handler) AdminGetCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"cache": h.Cache.Stats(),
+	})
+}
@@ -0,0 +1,406 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"github.com/jameslahm/conduit-server-gin/mail"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiFixture every route of a handler on a memory store
+type apiFixture struct {
+	t      *testing.T
+	h      *Handler
+	router *gin.Engine
+}
+
+func newAPIFixture(t *testing.T, configure func(cfg *config.Config)) *apiFixture {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test"
+	cfg.Auth.Password.Algorithm = "bcrypt"
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := keys.New([]byte(cfg.Auth.JWTSecret), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(memory.New(), cfg, ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Mailer = &recordingMailer{}
+	router := gin.New()
+	h.Routes(router)
+	return &apiFixture{t: t, h: h, router: router}
+}
+
+// do send data, if any, as json to path with the access token, if any,
+// and decode the response into out, if any, after checking its status
+func (f *apiFixture) do(method string, path string, token string, data interface{}, status int, out interface{}) {
+	f.t.Helper()
+	var body io.Reader
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		body = bytes.NewReader(b)
+	}
+	r := httptest.NewRequest(method, path, body)
+	if token != "" {
+		r.Header.Set("Authorization", "Token "+token)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	if w.Code != status {
+		f.t.Fatalf("%s %s: %d %s, want %d", method, path, w.Code, w.Body, status)
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			f.t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+}
+
+// register username and return their access token
+func (f *apiFixture) register(username string) string {
+	f.t.Helper()
+	var body struct {
+		User models.User `json:"user"`
+	}
+	data := RegisterInput{Username: username, Email: username + "@example.com", Password: "correct horse"}
+	f.do(http.MethodPost, "/api/users", "", data, http.StatusOK, &body)
+	return body.User.Token
+}
+
+// session log in as username, registered before, and return them with
+// their access token and refresh token
+func (f *apiFixture) session(username string) models.User {
+	f.t.Helper()
+	var body struct {
+		User models.User `json:"user"`
+	}
+	data := LoginInput{Email: username + "@example.com", Password: "correct horse"}
+	f.do(http.MethodPost, "/api/users/login", "", data, http.StatusOK, &body)
+	return body.User
+}
+
+// accessToken create a personal access token with data using the access
+// token session and return it
+func (f *apiFixture) accessToken(session string, data CreateAccessTokenInput) models.AccessToken {
+	f.t.Helper()
+	var body struct {
+		AccessToken models.AccessToken `json:"accessToken"`
+	}
+	f.do(http.MethodPost, "/api/user/tokens", session, data, http.StatusCreated, &body)
+	return body.AccessToken
+}
+
+// mailed token appended to a link in the last mail to username, and the
+// number of mails they got
+func (f *apiFixture) mailed(username string) (string, int) {
+	f.t.Helper()
+	mailer := f.h.Mailer.(*recordingMailer)
+	mailer.mu.Lock()
+	defer mailer.mu.Unlock()
+	var last *mail.Message
+	count := 0
+	for _, msg := range mailer.sent {
+		if msg.To == username+"@example.com" {
+			last = msg
+			count++
+		}
+	}
+	if last == nil {
+		return "", 0
+	}
+	i := strings.Index(last.Body, "token=")
+	if i < 0 {
+		f.t.Fatalf("no token in %q", last.Body)
+	}
+	return strings.Fields(last.Body[i+len("token="):])[0], count
+}
+
+type articleBody struct {
+	Article models.ArticleJSON `json:"article"`
+}
+
+type articlesBody struct {
+	Articles      []models.ArticleJSON `json:"articles"`
+	ArticlesCount int                  `json:"articlesCount"`
+}
+
+type profileBody struct {
+	Profile models.Profile `json:"profile"`
+}
+
+func TestAPIUsers(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	token := f.register("ada")
+	f.do(http.MethodPost, "/api/users", "", RegisterInput{Username: "ada2", Email: "ada@example.com", Password: "correct horse"},
+		http.StatusUnprocessableEntity, nil)
+	f.do(http.MethodPost, "/api/users", "", RegisterInput{Username: "bob", Email: "bob@example.com", Password: "short"},
+		http.StatusUnprocessableEntity, nil)
+	// the fixture hashes with bcrypt, which reads 72 bytes at most
+	f.do(http.MethodPost, "/api/users", "", RegisterInput{Username: "bob", Email: "bob@example.com", Password: strings.Repeat("ß", 40)},
+		http.StatusUnprocessableEntity, nil)
+
+	var body struct {
+		User models.User `json:"user"`
+	}
+	f.do(http.MethodPost, "/api/users/login", "", LoginInput{Email: "ada@example.com", Password: "correct horse"}, http.StatusOK, &body)
+	if body.User.Username != "ada" || body.User.Token == "" {
+		t.Fatalf("login: %+v", body.User)
+	}
+
+	f.do(http.MethodGet, "/api/user", "", nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodGet, "/api/user", "not a token", nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodPut, "/api/user", token, UpdateUserInput{Bio: "counts"}, http.StatusOK, nil)
+	body.User = models.User{}
+	f.do(http.MethodGet, "/api/user", token, nil, http.StatusOK, &body)
+	if body.User.Username != "ada" || body.User.Bio != "counts" {
+		t.Fatalf("current user: %+v", body.User)
+	}
+}
+
+func TestAPIArticles(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	ada, bob := f.register("ada"), f.register("bob")
+
+	var created articleBody
+	input := CreateArticleInput{Title: "Hello World", Description: "first", Body: "hi", TagList: []string{"go", "gin"}}
+	f.do(http.MethodPost, "/api/articles", ada, input, http.StatusOK, &created)
+	slug := created.Article.Slug
+	if slug != "hello-world" || created.Article.Author.Username != "ada" {
+		t.Fatalf("created %+v", created.Article)
+	}
+	f.do(http.MethodPost, "/api/articles", "", input, http.StatusUnauthorized, nil)
+	// the slug is taken
+	f.do(http.MethodPost, "/api/articles", bob, input, http.StatusUnprocessableEntity, nil)
+
+	var list articlesBody
+	f.do(http.MethodGet, "/api/articles?tag=go", "", nil, http.StatusOK, &list)
+	if list.ArticlesCount != 1 || len(list.Articles) != 1 || list.Articles[0].Slug != slug {
+		t.Fatalf("articles tagged go: %+v", list)
+	}
+	f.do(http.MethodGet, "/api/articles?author=bob", "", nil, http.StatusOK, &list)
+	if list.ArticlesCount != 0 {
+		t.Fatalf("articles of bob: %+v", list)
+	}
+	var tags struct {
+		Tags []string `json:"tags"`
+	}
+	f.do(http.MethodGet, "/api/tags", "", nil, http.StatusOK, &tags)
+	if len(tags.Tags) != 2 {
+		t.Fatalf("tags %v", tags.Tags)
+	}
+
+	var favorited articleBody
+	f.do(http.MethodPost, "/api/articles/"+slug+"/favorite", bob, nil, http.StatusOK, &favorited)
+	if favorited.Article.FavoritesCount != 1 {
+		t.Fatalf("favorites after favoriting: %d", favorited.Article.FavoritesCount)
+	}
+	f.do(http.MethodGet, "/api/articles?favorited=bob", "", nil, http.StatusOK, &list)
+	if list.ArticlesCount != 1 {
+		t.Fatalf("favorites of bob: %+v", list)
+	}
+	f.do(http.MethodDelete, "/api/articles/"+slug+"/favorite", bob, nil, http.StatusOK, &favorited)
+	if favorited.Article.FavoritesCount != 0 {
+		t.Fatalf("favorites after unfavoriting: %d", favorited.Article.FavoritesCount)
+	}
+
+	// only the author changes an article
+	update := UpdateArticleInput{Body: "changed"}
+	f.do(http.MethodPut, "/api/articles/"+slug, bob, update, http.StatusNotFound, nil)
+	f.do(http.MethodDelete, "/api/articles/"+slug, bob, nil, http.StatusNotFound, nil)
+	var updated articleBody
+	f.do(http.MethodPut, "/api/articles/"+slug, ada, update, http.StatusOK, &updated)
+	if updated.Article.Body != "changed" || updated.Article.Title != "Hello World" {
+		t.Fatalf("updated %+v", updated.Article)
+	}
+	var got articleBody
+	f.do(http.MethodGet, "/api/articles/"+slug, "", nil, http.StatusOK, &got)
+	if got.Article.Body != "changed" {
+		t.Fatalf("article after the update: %+v", got.Article)
+	}
+
+	f.do(http.MethodDelete, "/api/articles/"+slug, ada, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/articles/"+slug, "", nil, http.StatusNotFound, nil)
+	f.do(http.MethodPost, "/api/articles/"+slug+"/restore", ada, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/articles/"+slug, "", nil, http.StatusOK, nil)
+}
+
+func TestAPIComments(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	ada, bob := f.register("ada"), f.register("bob")
+	var article articleBody
+	f.do(http.MethodPost, "/api/articles", ada, CreateArticleInput{Title: "Hello"}, http.StatusOK, &article)
+	path := "/api/articles/" + article.Article.Slug + "/comments"
+
+	f.do(http.MethodPost, path, bob, AddCommentInput{Body: "nice"}, http.StatusOK, nil)
+	f.do(http.MethodPost, path, "", AddCommentInput{Body: "anonymous"}, http.StatusUnauthorized, nil)
+	f.do(http.MethodPost, "/api/articles/missing/comments", bob, AddCommentInput{Body: "nice"}, http.StatusNotFound, nil)
+
+	var list struct {
+		Comments []models.CommentJSON `json:"comments"`
+	}
+	f.do(http.MethodGet, path, "", nil, http.StatusOK, &list)
+	if len(list.Comments) != 1 || list.Comments[0].Body != "nice" || list.Comments[0].Author.Username != "bob" {
+		t.Fatalf("comments %+v", list.Comments)
+	}
+
+	// ids are not in the json
+	comments, err := f.h.Comments.ListByArticle(context.Background(), article.Article.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := comments[0].ID.Hex()
+	f.do(http.MethodDelete, path+"/"+id, ada, nil, http.StatusNotFound, nil)
+	f.do(http.MethodDelete, path+"/not-an-id", bob, nil, http.StatusNotFound, nil)
+	f.do(http.MethodDelete, path+"/"+id, bob, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, path, "", nil, http.StatusOK, &list)
+	if len(list.Comments) != 0 {
+		t.Fatalf("comments after the delete %+v", list.Comments)
+	}
+}
+
+func TestAPIProfilesFeed(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	ada, bob := f.register("ada"), f.register("bob")
+	f.do(http.MethodPost, "/api/articles", ada, CreateArticleInput{Title: "Hello"}, http.StatusOK, nil)
+
+	var profile profileBody
+	f.do(http.MethodGet, "/api/profiles/ada", "", nil, http.StatusOK, &profile)
+	if profile.Profile.Username != "ada" || profile.Profile.Following {
+		t.Fatalf("profile %+v", profile.Profile)
+	}
+	f.do(http.MethodGet, "/api/profiles/nobody", "", nil, http.StatusNotFound, nil)
+
+	var feed articlesBody
+	f.do(http.MethodGet, "/api/feed", bob, nil, http.StatusOK, &feed)
+	if feed.ArticlesCount != 0 {
+		t.Fatalf("feed before following: %+v", feed)
+	}
+
+	f.do(http.MethodPost, "/api/profiles/ada/follow", bob, nil, http.StatusOK, &profile)
+	if !profile.Profile.Following {
+		t.Fatalf("profile after following %+v", profile.Profile)
+	}
+	f.do(http.MethodGet, "/api/profiles/ada", bob, nil, http.StatusOK, &profile)
+	if !profile.Profile.Following {
+		t.Fatalf("profile of a followed user %+v", profile.Profile)
+	}
+	f.do(http.MethodGet, "/api/feed", bob, nil, http.StatusOK, &feed)
+	if feed.ArticlesCount != 1 || feed.Articles[0].Author.Username != "ada" {
+		t.Fatalf("feed after following: %+v", feed)
+	}
+
+	f.do(http.MethodDelete, "/api/profiles/ada/follow", bob, nil, http.StatusOK, &profile)
+	if profile.Profile.Following {
+		t.Fatalf("profile after unfollowing %+v", profile.Profile)
+	}
+	f.do(http.MethodGet, "/api/feed", bob, nil, http.StatusOK, &feed)
+	if feed.ArticlesCount != 0 {
+		t.Fatalf("feed after unfollowing: %+v", feed)
+	}
+}
+
+// promote username to role
+func (f *apiFixture) promote(username string, role models.Role) {
+	f.t.Helper()
+	ctx := context.Background()
+	user, err := f.h.Users.FindByUsername(ctx, username)
+	if err == nil {
+		_, err = f.h.Users.Update(ctx, user.ID, store.UserUpdate{Role: &role})
+	}
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func TestAPIAdminDeleteComment(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	ada, bob := f.register("ada"), f.register("bob")
+	f.promote("ada", models.RoleModerator)
+	var article, other articleBody
+	f.do(http.MethodPost, "/api/articles", ada, CreateArticleInput{Title: "Hello"}, http.StatusOK, &article)
+	f.do(http.MethodPost, "/api/articles", ada, CreateArticleInput{Title: "Other"}, http.StatusOK, &other)
+	f.do(http.MethodPost, "/api/articles/hello/comments", bob, AddCommentInput{Body: "nice"}, http.StatusOK, nil)
+	comments, err := f.h.Comments.ListByArticle(context.Background(), article.Article.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := comments[0].ID.Hex()
+
+	f.do(http.MethodDelete, "/api/admin/articles/hello/comments/"+id, bob, nil, http.StatusForbidden, nil)
+	// the comment is not under other
+	f.do(http.MethodDelete, "/api/admin/articles/other/comments/"+id, ada, nil, http.StatusNotFound, nil)
+	f.do(http.MethodDelete, "/api/admin/articles/hello/comments/"+primitive.NewObjectID().Hex(), ada, nil, http.StatusNotFound, nil)
+	f.do(http.MethodDelete, "/api/admin/articles/hello/comments/"+id, ada, nil, http.StatusOK, nil)
+	f.do(http.MethodDelete, "/api/admin/articles/hello/comments/"+id, ada, nil, http.StatusNotFound, nil)
+
+	entries, _, err := f.h.Audit.List(context.Background(), store.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != models.AuditCommentDeleted || entries[0].Target != id {
+		t.Fatalf("audit %+v", entries)
+	}
+}
+
+func TestAPIAdminUsersLimit(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	admin := f.register("admin")
+	f.promote("admin", models.RoleAdmin)
+	ctx := context.Background()
+	for i := 0; i < maxLimit+10; i++ {
+		user := &models.User{Email: fmt.Sprintf("user%d@example.com", i), Username: fmt.Sprintf("user%d", i)}
+		if err := f.h.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", defaultLimit},
+		{"?limit=0", defaultLimit},
+		{"?limit=-1", defaultLimit},
+		{"?limit=5", 5},
+		{"?limit=100000", maxLimit},
+		{"?limit=5&offset=-5", 5},
+		{"?limit=5&offset=108", 3},
+	}
+	for _, tt := range tests {
+		var body struct {
+			Users      []AdminUserJSON `json:"users"`
+			UsersCount int             `json:"usersCount"`
+		}
+		f.do(http.MethodGet, "/api/admin/users"+tt.query, admin, nil, http.StatusOK, &body)
+		if len(body.Users) != tt.want || body.UsersCount != maxLimit+11 {
+			t.Errorf("%s: %d of %d users, want %d", tt.query, len(body.Users), body.UsersCount, tt.want)
+		}
+	}
+}
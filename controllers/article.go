@@ -2,15 +2,15 @@ package controllers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gosimple/slug"
+	"github.com/jameslahm/conduit-server-gin/events"
 	"github.com/jameslahm/conduit-server-gin/middlewares"
 	"github.com/jameslahm/conduit-server-gin/models"
-	"github.com/jameslahm/conduit-server-gin/utils"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/jameslahm/conduit-server-gin/store"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // GetArticlesArgs args for get articles
@@ -35,10 +35,9 @@ type GetArticlesArgs struct {
 // @param favorited query string false "articles favorted by"
 // @router /articles [get]
 // @success 200 {array} models.Article
-func GetAllArticles(c *gin.Context) {
+func (h *Handler) GetAllArticles(c *gin.Context) {
 	var args GetArticlesArgs
-	args.Limit = 20
-	args.Offset = 0
+	args.Limit = defaultLimit
 
 	if err := c.ShouldBindQuery(&args); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -47,94 +46,47 @@ func GetAllArticles(c *gin.Context) {
 		return
 	}
 
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	articleCollection := client.Database("conduit").Collection("articles")
-	userCollection := client.Database("conduit").Collection("users")
-
-	var query bson.D = bson.D{}
-	var author models.User
+	ctx := c.Request.Context()
+	filter := store.ArticleFilter{Tag: args.Tag}
+	filter.Limit, filter.Offset = page(args.Limit, args.Offset)
 	if args.Author != "" {
-		err := userCollection.FindOne(ctx, bson.M{"username": args.Author}).Decode(&author)
+		author, err := h.Users.FindByUsername(ctx, args.Author)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
+			c.JSON(errorStatus(err), gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		query = append(query, primitive.E{Key: "author", Value: author.ID})
-	}
-	if args.Tag != "" {
-		query = append(query, primitive.E{Key: "tagList", Value: bson.D{{Key: "$in", Value: []string{args.Tag}}}})
+		filter.Authors = []primitive.ObjectID{author.ID}
 	}
 	if args.Favorited != "" {
-		err := userCollection.FindOne(ctx, bson.M{"username": args.Author}).Decode(&author)
+		favoritedBy, err := h.Users.FindByUsername(ctx, args.Favorited)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
+			c.JSON(errorStatus(err), gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		query = append(query, primitive.E{Key: "_id", Value: bson.D{{Key: "$in", Value: author.Favorites}}})
-	}
-
-	matchStage := bson.D{{Key: "$match", Value: query}}
-	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "users"}, {Key: "localField", Value: "author"}, {Key: "foreignField", Value: "_id"}, {Key: "as", Value: "author"}}}}
-	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$author"}, {Key: "preserveNullAndEmptyArrays", Value: false}}}}
-	skipStage := bson.D{{Key: "$skip", Value: args.Offset}}
-	limitStage := bson.D{{Key: "$limit", Value: args.Limit}}
-	cursor, err := articleCollection.Aggregate(ctx, mongo.Pipeline{matchStage, lookupStage, unwindStage, skipStage, limitStage})
-	var articles []models.ArticleWithAuthor
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	err = cursor.All(ctx, &articles)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
+		filter.IDs = append([]primitive.ObjectID{}, favoritedBy.Favorites...)
 	}
 
-	counts, err := articleCollection.CountDocuments(ctx, query)
+	articles, counts, err := h.Articles.List(ctx, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	var loginUser models.User
-	claims, err := middlewares.Authenticate(c)
-	if err == nil {
-		id, err := primitive.ObjectIDFromHex(claims.UserID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-		err = userCollection.FindOne(ctx, bson.M{
-			"_id": id,
-		}).Decode(&loginUser)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-	}
+	loginUser := middlewares.CurrentUser(c)
 	var articlesJSON []models.ArticleJSON = make([]models.ArticleJSON, len(articles))
 	for i, article := range articles {
 		articlesJSON[i].ArticleBase = article.ArticleBase
-		articlesJSON[i].Author = article.Author.ToProfile(&loginUser)
+		articlesJSON[i].Author = article.Author.ToProfile(loginUser)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"articles":      articles,
+		"articles":      articlesJSON,
 		"articlesCount": counts,
 	})
 }
@@ -146,40 +98,12 @@ type GetFeedArgs struct {
 }
 
 // GetFeedArticles get feed articles
-func GetFeedArticles(c *gin.Context) {
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	articleCollection := client.Database("conduit").Collection("articles")
-	userCollection := client.Database("conduit").Collection("users")
-
-	var loginUser models.User
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-	}
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
+func (h *Handler) GetFeedArticles(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
 	var args GetFeedArgs
-	args.Limit = 20
-	args.Offset = 0
+	args.Limit = defaultLimit
 
 	if err := c.ShouldBindQuery(&args); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -188,28 +112,11 @@ func GetFeedArticles(c *gin.Context) {
 		return
 	}
 
-	var query bson.M = make(primitive.M)
-	query["author"] = bson.M{
-		"$in": loginUser.Following,
-	}
-
-	matchStage := bson.D{{Key: "$match", Value: query}}
-	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "users"}, {Key: "localField", Value: "author"}, {Key: "foreignField", Value: "_id"}, {Key: "as", Value: "author"}}}}
-	unwindStage := bson.D{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$author"}, {Key: "preserveNullAndEmptyArrays", Value: false}}}}
-	skipStage := bson.D{{Key: "$skip", Value: args.Offset}}
-	limitStage := bson.D{{Key: "$limit", Value: args.Limit}}
-	cursor, err := articleCollection.Aggregate(ctx, mongo.Pipeline{matchStage, lookupStage, unwindStage, skipStage, limitStage})
-	counts, err := articleCollection.CountDocuments(ctx, query)
+	filter := store.ArticleFilter{Authors: append([]primitive.ObjectID{}, loginUser.Following...)}
+	filter.Limit, filter.Offset = page(args.Limit, args.Offset)
+	articles, counts, err := h.Articles.List(ctx, filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	var articles []models.ArticleWithAuthor
-	err = cursor.All(ctx, &articles)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -218,7 +125,7 @@ func GetFeedArticles(c *gin.Context) {
 	var articlesJSON []models.ArticleJSON = make([]models.ArticleJSON, len(articles))
 	for i, article := range articles {
 		articlesJSON[i].ArticleBase = article.ArticleBase
-		articlesJSON[i].Author = article.Author.ToProfile(&loginUser)
+		articlesJSON[i].Author = article.Author.ToProfile(loginUser)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -228,56 +135,18 @@ func GetFeedArticles(c *gin.Context) {
 }
 
 // GetArticle get single article
-func GetArticle(c *gin.Context) {
-	var loginUser models.User
-	claims, err := middlewares.Authenticate(c)
-
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
-
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
-
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	slug := c.Param("slug")
+func (h *Handler) GetArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
-	var article models.ArticleWithAuthor
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "slug", Value: slug}}}}
-	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "users"}, {Key: "localField", Value: "author"}, {Key: "foreignField", Value: "_id"}, {Key: "as", Value: "author"}}}}
-	cursor, err := articleCollection.Aggregate(ctx, mongo.Pipeline{matchStage, lookupStage})
+	article, author, err := h.article(ctx, c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	for cursor.Next(ctx) {
-		err := cursor.Decode(&article)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-		break
-	}
-	var articleJSON = models.ArticleJSON{ArticleBase: article.ArticleBase, Author: article.Author.ToProfile(&loginUser)}
+	var articleJSON = models.ArticleJSON{ArticleBase: article.ArticleBase, Author: following(author, article.Author, loginUser)}
 	c.JSON(http.StatusOK, gin.H{
 		"article": articleJSON,
 	})
@@ -292,38 +161,9 @@ type CreateArticleInput struct {
 }
 
 // CreateArticle create article
-func CreateArticle(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
-
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
+func (h *Handler) CreateArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
 	var data CreateArticleInput
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -341,13 +181,14 @@ func CreateArticle(c *gin.Context) {
 	article.Author = loginUser.ID
 	article.Slug = slug.Make(data.Title)
 
-	_, err = articleCollection.InsertOne(ctx, article)
-	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
+	if err := h.Articles.Create(ctx, &article); err != nil {
+		c.JSON(validationStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	h.Cache.Delete(ctx, tagsKey)
+	h.Events.Publish(ctx, events.ArticleCreated{Article: article})
 
 	var articleJSON models.ArticleJSON
 	articleJSON.ArticleBase = article.ArticleBase
@@ -360,38 +201,28 @@ func CreateArticle(c *gin.Context) {
 // UpdateArticleInput update article data
 type UpdateArticleInput = CreateArticleInput
 
-// UpdateArticle update article
-func UpdateArticle(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error()})
-		return
+// articleUpdate fields of data to update, empty ones are left untouched
+func articleUpdate(data *UpdateArticleInput) store.ArticleUpdate {
+	var update store.ArticleUpdate
+	if data.Title != "" {
+		update.Title = &data.Title
 	}
-
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
+	if data.Description != "" {
+		update.Description = &data.Description
 	}
-
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
-
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
+	if data.Body != "" {
+		update.Body = &data.Body
 	}
+	if len(data.TagList) != 0 {
+		update.TagList = data.TagList
+	}
+	return update
+}
+
+// UpdateArticle update article
+func (h *Handler) UpdateArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
 	var data UpdateArticleInput
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -401,33 +232,17 @@ func UpdateArticle(c *gin.Context) {
 		return
 	}
 
-	var article models.Article
-	article.Title = data.Title
-	article.Description = data.Description
-	article.Body = data.Body
-	article.TagList = data.TagList
-
-	_, err = articleCollection.UpdateOne(ctx, bson.M{
-		"slug":   c.Param("slug"),
-		"author": loginUser.ID,
-	}, bson.M{
-		"$set": article,
-	})
+	article, err := h.Articles.Update(ctx, c.Param("slug"), loginUser.ID, articleUpdate(&data))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	h.forgetArticle(ctx, article.Slug)
+	h.Events.Publish(ctx, events.ArticleUpdated{Article: *article})
 
 	var articleJSON models.ArticleJSON
-	err = articleCollection.FindOne(ctx, bson.M{"slug": c.Param("slug")}).Decode(&article)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
 	articleJSON.ArticleBase = article.ArticleBase
 	articleJSON.Author = loginUser.ToProfile(nil)
 
@@ -438,132 +253,68 @@ func UpdateArticle(c *gin.Context) {
 }
 
 // DeleteArticle delete article
-func DeleteArticle(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error()})
-		return
-	}
+func (h *Handler) DeleteArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	if err := h.Articles.Delete(ctx, c.Param("slug"), loginUser.ID); err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	h.forgetArticle(ctx, c.Param("slug"))
+	h.Events.Publish(ctx, events.ArticleDeleted{Slug: c.Param("slug"), Author: loginUser.ID})
+	c.JSON(http.StatusOK, gin.H{})
+}
 
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
+// RestoreArticle restore an article deleted within the retention window
+func (h *Handler) RestoreArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
+	deletedAfter := time.Now().Add(-h.Config.Articles.Retention)
+	article, err := h.Articles.Restore(ctx, c.Param("slug"), loginUser.ID, deletedAfter)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	h.forgetArticle(ctx, article.Slug)
 
-	var data UpdateArticleInput
-	if err := c.ShouldBindJSON(&data); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	var article models.Article
-	article.Title = data.Title
-	article.Description = data.Description
-	article.Body = data.Body
-	article.TagList = data.TagList
-
-	_, err = articleCollection.DeleteOne(ctx, bson.M{
-		"slug":   c.Param("slug"),
-		"author": loginUser.ID,
+	var articleJSON models.ArticleJSON
+	articleJSON.ArticleBase = article.ArticleBase
+	articleJSON.Author = loginUser.ToProfile(nil)
+	c.JSON(http.StatusOK, gin.H{
+		"article": articleJSON,
 	})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{})
 }
 
 // FavoriteArticle favorite article
-func FavoriteArticle(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
+func (h *Handler) FavoriteArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
+	article, err := h.Articles.FindBySlug(ctx, c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	var article models.Article
-	err = articleCollection.FindOne(ctx, bson.M{
-		"slug": c.Param("slug"),
-	}).Decode(&article)
+	article, err = h.Articles.Favorite(ctx, article.ID, loginUser.ID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-
-	err = loginUser.Favorite(&article)
-	if err == nil {
-		_, err = userCollection.UpdateOne(ctx, bson.M{
-			"_id": loginUser.ID,
-		}, loginUser)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-		article.FavoritesCount++
-		_, err = articleCollection.UpdateOne(ctx, bson.M{
-			"_id": article.ID,
-		}, article)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-	}
+	h.Cache.Delete(ctx, articleKey(article.Slug))
+	h.Events.Publish(ctx, events.ArticleFavorited{
+		Article: article.ID, Slug: article.Slug, User: loginUser.ID, FavoritesCount: article.FavoritesCount,
+	})
 
 	var articleJSON models.ArticleJSON
 	articleJSON.ArticleBase = article.ArticleBase
@@ -575,72 +326,29 @@ func FavoriteArticle(c *gin.Context) {
 }
 
 // UnFavoriteArticle unfavorite article
-func UnFavoriteArticle(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
+func (h *Handler) UnFavoriteArticle(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
+	article, err := h.Articles.FindBySlug(ctx, c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	var article models.Article
-	err = articleCollection.FindOne(ctx, bson.M{
-		"slug": c.Param("slug"),
-	}).Decode(&article)
+	article, err = h.Articles.Unfavorite(ctx, article.ID, loginUser.ID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-
-	err = loginUser.UnFavorite(&article)
-	if err == nil {
-		_, err = userCollection.UpdateOne(ctx, bson.M{
-			"_id": loginUser.ID,
-		}, loginUser)
-
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-		article.FavoritesCount--
-		_, err = articleCollection.UpdateOne(ctx, bson.M{
-			"_id": article.ID,
-		}, article)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-	}
+	h.Cache.Delete(ctx, articleKey(article.Slug))
+	h.Events.Publish(ctx, events.ArticleUnfavorited{
+		Article: article.ID, Slug: article.Slug, User: loginUser.ID, FavoritesCount: article.FavoritesCount,
+	})
 
 	var articleJSON models.ArticleJSON
 	articleJSON.ArticleBase = article.ArticleBase
@@ -652,19 +360,15 @@ func UnFavoriteArticle(c *gin.Context) {
 }
 
 // GetTags get tas
-func GetTags(c *gin.Context) {
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-
-	articleCollection := client.Database("conduit").Collection("articles")
-	distinctResult, err := articleCollection.Distinct(ctx, "tagList", bson.M{})
+func (h *Handler) GetTags(c *gin.Context) {
+	tags, err := h.tags(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"tags": distinctResult,
+		"tags": tags,
 	})
 }
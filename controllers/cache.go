@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cache keys
+const tagsKey = "tags"
+
+func articleKey(slug string) string {
+	return "article:" + slug
+}
+
+func profileKey(id primitive.ObjectID) string {
+	return "profile:" + id.Hex()
+}
+
+func usernameKey(username string) string {
+	return "username:" + username
+}
+
+// cachedArticle article as cached. The author is kept by id and rendered
+// from the profile cache, so a profile change never has to find the
+// articles of its user.
+type cachedArticle struct {
+	models.ArticleBase
+	Author primitive.ObjectID `json:"authorId"`
+}
+
+// getJSON decode cached key into v, an undecodable entry is dropped
+func (h *Handler) getJSON(ctx context.Context, key string, v interface{}) bool {
+	data, ok := h.Cache.Get(ctx, key)
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		h.Cache.Delete(ctx, key)
+		return false
+	}
+	return true
+}
+
+func (h *Handler) setJSON(ctx context.Context, key string, v interface{}) {
+	if data, err := json.Marshal(v); err == nil {
+		h.Cache.Set(ctx, key, data)
+	}
+}
+
+// tags distinct tags through the cache
+func (h *Handler) tags(ctx context.Context) ([]string, error) {
+	var tags []string
+	if h.getJSON(ctx, tagsKey, &tags) {
+		return tags, nil
+	}
+	tags, err := h.Articles.Tags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.setJSON(ctx, tagsKey, tags)
+	return tags, nil
+}
+
+// article article of slug and its author through the cache, the profile
+// has following unset
+func (h *Handler) article(ctx context.Context, slug string) (*cachedArticle, models.Profile, error) {
+	var article cachedArticle
+	if h.getJSON(ctx, articleKey(slug), &article) {
+		profile, err := h.profile(ctx, article.Author)
+		if errors.Is(err, store.ErrNotFound) {
+			// the author is gone and their articles with them
+			h.Cache.Delete(ctx, articleKey(slug))
+		}
+		return &article, profile, err
+	}
+	found, err := h.Articles.FindBySlugWithAuthor(ctx, slug)
+	if err != nil {
+		return nil, models.Profile{}, err
+	}
+	article = cachedArticle{ArticleBase: found.ArticleBase, Author: found.Author.ID}
+	profile := found.Author.ToProfile(nil)
+	h.setJSON(ctx, articleKey(slug), article)
+	h.setJSON(ctx, profileKey(found.Author.ID), profile)
+	return &article, profile, nil
+}
+
+// profile profile of user id through the cache, following unset
+func (h *Handler) profile(ctx context.Context, id primitive.ObjectID) (models.Profile, error) {
+	var profile models.Profile
+	if h.getJSON(ctx, profileKey(id), &profile) {
+		return profile, nil
+	}
+	user, err := h.Users.FindByID(ctx, id)
+	if err != nil {
+		return profile, err
+	}
+	profile = user.ToProfile(nil)
+	h.setJSON(ctx, profileKey(id), profile)
+	return profile, nil
+}
+
+// profileByUsername id and profile of username through the cache. A
+// cached id whose profile carries another username is stale, the user was
+// renamed, and is looked up again.
+func (h *Handler) profileByUsername(ctx context.Context, username string) (primitive.ObjectID, models.Profile, error) {
+	var id primitive.ObjectID
+	if h.getJSON(ctx, usernameKey(username), &id) {
+		profile, err := h.profile(ctx, id)
+		if err == nil && profile.Username == username {
+			return id, profile, nil
+		}
+		h.Cache.Delete(ctx, usernameKey(username))
+	}
+	user, err := h.Users.FindByUsername(ctx, username)
+	if err != nil {
+		return id, models.Profile{}, err
+	}
+	profile := user.ToProfile(nil)
+	h.setJSON(ctx, usernameKey(username), user.ID)
+	h.setJSON(ctx, profileKey(user.ID), profile)
+	return user.ID, profile, nil
+}
+
+// following profile of user id as seen by loginUser
+func following(profile models.Profile, id primitive.ObjectID, loginUser *models.User) models.Profile {
+	profile.Following = loginUser != nil && utils.IndexOf(loginUser.Following, id) != -1
+	return profile
+}
+
+// forgetArticle evict article of slug and the tags it may have changed
+func (h *Handler) forgetArticle(ctx context.Context, slug string) {
+	h.Cache.Delete(ctx, articleKey(slug), tagsKey)
+}
+
+// forgetUser evict profile of user id
+func (h *Handler) forgetUser(ctx context.Context, id primitive.ObjectID) {
+	h.Cache.Delete(ctx, profileKey(id))
+}
@@ -4,12 +4,10 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/events"
 	"github.com/jameslahm/conduit-server-gin/middlewares"
 	"github.com/jameslahm/conduit-server-gin/models"
-	"github.com/jameslahm/conduit-server-gin/utils"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // AddCommentInput add comment data
@@ -18,39 +16,9 @@ type AddCommentInput struct {
 }
 
 // AddComment add comment
-func AddComment(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	articleCollection := client.Database("conduit").Collection("articles")
-	commentCollection := client.Database("conduit").Collection("comments")
-
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
+func (h *Handler) AddComment(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
 	var data AddCommentInput
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -60,12 +28,9 @@ func AddComment(c *gin.Context) {
 		return
 	}
 
-	var article models.Article
-	err = articleCollection.FindOne(ctx, bson.M{
-		"slug": c.Param("slug"),
-	}).Decode(&article)
+	article, err := h.Articles.FindBySlug(ctx, c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -75,19 +40,17 @@ func AddComment(c *gin.Context) {
 	comment.Body = data.Body
 	comment.Article = article.ID
 	comment.Author = loginUser.ID
-	insertResult, err := commentCollection.InsertOne(ctx, comment)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := h.Comments.Create(ctx, &comment); err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-
-	comment.ID = insertResult.InsertedID.(primitive.ObjectID)
+	h.Events.Publish(ctx, events.CommentAdded{Comment: comment, ArticleSlug: article.Slug})
 
 	var commentJSON models.CommentJSON
 	commentJSON.CommentBase = comment.CommentBase
+	commentJSON.Author = loginUser.ToProfile(nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"comment": commentJSON,
@@ -95,105 +58,44 @@ func AddComment(c *gin.Context) {
 }
 
 // DeleteComment delete comment
-func DeleteComment(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
+func (h *Handler) DeleteComment(c *gin.Context) {
+	ctx := c.Request.Context()
+	loginUser := middlewares.CurrentUser(c)
 
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	commentCollection := client.Database("conduit").Collection("comments")
-
-	var loginUser models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&loginUser)
+	commentID, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	_, err = commentCollection.DeleteOne(ctx, bson.M{
-		"_id":    c.Param("id"),
-		"author": id,
-	})
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+	if err := h.Comments.Delete(ctx, commentID, loginUser.ID); err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	h.Events.Publish(ctx, events.CommentDeleted{ID: commentID, Author: loginUser.ID, ArticleSlug: c.Param("slug")})
 	c.JSON(http.StatusOK, gin.H{})
 }
 
 // GetComments get comments
-func GetComments(c *gin.Context) {
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-
-	var loginUser models.User
-	claims, err := middlewares.Authenticate(c)
-	if err == nil {
-		id, err := primitive.ObjectIDFromHex(claims.UserID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-		err = userCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&loginUser)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": err.Error(),
-			})
-			return
-		}
-	}
+func (h *Handler) GetComments(c *gin.Context) {
+	ctx := c.Request.Context()
 
-	var article models.Article
-	articleCollection := client.Database("conduit").Collection("articles")
-	err = articleCollection.FindOne(ctx, bson.M{
-		"slug": c.Param("slug"),
-	}).Decode(&article)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
+	loginUser := middlewares.CurrentUser(c)
 
-	commentCollection := client.Database("conduit").Collection("comments")
-	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "article", Value: article.ID}}}}
-	lookupStage := bson.D{{Key: "$lookup", Value: bson.D{{
-		Key:   "from",
-		Value: "users",
-	}, {Key: "localField", Value: "author"}, {Key: "foreignField", Value: "_id"}, {Key: "as", Value: "author"}}}}
-	cursor, err := commentCollection.Aggregate(ctx, mongo.Pipeline{matchStage, lookupStage})
+	article, err := h.Articles.FindBySlug(ctx, c.Param("slug"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	var comments []models.CommentWithAuthor
-	err = cursor.All(ctx, comments)
+
+	comments, err := h.Comments.ListByArticle(ctx, article.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -201,7 +103,7 @@ func GetComments(c *gin.Context) {
 	var commentsJSON = make([]models.CommentJSON, len(comments))
 	for i := range comments {
 		commentsJSON[i].CommentBase = comments[i].CommentBase
-		commentsJSON[i].Author = comments[i].Author.ToProfile(&loginUser)
+		commentsJSON[i].Author = comments[i].Author.ToProfile(loginUser)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"comments": commentsJSON,
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jameslahm/conduit-server-gin/cache"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/events"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"github.com/jameslahm/conduit-server-gin/mail"
+	"github.com/jameslahm/conduit-server-gin/oidc"
+	"github.com/jameslahm/conduit-server-gin/password"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+// Handler http handlers backed by a store
+type Handler struct {
+	Users    store.UserStore
+	Articles store.ArticleStore
+	Comments store.CommentStore
+	// RefreshTokens server side refresh tokens
+	RefreshTokens store.RefreshTokenStore
+	// Revocations revoked access tokens
+	Revocations store.RevocationStore
+	// PasswordResets mailed password reset tokens
+	PasswordResets store.PasswordResetStore
+	// TwoFactors TOTP second factors
+	TwoFactors store.TwoFactorStore
+	// Identities accounts at login providers linked to users
+	Identities store.IdentityStore
+	// AccessTokens personal access tokens
+	AccessTokens store.AccessTokenStore
+	// Audit log of privileged actions
+	Audit store.AuditStore
+	// LoginAttempts failed logins per account and client ip
+	LoginAttempts store.LoginAttemptStore
+	// Passwords hash new passwords and check stored ones
+	Passwords *password.Hasher
+	// PasswordPolicy rules of new passwords
+	PasswordPolicy *password.Policy
+	// Providers OpenID Connect login providers by name
+	Providers map[string]*oidc.Provider
+	// Keys sign access tokens
+	Keys   *keys.Ring
+	Mailer mail.Mailer
+	Config *config.Config
+	// Cache tags, articles and profiles
+	Cache cache.Cache
+	// Events domain events published after successful writes
+	Events *events.Bus
+}
+
+// NewHandler create handler using store and cfg, signing tokens with ring.
+// It fails when the banned passwords cannot be read.
+func NewHandler(s store.Store, cfg *config.Config, ring *keys.Ring) (*Handler, error) {
+	var c cache.Cache = &cache.Nop{}
+	if cfg.Cache.Size > 0 {
+		c = cache.NewLRU(cfg.Cache.Size, cfg.Cache.TTL)
+	}
+	policy, err := passwordPolicy(&cfg.Auth.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		Users:    s.Users(),
+		Articles: s.Articles(),
+		Comments: s.Comments(),
+
+		RefreshTokens: s.RefreshTokens(),
+		Revocations:   s.Revocations(),
+		Keys:          ring,
+
+		PasswordResets: s.PasswordResets(),
+		TwoFactors:     s.TwoFactors(),
+		Identities:     s.Identities(),
+		AccessTokens:   s.AccessTokens(),
+		Audit:          s.Audit(),
+		LoginAttempts:  s.LoginAttempts(),
+		Passwords:      PasswordHasher(cfg),
+		PasswordPolicy: policy,
+		Providers:      newProviders(&cfg.Auth.OIDC),
+		Mailer:         newMailer(&cfg.Mail),
+		Config:         cfg,
+		Cache:          c,
+		Events:         events.NewBus(),
+	}, nil
+}
+
+// passwordPolicy policy of new passwords under cfg
+func passwordPolicy(cfg *config.PasswordConfig) (*password.Policy, error) {
+	banned, err := password.LoadBanned(cfg.BannedFile)
+	if err != nil {
+		return nil, err
+	}
+	policy := &password.Policy{MinLength: cfg.MinLength, MaxLength: cfg.MaxLength, Banned: banned}
+	if cfg.Algorithm == password.Bcrypt {
+		policy.MaxBytes = password.BcryptMaxBytes
+	}
+	return policy, nil
+}
+
+// newMailer mailer of the configured driver
+func newMailer(cfg *config.MailConfig) mail.Mailer {
+	if cfg.Driver == "smtp" {
+		return &mail.SMTP{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.From,
+		}
+	}
+	return &mail.Outbox{Dir: cfg.Outbox, From: cfg.From}
+}
+
+// PasswordHasher hasher of new passwords under cfg
+func PasswordHasher(cfg *config.Config) *password.Hasher {
+	argon2 := cfg.Auth.Password.Argon2
+	return &password.Hasher{
+		Algorithm:  cfg.Auth.Password.Algorithm,
+		BcryptCost: cfg.Auth.BcryptCost,
+		Argon2: password.Argon2Params{
+			Time:    uint32(argon2.Time),
+			Memory:  uint32(argon2.Memory),
+			Threads: uint8(argon2.Threads),
+		},
+	}
+}
+
+// CommentPolicy what cascades do with comments under cfg
+func CommentPolicy(cfg *config.Config) store.CommentPolicy {
+	if cfg.Articles.ArchiveComments {
+		return store.ArchiveComments
+	}
+	return store.DeleteComments
+}
+
+const (
+	// defaultLimit items of a listing page when the query names no limit
+	defaultLimit = 20
+	// maxLimit most items of a listing page
+	maxLimit = 100
+)
+
+// page limit and offset of a listing query, the limit clamped to 1 through
+// maxLimit so no query loads a whole collection, and a negative offset is 0
+func page(limit int, offset int) (int, int) {
+	if limit < 1 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// errorStatus http status for store error
+func errorStatus(err error) int {
+	var duplicate *store.DuplicateError
+	switch {
+	case errors.As(err, &duplicate):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// validationStatus http status for store error on user input
+func validationStatus(err error) int {
+	if errors.Is(err, store.ErrUnavailable) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusUnprocessableEntity
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+var (
+	// errAccountLocked the account failed too many logins, it unlocks on
+	// its own or by an admin
+	errAccountLocked = errors.New("error: account locked after too many failed logins")
+	// errTooManyAttempts logins come too fast after failures of the
+	// account, or the client ip failed too often
+	errTooManyAttempts = errors.New("error: too many failed logins, retry later")
+)
+
+// refusal login turned away before checking the password
+type refusal struct {
+	// err errAccountLocked or errTooManyAttempts
+	err   error
+	until time.Time
+}
+
+// backoff wait after failures of an account, doubling from BackoffBase
+// up to BackoffMax
+func backoff(cfg *config.LockoutConfig, failures int) time.Duration {
+	if cfg.BackoffBase <= 0 || failures < 1 {
+		return 0
+	}
+	delay := cfg.BackoffBase
+	for i := 1; i < failures && delay < cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > cfg.BackoffMax {
+		delay = cfg.BackoffMax
+	}
+	return delay
+}
+
+// reserveIP count a login from ip before its password is checked,
+// refusing it when ip failed too often. Counting first keeps concurrent
+// logins from all passing the check.
+func (h *Handler) reserveIP(ctx context.Context, ip string, now time.Time) (*refusal, error) {
+	cfg := &h.Config.Auth.Lockout
+	if cfg.IPThreshold == 0 {
+		return nil, nil
+	}
+	attempt, err := h.LoginAttempts.Fail(ctx, models.IPAttemptKey(ip), now, cfg.Duration)
+	if err != nil || attempt.Failures <= cfg.IPThreshold {
+		return nil, err
+	}
+	return &refusal{err: errTooManyAttempts, until: attempt.LastFailure.Add(cfg.Duration)}, nil
+}
+
+// reserveAccount count a login as user before its password is checked,
+// refusing it when the account is locked or the backoff after its last
+// failure has not passed. Refused logins count as well, so retrying too
+// early only prolongs the wait.
+func (h *Handler) reserveAccount(ctx context.Context, user *models.User, now time.Time) (*refusal, error) {
+	cfg := &h.Config.Auth.Lockout
+	attempt, err := h.LoginAttempts.Fail(ctx, models.AccountAttemptKey(user.ID), now, cfg.Duration)
+	if err != nil {
+		return nil, err
+	}
+	// failures before this login
+	failures := attempt.Failures - 1
+	if cfg.Threshold > 0 && failures >= cfg.Threshold {
+		return &refusal{err: errAccountLocked, until: attempt.LastFailure.Add(cfg.Duration)}, nil
+	}
+	if attempt.PreviousFailure != nil && now.Before(attempt.PreviousFailure.Add(backoff(cfg, failures))) {
+		return &refusal{err: errTooManyAttempts, until: attempt.LastFailure.Add(backoff(cfg, attempt.Failures))}, nil
+	}
+	return nil, nil
+}
+
+// reserveCode count a two-factor code of user before it is checked,
+// refusing it when the account is locked. Codes share the failures of
+// passwords, so a known password does not buy unlimited code guesses. There
+// is no backoff, the password step was counted a moment ago.
+func (h *Handler) reserveCode(ctx context.Context, user *models.User, now time.Time) (*refusal, error) {
+	cfg := &h.Config.Auth.Lockout
+	attempt, err := h.LoginAttempts.Fail(ctx, models.AccountAttemptKey(user.ID), now, cfg.Duration)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Threshold > 0 && attempt.Failures-1 >= cfg.Threshold {
+		return &refusal{err: errAccountLocked, until: attempt.LastFailure.Add(cfg.Duration)}, nil
+	}
+	return nil, nil
+}
+
+// loggedIn forget the failed logins of user and ip once tokens were issued
+func (h *Handler) loggedIn(c *gin.Context, user *models.User) {
+	if err := h.LoginAttempts.Reset(c.Request.Context(), models.AccountAttemptKey(user.ID), models.IPAttemptKey(c.ClientIP())); err != nil {
+		c.Error(err)
+	}
+}
+
+// refuse answer 423 to locked accounts and 429 otherwise, with the
+// seconds until the next attempt may succeed
+func refuse(c *gin.Context, r *refusal) {
+	seconds := int(math.Ceil(time.Until(r.until).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	status := http.StatusTooManyRequests
+	if r.err == errAccountLocked {
+		status = http.StatusLocked
+	}
+	c.JSON(status, gin.H{
+		"error": r.err.Error(),
+	})
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store/memory"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginFixture handler on a memory store with the user ada, password
+// "correct horse"
+type loginFixture struct {
+	t      *testing.T
+	h      *Handler
+	router *gin.Engine
+	ada    *models.User
+}
+
+func newLoginFixture(t *testing.T, configure func(cfg *config.Config)) *loginFixture {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test"
+	cfg.Auth.Password.Algorithm = "bcrypt"
+	cfg.Auth.BcryptCost = bcrypt.MinCost
+	if configure != nil {
+		configure(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := keys.New([]byte(cfg.Auth.JWTSecret), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(memory.New(), cfg, ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := h.Passwords.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ada := &models.User{Email: "ada@example.com", Username: "ada", Password: hash}
+	if err := h.Users.Create(context.Background(), ada); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.ForwardedByClientIP = cfg.Server.TrustProxy
+	router.POST("/api/users/login", h.Login)
+	return &loginFixture{t: t, h: h, router: router, ada: ada}
+}
+
+// post data as json to path from the client at X-Forwarded-For forwarded,
+// if any, and return the response
+func (f *loginFixture) post(path string, data interface{}, forwarded string) *httptest.ResponseRecorder {
+	body, err := json.Marshal(data)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if forwarded != "" {
+		r.Header.Set("X-Forwarded-For", forwarded)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, r)
+	return w
+}
+
+// login as email with password, see post
+func (f *loginFixture) login(email string, password string, forwarded string) *httptest.ResponseRecorder {
+	return f.post("/api/users/login", LoginInput{Email: email, Password: password}, forwarded)
+}
+
+// expect status of w, and a Retry-After of at least one second on refusals
+func (f *loginFixture) expect(w *httptest.ResponseRecorder, status int, what string) {
+	f.t.Helper()
+	if w.Code != status {
+		f.t.Fatalf("%s: %d %s, want %d", what, w.Code, w.Body, status)
+	}
+	if status == http.StatusLocked || status == http.StatusTooManyRequests {
+		if seconds, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || seconds < 1 {
+			f.t.Fatalf("%s: Retry-After %q", what, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestLoginLocksAccount(t *testing.T) {
+	f := newLoginFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.Threshold = 3
+		cfg.Auth.Lockout.BackoffBase = 0
+	})
+	for i := 0; i < 3; i++ {
+		f.expect(f.login("ada@example.com", "wrong", ""), http.StatusUnprocessableEntity, "wrong password")
+	}
+	w := f.login("ada@example.com", "correct horse", "")
+	f.expect(w, http.StatusLocked, "right password of a locked account")
+	if seconds, _ := strconv.Atoi(w.Header().Get("Retry-After")); seconds > int((15 * time.Minute).Seconds()) {
+		t.Fatalf("Retry-After %d beyond the lockout duration", seconds)
+	}
+
+	// an unlock forgets the failures
+	if err := f.h.LoginAttempts.Reset(context.Background(), models.AccountAttemptKey(f.ada.ID)); err != nil {
+		t.Fatal(err)
+	}
+	f.expect(f.login("ada@example.com", "correct horse", ""), http.StatusOK, "after unlock")
+}
+
+func TestLoginSuccessResetsCounters(t *testing.T) {
+	f := newLoginFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.Threshold = 3
+		cfg.Auth.Lockout.BackoffBase = 0
+	})
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 2; i++ {
+			f.expect(f.login("ada@example.com", "wrong", ""), http.StatusUnprocessableEntity, "wrong password")
+		}
+		f.expect(f.login("ada@example.com", "correct horse", ""), http.StatusOK, "right password")
+	}
+	ctx := context.Background()
+	for _, key := range []string{models.AccountAttemptKey(f.ada.ID), models.IPAttemptKey("192.0.2.1")} {
+		if attempt, err := f.h.LoginAttempts.Find(ctx, key); err == nil {
+			t.Fatalf("%s still counts %d failures", key, attempt.Failures)
+		}
+	}
+}
+
+func TestLoginBacksOff(t *testing.T) {
+	f := newLoginFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.BackoffBase = time.Minute
+		cfg.Auth.Lockout.BackoffMax = 4 * time.Minute
+	})
+	f.expect(f.login("ada@example.com", "wrong", ""), http.StatusUnprocessableEntity, "wrong password")
+	w := f.login("ada@example.com", "correct horse", "")
+	f.expect(w, http.StatusTooManyRequests, "login during backoff")
+	// the early login counted as the second failure, doubling the wait
+	if seconds, _ := strconv.Atoi(w.Header().Get("Retry-After")); seconds <= 60 || seconds > 120 {
+		t.Fatalf("Retry-After %d, want within 60 and 120", seconds)
+	}
+}
+
+func TestLoginConcurrentGuessesLock(t *testing.T) {
+	const threshold, guesses = 3, 30
+	f := newLoginFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.Threshold = threshold
+		cfg.Auth.Lockout.BackoffBase = 0
+	})
+	var wg sync.WaitGroup
+	statuses := make(chan int, guesses)
+	for i := 0; i < guesses; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- f.login("ada@example.com", "wrong", "").Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusUnprocessableEntity] != threshold || counts[http.StatusLocked] != guesses-threshold {
+		t.Fatalf("statuses %v, want %d password checks and the rest locked", counts, threshold)
+	}
+}
+
+func TestLoginThrottlesIP(t *testing.T) {
+	for _, trustProxy := range []bool{false, true} {
+		f := newLoginFixture(t, func(cfg *config.Config) {
+			cfg.Server.TrustProxy = trustProxy
+			cfg.Auth.Lockout.IPThreshold = 2
+		})
+		// unknown emails count against the ip only
+		f.expect(f.login("nobody1@example.com", "x", "198.51.100.1"), http.StatusUnprocessableEntity, "unknown email")
+		f.expect(f.login("nobody2@example.com", "x", "198.51.100.2"), http.StatusUnprocessableEntity, "unknown email")
+		w := f.login("ada@example.com", "correct horse", "198.51.100.3")
+		// without a trusted proxy every login comes from the remote address
+		// of the request, whatever X-Forwarded-For claims
+		if trustProxy {
+			f.expect(w, http.StatusOK, "login from another forwarded ip")
+		} else {
+			f.expect(w, http.StatusTooManyRequests, "login with a spoofed X-Forwarded-For")
+		}
+	}
+}
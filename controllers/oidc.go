@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gosimple/slug"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/events"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/oidc"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+// maxUsernameLength longest generated username, before a clash suffix
+const maxUsernameLength = 30
+
+// usernameAttempts usernames tried when provisioning, the first without a
+// suffix
+const usernameAttempts = 8
+
+var (
+	// errProviderUnknown no provider of that name is configured
+	errProviderUnknown = errors.New("error: unknown login provider")
+	// errOIDCSessionInvalid malformed, expired or used login session, or a
+	// state that does not match it
+	errOIDCSessionInvalid = errors.New("error: login session invalid")
+	// errOIDCRejected the provider refused the code or sent an id token
+	// that does not verify
+	errOIDCRejected = errors.New("error: login rejected by provider")
+	// errOIDCNoEmail provisioning needs an email, ask for the email scope
+	errOIDCNoEmail = errors.New("error: login provider sent no email")
+	// errOIDCUnverifiedAccount the account of the email never verified it,
+	// so who owns it is unknown
+	errOIDCUnverifiedAccount = errors.New("error: account with this email has not verified it")
+)
+
+// newProviders providers of cfg by name
+func newProviders(cfg *config.OIDCConfig) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	for _, p := range cfg.Providers {
+		scopes := p.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+		providers[p.Name] = &oidc.Provider{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       scopes,
+			Client:       &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return providers
+}
+
+// oidcStatus http status for errors of the oidc endpoints, anything the
+// provider did not answer properly is a bad gateway
+func oidcStatus(err error) int {
+	switch err {
+	case errProviderUnknown:
+		return http.StatusNotFound
+	case errOIDCSessionInvalid, errOIDCRejected:
+		return http.StatusUnauthorized
+	case errOIDCNoEmail, errOIDCUnverifiedAccount:
+		return http.StatusUnprocessableEntity
+	}
+	if status := errorStatus(err); status != http.StatusInternalServerError {
+		return status
+	}
+	return http.StatusBadGateway
+}
+
+// OIDCProviders names of the configured login providers
+func (h *Handler) OIDCProviders(c *gin.Context) {
+	names := []string{}
+	for name := range h.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	c.JSON(http.StatusOK, gin.H{
+		"providers": names,
+	})
+}
+
+// OIDCAuthorize start a login at the provider. The frontend sends the user
+// to the authorization url and keeps the session for the callback.
+func (h *Handler) OIDCAuthorize(c *gin.Context) {
+	name := c.Param("provider")
+	provider, ok := h.Providers[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": errProviderUnknown.Error(),
+		})
+		return
+	}
+
+	claims := models.OIDCClaims{Provider: name}
+	var err error
+	if claims.State, err = models.RandomToken(16); err == nil {
+		if claims.Nonce, err = models.RandomToken(16); err == nil {
+			claims.Verifier, err = oidc.GenerateVerifier()
+		}
+	}
+	var authURL, session string
+	if err == nil {
+		authURL, err = provider.AuthCodeURL(c.Request.Context(), claims.State, claims.Nonce, claims.Verifier)
+	}
+	if err == nil {
+		session, err = models.GenerateOIDCToken(claims, h.Keys, h.Config.Auth.OIDC.SessionTTL)
+	}
+	if err != nil {
+		c.JSON(oidcStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"authorizationURL": authURL,
+		"session":          session,
+	})
+}
+
+// OIDCCallbackInput oidc callback post data, the code and state the
+// provider sent the user back with and the session of OIDCAuthorize
+type OIDCCallbackInput struct {
+	Code    string `json:"code" binding:"required"`
+	State   string `json:"state" binding:"required"`
+	Session string `json:"session" binding:"required"`
+}
+
+// OIDCCallback finish a login at the provider. The identity signs in the
+// user it is linked to, else the user of its verified email, else a new
+// user. Users with 2fa get a challenge as from Login.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	var data OIDCCallbackInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	name := c.Param("provider")
+	provider, ok := h.Providers[name]
+	var err error
+	if !ok {
+		err = errProviderUnknown
+	}
+	var claims *models.OIDCClaims
+	if err == nil {
+		claims, err = h.oidcSession(ctx, name, data)
+	}
+	var token *oidc.IDToken
+	if err == nil {
+		token, err = provider.Exchange(ctx, data.Code, claims.Verifier, claims.Nonce)
+		if errors.Is(err, oidc.ErrRejected) {
+			c.Error(err)
+			err = errOIDCRejected
+		}
+	}
+	var user *models.User
+	var created bool
+	if err == nil {
+		user, created, err = h.federate(ctx, name, token)
+	}
+	if err != nil {
+		c.JSON(oidcStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if created {
+		h.Events.Publish(ctx, events.UserRegistered{User: user.ID, Username: user.Username, Email: user.Email})
+		if !user.EmailVerified {
+			if err := h.mailVerification(ctx, user); err != nil {
+				c.Error(err)
+			}
+		}
+	}
+	h.signIn(c, user)
+}
+
+// oidcSession claims of the login session of data at provider name, which
+// can be used once
+func (h *Handler) oidcSession(ctx context.Context, name string, data OIDCCallbackInput) (*models.OIDCClaims, error) {
+	claims, err := models.VerifyOIDCToken(data.Session, h.Keys)
+	if err != nil || claims.Provider != name || subtle.ConstantTimeCompare([]byte(claims.State), []byte(data.State)) != 1 {
+		return nil, errOIDCSessionInvalid
+	}
+	found, err := h.Revocations.Find(ctx, claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) > 0 {
+		return nil, errOIDCSessionInvalid
+	}
+	// the code is single use at the provider anyway, this keeps a session
+	// from being replayed with a code of another login
+	if err := h.revokeToken(ctx, &models.JwtClaims{StandardClaims: claims.StandardClaims}); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// federate user of the identity of token at provider, linking it to the
+// user of its email when the provider verified that, or to a new user.
+// created reports a new user.
+func (h *Handler) federate(ctx context.Context, provider string, token *oidc.IDToken) (user *models.User, created bool, err error) {
+	identity, err := h.Identities.Find(ctx, provider, token.Subject)
+	if err == nil {
+		user, err = h.Users.FindByID(ctx, identity.User)
+		return user, false, err
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, false, err
+	}
+	if token.Email == "" {
+		return nil, false, errOIDCNoEmail
+	}
+
+	// an unverified email may belong to anyone, it only ever gets a new
+	// user, which fails when the email is taken
+	if token.EmailVerified {
+		user, err = h.Users.FindByEmail(ctx, token.Email)
+		switch {
+		case err == nil && !user.EmailVerified:
+			return nil, false, errOIDCUnverifiedAccount
+		case errors.Is(err, store.ErrNotFound):
+			err = nil
+			user = nil
+		case err != nil:
+			return nil, false, err
+		}
+	}
+	if user == nil {
+		if user, err = h.provision(ctx, token); err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+	err = h.Identities.Create(ctx, &models.Identity{
+		Provider:  provider,
+		Subject:   token.Subject,
+		User:      user.ID,
+		Email:     token.Email,
+		CreatedAt: time.Now(),
+	})
+	return user, created, err
+}
+
+// provision create the user of token without a password, it can be set
+// through a password reset. The username comes from the token and gets a
+// random suffix while it is taken.
+func (h *Handler) provision(ctx context.Context, token *oidc.IDToken) (*models.User, error) {
+	base := slug.Make(token.PreferredUsername)
+	if base == "" {
+		base = slug.Make(token.Name)
+	}
+	if base == "" {
+		base = slug.Make(strings.SplitN(token.Email, "@", 2)[0])
+	}
+	if base == "" {
+		base = "user"
+	}
+	if len(base) > maxUsernameLength {
+		base = strings.Trim(base[:maxUsernameLength], "-")
+	}
+
+	username := base
+	for attempt := 1; ; attempt++ {
+		user := &models.User{
+			Email:         token.Email,
+			Username:      username,
+			EmailVerified: bool(token.EmailVerified),
+		}
+		err := h.Users.Create(ctx, user)
+		if err == nil {
+			return user, nil
+		}
+		var duplicate *store.DuplicateError
+		if !errors.As(err, &duplicate) || duplicate.Field != "username" || attempt == usernameAttempts {
+			return nil, err
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return nil, err
+		}
+		username = fmt.Sprintf("%s-%04d", base, n)
+	}
+}
@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"github.com/jameslahm/conduit-server-gin/mail"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/oidc/oidctest"
+	"github.com/jameslahm/conduit-server-gin/store/memory"
+)
+
+// recordingMailer mailer keeping what it sends
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []*mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// oidcFixture handler on a memory store with the stand-in provider corp
+type oidcFixture struct {
+	t        *testing.T
+	h        *Handler
+	router   *gin.Engine
+	provider *oidctest.Provider
+	mailer   *recordingMailer
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	gin.SetMode(gin.TestMode)
+	provider, err := oidctest.NewProvider("conduit", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(provider.Close)
+
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = "test"
+	cfg.Auth.OIDC.Providers = []config.OIDCProviderConfig{{
+		Name:         "corp",
+		Issuer:       provider.URL,
+		ClientID:     "conduit",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:4100/oidc/corp",
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := keys.New([]byte(cfg.Auth.JWTSecret), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHandler(memory.New(), cfg, ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailer := &recordingMailer{}
+	h.Mailer = mailer
+
+	router := gin.New()
+	router.POST("/api/users/oidc/:provider", h.OIDCAuthorize)
+	router.POST("/api/users/oidc/:provider/callback", h.OIDCCallback)
+	return &oidcFixture{t: t, h: h, router: router, provider: provider, mailer: mailer}
+}
+
+// post body to path and decode the response
+func (f *oidcFixture) post(path string, body interface{}) (int, map[string]interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data)))
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		f.t.Fatalf("POST %s: %d %s", path, w.Code, w.Body)
+	}
+	return w.Code, response
+}
+
+// authorize start a login at corp and sign user in there, returning the
+// callback post data
+func (f *oidcFixture) authorize(user oidctest.User) OIDCCallbackInput {
+	status, response := f.post("/api/users/oidc/corp", nil)
+	if status != http.StatusOK {
+		f.t.Fatalf("authorize: %d %v", status, response)
+	}
+	f.provider.SignIn(user)
+	code, state, err := f.provider.Authorize(response["authorizationURL"].(string))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return OIDCCallbackInput{Code: code, State: state, Session: response["session"].(string)}
+}
+
+// login sign in as user at corp and return the status and response of
+// the callback
+func (f *oidcFixture) login(user oidctest.User) (int, map[string]interface{}) {
+	return f.post("/api/users/oidc/corp/callback", f.authorize(user))
+}
+
+// loggedIn user of a successful callback response
+func (f *oidcFixture) loggedIn(status int, response map[string]interface{}) map[string]interface{} {
+	f.t.Helper()
+	if status != http.StatusOK || response["user"] == nil {
+		f.t.Fatalf("login: %d %v", status, response)
+	}
+	user := response["user"].(map[string]interface{})
+	if user["token"] == "" || user["refreshToken"] == "" {
+		f.t.Fatalf("login without tokens: %v", user)
+	}
+	return user
+}
+
+func TestOIDCProvisionsUsers(t *testing.T) {
+	f := newOIDCFixture(t)
+	ada := oidctest.User{Subject: "1", Email: "ada@example.com", EmailVerified: true, PreferredUsername: "Ada"}
+
+	user := f.loggedIn(f.login(ada))
+	if user["username"] != "ada" || user["email"] != "ada@example.com" || user["emailVerified"] != true {
+		t.Fatalf("provisioned %v", user)
+	}
+	if len(f.mailer.sent) != 0 {
+		t.Fatalf("verified email got a verification mail")
+	}
+
+	// the identity is linked, a changed email at the provider is the same
+	// user
+	ada.Email = "ada@new.example.com"
+	if again := f.loggedIn(f.login(ada)); again["username"] != "ada" || again["email"] != "ada@example.com" {
+		t.Fatalf("second login as %v", again)
+	}
+
+	// another subject with the same preferred username gets a suffix, and
+	// a verification mail for its unverified email
+	other := f.loggedIn(f.login(oidctest.User{Subject: "2", Email: "ada2@example.com", PreferredUsername: "ada"}))
+	if username := other["username"].(string); !strings.HasPrefix(username, "ada-") || len(username) != len("ada-0000") {
+		t.Fatalf("username %q for a taken preferred username", username)
+	}
+	if other["emailVerified"] != false || len(f.mailer.sent) != 1 || f.mailer.sent[0].To != "ada2@example.com" {
+		t.Fatalf("unverified email %v, mails %v", other["emailVerified"], f.mailer.sent)
+	}
+
+	// without a username claim the name or the email is used
+	named := f.loggedIn(f.login(oidctest.User{Subject: "3", Email: "x@example.com", Name: "Grace Hopper"}))
+	mailed := f.loggedIn(f.login(oidctest.User{Subject: "4", Email: "linus.t@example.com"}))
+	if named["username"] != "grace-hopper" || mailed["username"] != "linus-t" {
+		t.Fatalf("usernames %v and %v", named["username"], mailed["username"])
+	}
+
+	if status, response := f.login(oidctest.User{Subject: "5"}); status != http.StatusUnprocessableEntity {
+		t.Fatalf("no email: %d %v", status, response)
+	}
+}
+
+func TestOIDCLinksByVerifiedEmail(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+	local := &models.User{Email: "bob@example.com", Username: "bobby", EmailVerified: true}
+	unverified := &models.User{Email: "eve@example.com", Username: "eve"}
+	for _, user := range []*models.User{local, unverified} {
+		if err := f.h.Users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an email the provider did not verify never links
+	status, response := f.login(oidctest.User{Subject: "b", Email: "bob@example.com", PreferredUsername: "bob"})
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("unverified provider email: %d %v", status, response)
+	}
+
+	user := f.loggedIn(f.login(oidctest.User{Subject: "b", Email: "bob@example.com", EmailVerified: true, PreferredUsername: "bob"}))
+	if user["username"] != "bobby" {
+		t.Fatalf("linked to %v", user)
+	}
+	identity, err := f.h.Identities.Find(ctx, "corp", "b")
+	if err != nil || identity.User != local.ID {
+		t.Fatalf("identity %+v, %v", identity, err)
+	}
+
+	// nor does an account that never verified its email
+	status, response = f.login(oidctest.User{Subject: "e", Email: "eve@example.com", EmailVerified: true})
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("unverified account: %d %v", status, response)
+	}
+}
+
+func TestOIDCSession(t *testing.T) {
+	f := newOIDCFixture(t)
+	user := oidctest.User{Subject: "1", Email: "ada@example.com", EmailVerified: true}
+
+	input := f.authorize(user)
+	wrongState := input
+	wrongState.State = "forged"
+	if status, response := f.post("/api/users/oidc/corp/callback", wrongState); status != http.StatusUnauthorized {
+		t.Fatalf("forged state: %d %v", status, response)
+	}
+	if status, response := f.post("/api/users/oidc/other/callback", input); status != http.StatusNotFound {
+		t.Fatalf("unknown provider: %d %v", status, response)
+	}
+	f.loggedIn(f.post("/api/users/oidc/corp/callback", input))
+	if status, response := f.post("/api/users/oidc/corp/callback", input); status != http.StatusUnauthorized {
+		t.Fatalf("session used twice: %d %v", status, response)
+	}
+
+	// a code of one login does not redeem with the session of another
+	first, second := f.authorize(user), f.authorize(user)
+	first.Session, first.State = second.Session, second.State
+	if status, response := f.post("/api/users/oidc/corp/callback", first); status != http.StatusUnauthorized {
+		t.Fatalf("code of another session: %d %v", status, response)
+	}
+}
+
+func TestOIDCTwoFactor(t *testing.T) {
+	f := newOIDCFixture(t)
+	user := oidctest.User{Subject: "1", Email: "ada@example.com", EmailVerified: true}
+	f.loggedIn(f.login(user))
+	identity, err := f.h.Identities.Find(context.Background(), "corp", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.h.TwoFactors.Save(context.Background(), &models.TwoFactor{User: identity.User, Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, response := f.login(user)
+	if status != http.StatusOK || response["challenge"] == nil || response["user"] != nil {
+		t.Fatalf("login with 2fa: %d %v", status, response)
+	}
+}
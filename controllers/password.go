@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/mail"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+// errResetInvalid unknown, used or expired password reset token
+var errResetInvalid = errors.New("error: password reset token invalid")
+
+// ForgotPasswordInput forgot password post data
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// ResetPasswordInput reset password post data
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// mailPasswordReset mail user a new reset token, unless the limit of
+// resets mailed to their address within the window was reached
+func (h *Handler) mailPasswordReset(ctx context.Context, user *models.User) error {
+	cfg := h.Config.Auth.PasswordReset
+	now := time.Now()
+	sent, err := h.PasswordResets.CountSince(ctx, user.Email, now.Add(-cfg.Window))
+	if err != nil {
+		return err
+	}
+	if sent >= int64(cfg.Limit) {
+		return nil
+	}
+
+	token, err := models.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	err = h.PasswordResets.Create(ctx, &models.PasswordReset{
+		Hash:      models.HashToken(token),
+		User:      user.ID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(cfg.TTL),
+	})
+	if err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Conduit password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"someone asked to reset the password of your Conduit account. Open this link within %d minutes to choose a new one:\n\n"+
+			"%s%s\n\n"+
+			"If that was not you, ignore this mail and your password stays as it is.\n",
+			user.Username, int(cfg.TTL.Minutes()), cfg.URL, token),
+	})
+}
+
+// ForgotPassword mail a password reset link to the account of an email.
+// The answer is the same whether the email is registered or not, and
+// requests over the rate limit of the address are dropped silently.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var data ForgotPasswordInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.Users.FindByEmail(ctx, data.Email)
+	if err == nil {
+		err = h.mailPasswordReset(ctx, user)
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{})
+}
+
+// ResetPassword set a new password with a mailed reset token, ending every
+// session and personal access token of the user
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var data ResetPasswordInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	hash := models.HashToken(data.Token)
+	reset, err := h.PasswordResets.Find(ctx, hash)
+	if err == nil && time.Now().After(reset.ExpiresAt) {
+		err = errResetInvalid
+	}
+	var user *models.User
+	if err == nil {
+		user, err = h.Users.FindByID(ctx, reset.User)
+	}
+	// a rejected password leaves the token for another try
+	if err == nil {
+		if err := h.PasswordPolicy.Check(data.Password, user.Username, user.Email); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	var password string
+	if err == nil {
+		password, err = h.Passwords.Hash(data.Password)
+	}
+	if err == nil {
+		_, err = h.PasswordResets.Use(ctx, hash)
+	}
+	if err == nil {
+		user, err = h.Users.Update(ctx, reset.User, store.UserUpdate{Password: &password})
+	}
+	if err == nil {
+		h.forgetUser(ctx, user.ID)
+		err = h.revokeUser(ctx, user.ID)
+	}
+	if err == nil {
+		// whoever had the password may have minted tokens with it
+		err = h.AccessTokens.DeleteUser(ctx, user.ID)
+	}
+	if err == nil {
+		// the owner proved themselves, a lockout no longer stands
+		err = h.LoginAttempts.Reset(ctx, models.AccountAttemptKey(user.ID))
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if errors.Is(err, store.ErrNotFound) || err == errResetInvalid {
+			status, err = http.StatusBadRequest, errResetInvalid
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+func TestResetPassword(t *testing.T) {
+	f := newAPIFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.BackoffBase = 0
+	})
+	f.register("ada")
+	f.do(http.MethodPost, "/api/users/password/forgot", "", ForgotPasswordInput{Email: "ada@example.com"}, http.StatusAccepted, nil)
+	token, _ := f.mailed("ada")
+
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: "not-a-token", Password: "battery staple"},
+		http.StatusBadRequest, nil)
+	// a rejected password leaves the token for another try
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: token, Password: "short"},
+		http.StatusUnprocessableEntity, nil)
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: token, Password: "battery staple"},
+		http.StatusOK, nil)
+	f.do(http.MethodPost, "/api/users/login", "", LoginInput{Email: "ada@example.com", Password: "correct horse"}, http.StatusUnprocessableEntity, nil)
+	f.do(http.MethodPost, "/api/users/login", "", LoginInput{Email: "ada@example.com", Password: "battery staple"}, http.StatusOK, nil)
+
+	// a token resets once
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: token, Password: "correct horse"},
+		http.StatusBadRequest, nil)
+
+	// and using one voids the others still open
+	for i := 0; i < 2; i++ {
+		f.do(http.MethodPost, "/api/users/password/forgot", "", ForgotPasswordInput{Email: "ada@example.com"}, http.StatusAccepted, nil)
+		if i == 0 {
+			token, _ = f.mailed("ada")
+		}
+	}
+	second, _ := f.mailed("ada")
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: second, Password: "correct horse"},
+		http.StatusOK, nil)
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: token, Password: "battery staple"},
+		http.StatusBadRequest, nil)
+}
+
+func TestResetPasswordExpired(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	ctx := context.Background()
+	user, err := f.h.Users.FindByUsername(ctx, "ada")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := models.RandomToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	err = f.h.PasswordResets.Create(ctx, &models.PasswordReset{
+		Hash: models.HashToken(token), User: user.ID, Email: user.Email,
+		CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: token, Password: "battery staple"},
+		http.StatusBadRequest, nil)
+	f.do(http.MethodPost, "/api/users/login", "", LoginInput{Email: "ada@example.com", Password: "correct horse"}, http.StatusOK, nil)
+}
+
+func TestForgotPasswordLimit(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	f.register("bob")
+	limit := f.h.Config.Auth.PasswordReset.Limit
+
+	// unknown emails get the same answer and no mail
+	f.do(http.MethodPost, "/api/users/password/forgot", "", ForgotPasswordInput{Email: "eve@example.com"}, http.StatusAccepted, nil)
+	if _, mails := f.mailed("eve"); mails != 0 {
+		t.Fatalf("%d mails to an unknown email", mails)
+	}
+	for i := 0; i < limit+2; i++ {
+		f.do(http.MethodPost, "/api/users/password/forgot", "", ForgotPasswordInput{Email: "ada@example.com"}, http.StatusAccepted, nil)
+	}
+	// registering mailed the verification link
+	last, mails := f.mailed("ada")
+	if mails != 1+limit {
+		t.Fatalf("%d reset mails, want %d", mails-1, limit)
+	}
+	// the limit is per email, dropped requests mint no tokens
+	f.do(http.MethodPost, "/api/users/password/forgot", "", ForgotPasswordInput{Email: "bob@example.com"}, http.StatusAccepted, nil)
+	if _, mails := f.mailed("bob"); mails != 2 {
+		t.Fatalf("%d mails to bob, want 2", mails)
+	}
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: last, Password: "battery staple"},
+		http.StatusOK, nil)
+}
+
+func TestResetPasswordRevokes(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	bob := f.register("bob")
+	session := f.session("ada")
+	pat := f.accessToken(session.Token, CreateAccessTokenInput{Name: "ci", Scopes: models.Scopes})
+	f.do(http.MethodPost, "/api/users/password/forgot", "", ForgotPasswordInput{Email: "ada@example.com"}, http.StatusAccepted, nil)
+	token, _ := f.mailed("ada")
+	// revocations have millisecond precision
+	time.Sleep(2 * time.Millisecond)
+
+	f.do(http.MethodPost, "/api/users/password/reset", "", ResetPasswordInput{Token: token, Password: "battery staple"},
+		http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", session.Token, nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: session.RefreshToken}, http.StatusUnauthorized, nil)
+	f.do(http.MethodGet, "/api/user", pat.Token, nil, http.StatusUnauthorized, nil)
+	// other users keep theirs
+	f.do(http.MethodGet, "/api/user", bob, nil, http.StatusOK, nil)
+
+	// a session of the new password works
+	var body userBody
+	f.do(http.MethodPost, "/api/users/login", "", LoginInput{Email: "ada@example.com", Password: "battery staple"}, http.StatusOK, &body)
+	f.do(http.MethodGet, "/api/user", body.User.Token, nil, http.StatusOK, nil)
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/middlewares"
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+// Routes register the api of h on r
+func (h *Handler) Routes(r gin.IRouter) {
+	r.GET("/.well-known/jwks.json", h.JWKS)
+
+	api := r.Group("/api")
+	// auth takes sessions only, scoped also personal access tokens with
+	// all of scopes
+	auth := middlewares.RequireAuth(h.Keys, h.Users, h.Revocations, h.AccessTokens)
+	scoped := func(scopes ...string) gin.HandlerFunc {
+		return middlewares.RequireAuth(h.Keys, h.Users, h.Revocations, h.AccessTokens, scopes...)
+	}
+	optionalAuth := middlewares.OptionalAuth(h.Keys, h.Users, h.Revocations, h.AccessTokens)
+	verified := middlewares.RequireVerifiedEmail(h.Config.Auth.EmailVerification.Required)
+
+	api.POST("/users/login", h.Login)
+	api.POST("/users/login/2fa", h.LoginTwoFactor)
+	api.GET("/users/oidc", h.OIDCProviders)
+	api.POST("/users/oidc/:provider", h.OIDCAuthorize)
+	api.POST("/users/oidc/:provider/callback", h.OIDCCallback)
+	api.POST("/users", h.Register)
+	api.POST("/users/refresh", h.Refresh)
+	api.POST("/users/logout", auth, h.Logout)
+	api.POST("/users/logout/all", auth, h.LogoutAll)
+	api.POST("/users/password/forgot", h.ForgotPassword)
+	api.POST("/users/password/reset", h.ResetPassword)
+	api.GET("/users/verify", h.VerifyEmail)
+	api.GET("/user", scoped(models.ScopeProfileRead), h.GetCurrentUser)
+	api.PUT("/user", auth, h.UpdateUser)
+	api.DELETE("/user", auth, h.DeleteCurrentUser)
+	api.POST("/user/verify", auth, h.ResendVerification)
+	api.POST("/user/2fa/enroll", auth, h.EnrollTwoFactor)
+	api.POST("/user/2fa/confirm", auth, h.ConfirmTwoFactor)
+	api.DELETE("/user/2fa", auth, h.DisableTwoFactor)
+	api.GET("/user/tokens", auth, h.GetAccessTokens)
+	api.POST("/user/tokens", auth, h.CreateAccessToken)
+	api.DELETE("/user/tokens/:id", auth, h.DeleteAccessToken)
+
+	api.GET("/profiles/:username", optionalAuth, h.GetProfile)
+	api.POST("/profiles/:username/follow", scoped(models.ScopeProfileWrite), h.FollowUser)
+	api.DELETE("/profiles/:username/follow", scoped(models.ScopeProfileWrite), h.UnFollowUser)
+
+	api.GET("/articles", optionalAuth, h.GetAllArticles)
+	api.GET("/articles/:slug", optionalAuth, h.GetArticle)
+	api.GET("/feed", scoped(models.ScopeProfileRead), h.GetFeedArticles)
+	api.POST("/articles", scoped(models.ScopeArticlesWrite), verified, h.CreateArticle)
+	api.PUT("/articles/:slug", scoped(models.ScopeArticlesWrite), h.UpdateArticle)
+	api.DELETE("/articles/:slug", scoped(models.ScopeArticlesWrite), h.DeleteArticle)
+	api.POST("/articles/:slug/restore", scoped(models.ScopeArticlesWrite), h.RestoreArticle)
+
+	api.POST("/articles/:slug/comments", scoped(models.ScopeCommentsWrite), verified, h.AddComment)
+	api.GET("/articles/:slug/comments", optionalAuth, h.GetComments)
+	api.DELETE("/articles/:slug/comments/:id", scoped(models.ScopeCommentsWrite), h.DeleteComment)
+
+	api.POST("/articles/:slug/favorite", scoped(models.ScopeArticlesWrite), h.FavoriteArticle)
+	api.DELETE("/articles/:slug/favorite", scoped(models.ScopeArticlesWrite), h.UnFavoriteArticle)
+
+	api.GET("/tags", h.GetTags)
+
+	// admin takes sessions only, each route needs a permission of the role
+	admin := api.Group("/admin", auth)
+	moderate := middlewares.RequirePermission(models.PermissionModerate)
+	manageUsers := middlewares.RequirePermission(models.PermissionManageUsers)
+	admin.GET("/users", manageUsers, h.AdminGetUsers)
+	admin.PUT("/users/:username/role", manageUsers, h.AdminSetRole)
+	admin.POST("/users/:username/unlock", manageUsers, h.AdminUnlockUser)
+	admin.PUT("/articles/:slug", moderate, h.AdminUpdateArticle)
+	admin.DELETE("/articles/:slug", moderate, h.AdminDeleteArticle)
+	admin.DELETE("/articles/:slug/comments/:id", moderate, h.AdminDeleteComment)
+	admin.GET("/audit", middlewares.RequirePermission(models.PermissionReadAudit), h.AdminGetAudit)
+	admin.GET("/stats/cache", middlewares.RequirePermission(models.PermissionReadStats), h.AdminGetCacheStats)
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/middlewares"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errRefreshInvalid unknown, expired, reused or revoked refresh token
+var errRefreshInvalid = errors.New("error: refresh token invalid")
+
+// issueTokens set a new access token and refresh token on user. The
+// refresh token joins family, or starts a new family when empty.
+func (h *Handler) issueTokens(ctx context.Context, user *models.User, family string) error {
+	var err error
+	if user.Token, err = models.GenerateJwtToken(user.ID, h.Keys, h.Config.Auth.AccessTokenTTL); err != nil {
+		return err
+	}
+	if family == "" {
+		if family, err = models.RandomToken(16); err != nil {
+			return err
+		}
+	}
+	refreshToken, err := models.RandomToken(32)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	err = h.RefreshTokens.Create(ctx, &models.RefreshToken{
+		Hash:      models.HashToken(refreshToken),
+		Family:    family,
+		User:      user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.Config.Auth.RefreshTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+	user.RefreshToken = refreshToken
+	return nil
+}
+
+// RefreshInput refresh post data
+type RefreshInput struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// Refresh exchange a refresh token for a new access token and refresh
+// token. Presenting a token twice revokes its whole family, one of the
+// holders must have stolen it.
+func (h *Handler) Refresh(c *gin.Context) {
+	var data RefreshInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := h.RefreshTokens.Use(ctx, models.HashToken(data.RefreshToken))
+	if errors.Is(err, store.ErrTokenReused) {
+		err = h.RefreshTokens.RevokeFamily(ctx, token.Family)
+		if err == nil {
+			err = errRefreshInvalid
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) || err == nil && time.Now().After(token.ExpiresAt) {
+		err = errRefreshInvalid
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if err == errRefreshInvalid {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.Users.FindByID(ctx, token.User)
+	if err != nil {
+		status := errorStatus(err)
+		if errors.Is(err, store.ErrNotFound) {
+			status, err = http.StatusUnauthorized, errRefreshInvalid
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := h.issueTokens(ctx, user, token.Family); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// revokeToken revoke the access token of claims until it expires
+func (h *Handler) revokeToken(ctx context.Context, claims *models.JwtClaims) error {
+	return h.Revocations.Revoke(ctx, &models.Revocation{
+		ID:        claims.Id,
+		RevokedAt: time.Now(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+}
+
+// revokeUser revoke every access token and refresh token of user. Access
+// tokens issued from now on are not affected.
+func (h *Handler) revokeUser(ctx context.Context, user primitive.ObjectID) error {
+	// tokens carry their issue time in milliseconds, one issued in the
+	// millisecond of the revocation survives it
+	now := time.Now().Truncate(time.Millisecond)
+	err := h.Revocations.Revoke(ctx, &models.Revocation{
+		ID:        models.UserRevocationID(user),
+		RevokedAt: now,
+		ExpiresAt: now.Add(h.Config.Auth.AccessTokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+	return h.RefreshTokens.RevokeUser(ctx, user)
+}
+
+// LogoutInput logout post data, the refresh token is optional
+type LogoutInput struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Logout revoke the access token of the request, and the family of the
+// refresh token when one of the current user is given
+func (h *Handler) Logout(c *gin.Context) {
+	var data LogoutInput
+	if err := c.ShouldBindJSON(&data); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.revokeToken(ctx, middlewares.CurrentClaims(c))
+	if err == nil && data.RefreshToken != "" {
+		// found rather than used, a refresh token of someone else is left
+		// alone instead of being spent
+		var token *models.RefreshToken
+		token, err = h.RefreshTokens.Find(ctx, models.HashToken(data.RefreshToken))
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			err = nil
+		case err == nil && token.User == middlewares.CurrentUser(c).ID:
+			err = h.RefreshTokens.RevokeFamily(ctx, token.Family)
+		}
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// LogoutAll revoke every access token and refresh token of the current
+// user
+func (h *Handler) LogoutAll(c *gin.Context) {
+	ctx := c.Request.Context()
+	err := h.revokeUser(ctx, middlewares.CurrentUser(c).ID)
+	if err == nil {
+		// in case it was issued within the millisecond of the revocation
+		err = h.revokeToken(ctx, middlewares.CurrentClaims(c))
+	}
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// JWKS public keys verifying access tokens, for other services
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.Keys.JWKS())
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+// refreshToken of the user in the response w
+func refreshToken(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		User models.User `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.User.RefreshToken == "" {
+		t.Fatalf("no refresh token: %s", w.Body)
+	}
+	return body.User.RefreshToken
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	f := newLoginFixture(t, nil)
+	f.router.POST("/api/users/refresh", f.h.Refresh)
+	refresh := func(token string) *httptest.ResponseRecorder {
+		return f.post("/api/users/refresh", RefreshInput{RefreshToken: token}, "")
+	}
+
+	w := f.login("ada@example.com", "correct horse", "")
+	f.expect(w, http.StatusOK, "login")
+	first := refreshToken(t, w)
+	// a second session of ada, another family
+	w = f.login("ada@example.com", "correct horse", "")
+	f.expect(w, http.StatusOK, "second login")
+	other := refreshToken(t, w)
+
+	w = refresh(first)
+	f.expect(w, http.StatusOK, "first refresh")
+	second := refreshToken(t, w)
+	w = refresh(second)
+	f.expect(w, http.StatusOK, "second refresh")
+	newest := refreshToken(t, w)
+
+	// the rotated token comes back, whoever holds the newest one may
+	// have stolen it
+	f.expect(refresh(first), http.StatusUnauthorized, "replayed rotated token")
+	f.expect(refresh(newest), http.StatusUnauthorized, "newest token of the revoked family")
+	f.expect(refresh(second), http.StatusUnauthorized, "middle token of the revoked family")
+
+	f.expect(refresh(other), http.StatusOK, "token of another family")
+	f.expect(refresh("unknown"), http.StatusUnauthorized, "unknown token")
+}
+
+func TestLogout(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	f.register("bob")
+	first, second, bob := f.session("ada"), f.session("ada"), f.session("bob")
+
+	// a refresh token of someone else is neither spent nor revoked
+	f.do(http.MethodPost, "/api/users/logout", bob.Token, LogoutInput{RefreshToken: first.RefreshToken}, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", bob.Token, nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: first.RefreshToken}, http.StatusOK, nil)
+
+	f.do(http.MethodPost, "/api/users/logout", second.Token, LogoutInput{RefreshToken: second.RefreshToken}, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", second.Token, nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: second.RefreshToken}, http.StatusUnauthorized, nil)
+	// the other session of ada goes on
+	f.do(http.MethodGet, "/api/user", first.Token, nil, http.StatusOK, nil)
+
+	// the refresh token is optional
+	f.do(http.MethodPost, "/api/users/logout", first.Token, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", first.Token, nil, http.StatusUnauthorized, nil)
+	f.do(http.MethodPost, "/api/users/logout", "", nil, http.StatusUnauthorized, nil)
+}
+
+func TestLogoutAll(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	f.register("bob")
+	first, second, bob := f.session("ada"), f.session("ada"), f.session("bob")
+	// tokens issued within the millisecond of the revocation survive it
+	time.Sleep(2 * time.Millisecond)
+
+	f.do(http.MethodPost, "/api/users/logout/all", first.Token, nil, http.StatusOK, nil)
+	for _, session := range []models.User{first, second} {
+		f.do(http.MethodGet, "/api/user", session.Token, nil, http.StatusUnauthorized, nil)
+		f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: session.RefreshToken}, http.StatusUnauthorized, nil)
+	}
+	f.do(http.MethodGet, "/api/user", bob.Token, nil, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", f.session("ada").Token, nil, http.StatusOK, nil)
+}
+
+func TestCredentialChangeRevokes(t *testing.T) {
+	newPassword := "battery staple"
+	for name, update := range map[string]UpdateUserInput{
+		"password": {Password: &newPassword},
+		"email":    {Email: "ada@example.org"},
+	} {
+		f := newAPIFixture(t, nil)
+		f.register("ada")
+		current, other := f.session("ada"), f.session("ada")
+		pat := f.accessToken(current.Token, CreateAccessTokenInput{Name: "ci", Scopes: []string{models.ScopeProfileRead}})
+		time.Sleep(2 * time.Millisecond)
+
+		var body struct {
+			User models.User `json:"user"`
+		}
+		f.do(http.MethodPut, "/api/user", current.Token, update, http.StatusOK, &body)
+		for _, session := range []models.User{current, other} {
+			f.do(http.MethodGet, "/api/user", session.Token, nil, http.StatusUnauthorized, nil)
+			f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: session.RefreshToken}, http.StatusUnauthorized, nil)
+		}
+		f.do(http.MethodGet, "/api/user", pat.Token, nil, http.StatusUnauthorized, nil)
+		// the caller got a new session
+		if body.User.Token == "" || body.User.RefreshToken == "" {
+			t.Fatalf("%s change: no new tokens in %+v", name, body.User)
+		}
+		f.do(http.MethodGet, "/api/user", body.User.Token, nil, http.StatusOK, nil)
+		f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: body.User.RefreshToken}, http.StatusOK, nil)
+	}
+
+	// other changes keep every session
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	current, other := f.session("ada"), f.session("ada")
+	f.do(http.MethodPut, "/api/user", current.Token, UpdateUserInput{Bio: "counts"}, http.StatusOK, nil)
+	f.do(http.MethodGet, "/api/user", other.Token, nil, http.StatusOK, nil)
+	f.do(http.MethodPost, "/api/users/refresh", "", RefreshInput{RefreshToken: other.RefreshToken}, http.StatusOK, nil)
+}
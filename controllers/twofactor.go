@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/middlewares"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/totp"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recoveryCodeCount recovery codes handed out when 2fa is enabled
+const recoveryCodeCount = 10
+
+var (
+	// errCodeInvalid wrong, reused or missing two-factor code
+	errCodeInvalid = errors.New("error: two-factor code invalid")
+	// errChallengeInvalid malformed, expired or already used login
+	// challenge
+	errChallengeInvalid = errors.New("error: login challenge invalid")
+	// errTwoFactorEnabled enrolling while 2fa is on
+	errTwoFactorEnabled = errors.New("error: two-factor authentication already enabled")
+	// errTwoFactorDisabled confirming or disabling without 2fa
+	errTwoFactorDisabled = errors.New("error: two-factor authentication not enabled")
+)
+
+// TwoFactorInput second factor post data, a code of the authenticator app
+// or one of the recovery codes
+type TwoFactorInput struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// LoginTwoFactorInput second login step post data
+type LoginTwoFactorInput struct {
+	Challenge string `json:"challenge" binding:"required"`
+	TwoFactorInput
+}
+
+// verifySecondFactor check data against twoFactor and use up the code,
+// errCodeInvalid when it does not match or was used before
+func (h *Handler) verifySecondFactor(ctx context.Context, twoFactor *models.TwoFactor, data TwoFactorInput) error {
+	var err error
+	switch {
+	case data.Code != "":
+		step, ok := totp.Validate(twoFactor.Secret, data.Code, time.Now())
+		if !ok {
+			return errCodeInvalid
+		}
+		err = h.TwoFactors.UseStep(ctx, twoFactor.User, step)
+	case data.RecoveryCode != "" && twoFactor.Enabled:
+		// recovery codes only exist once enrollment was confirmed
+		err = h.TwoFactors.UseRecoveryCode(ctx, twoFactor.User, models.HashRecoveryCode(twoFactor.User, data.RecoveryCode))
+	default:
+		return errCodeInvalid
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return errCodeInvalid
+	}
+	return err
+}
+
+// twoFactorStatus http status for errors of the 2fa endpoints
+func twoFactorStatus(err error) int {
+	switch err {
+	case errCodeInvalid, errTwoFactorEnabled, errTwoFactorDisabled:
+		return http.StatusUnprocessableEntity
+	}
+	return errorStatus(err)
+}
+
+// EnrollTwoFactor start 2fa enrollment of the current user with a new
+// secret, replacing one that was never confirmed
+func (h *Handler) EnrollTwoFactor(c *gin.Context) {
+	ctx := c.Request.Context()
+	user := middlewares.CurrentUser(c)
+	twoFactor, err := h.TwoFactors.Find(ctx, user.ID)
+	switch {
+	case err == nil && twoFactor.Enabled:
+		err = errTwoFactorEnabled
+	case err == nil || errors.Is(err, store.ErrNotFound):
+		twoFactor = &models.TwoFactor{User: user.ID, CreatedAt: time.Now()}
+		if twoFactor.Secret, err = totp.GenerateSecret(); err == nil {
+			err = h.TwoFactors.Save(ctx, twoFactor)
+		}
+	}
+	if err != nil {
+		c.JSON(twoFactorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":     twoFactor.Secret,
+		"otpauthURI": totp.URI(h.Config.Auth.TwoFactor.Issuer, user.Email, twoFactor.Secret),
+	})
+}
+
+// ConfirmTwoFactor enable 2fa of the current user with a first code of
+// the enrolled secret. The recovery codes are only shown in the response.
+func (h *Handler) ConfirmTwoFactor(c *gin.Context) {
+	var data TwoFactorInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user := middlewares.CurrentUser(c)
+	twoFactor, err := h.TwoFactors.Find(ctx, user.ID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		err = errTwoFactorDisabled
+	case err == nil && twoFactor.Enabled:
+		err = errTwoFactorEnabled
+	case err == nil:
+		err = h.verifySecondFactor(ctx, twoFactor, data)
+	}
+	var codes []string
+	if err == nil {
+		codes, err = h.enableTwoFactor(ctx, twoFactor)
+	}
+	if err != nil {
+		c.JSON(twoFactorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"recoveryCodes": codes,
+	})
+}
+
+// enableTwoFactor enable twoFactor with new recovery codes and return them
+func (h *Handler) enableTwoFactor(ctx context.Context, twoFactor *models.TwoFactor) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	twoFactor.RecoveryCodes = make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := models.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i], twoFactor.RecoveryCodes[i] = code, models.HashRecoveryCode(twoFactor.User, code)
+	}
+	// the confirming code was used, Find again for its step
+	current, err := h.TwoFactors.Find(ctx, twoFactor.User)
+	if err != nil {
+		return nil, err
+	}
+	twoFactor.LastStep = current.LastStep
+	twoFactor.Enabled = true
+	if err := h.TwoFactors.Save(ctx, twoFactor); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor turn 2fa of the current user off, which takes a code
+// or a recovery code
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	var data TwoFactorInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user := middlewares.CurrentUser(c)
+	twoFactor, err := h.TwoFactors.Find(ctx, user.ID)
+	switch {
+	case errors.Is(err, store.ErrNotFound) || err == nil && !twoFactor.Enabled:
+		err = errTwoFactorDisabled
+	case err == nil:
+		err = h.verifySecondFactor(ctx, twoFactor, data)
+	}
+	if err == nil {
+		err = h.TwoFactors.Delete(ctx, user.ID)
+	}
+	if err != nil {
+		c.JSON(twoFactorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// loginChallenge user of a login challenge, which can be used once
+func (h *Handler) loginChallenge(ctx context.Context, ss string) (*models.User, error) {
+	claims, err := models.VerifyChallengeToken(ss, h.Keys)
+	if err != nil {
+		return nil, errChallengeInvalid
+	}
+	id, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return nil, errChallengeInvalid
+	}
+	// a password reset or log out everywhere voids pending challenges too
+	if r, err := middlewares.Revoked(ctx, claims, id, h.Revocations); err != nil || r {
+		if err == nil {
+			err = errChallengeInvalid
+		}
+		return nil, err
+	}
+	// every challenge gets one attempt, a wrong code takes the password
+	// again. Both steps count against the lockout of the account, see
+	// reserveCode.
+	if err := h.revokeToken(ctx, claims); err != nil {
+		return nil, err
+	}
+	user, err := h.Users.FindByID(ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		err = errChallengeInvalid
+	}
+	return user, err
+}
+
+// LoginTwoFactor second login step, exchange the challenge Login returned
+// and a code for tokens. A wrong code counts as a failed login of the
+// account, a right one clears its failures.
+func (h *Handler) LoginTwoFactor(c *gin.Context) {
+	var data LoginTwoFactorInput
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.loginChallenge(ctx, data.Challenge)
+	var refused *refusal
+	if err == nil {
+		refused, err = h.reserveCode(ctx, user, time.Now())
+	}
+	if err == nil && refused != nil {
+		refuse(c, refused)
+		return
+	}
+	var twoFactor *models.TwoFactor
+	if err == nil {
+		twoFactor, err = h.TwoFactors.Find(ctx, user.ID)
+	}
+	switch {
+	case errors.Is(err, store.ErrNotFound) || err == nil && !twoFactor.Enabled:
+		// turned off since the password was checked
+		err = errChallengeInvalid
+	case err == nil:
+		err = h.verifySecondFactor(ctx, twoFactor, data.TwoFactorInput)
+	}
+	if err == nil {
+		err = h.issueTokens(ctx, user, "")
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if err == errChallengeInvalid || err == errCodeInvalid {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	h.loggedIn(c, user)
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
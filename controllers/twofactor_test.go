@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/totp"
+)
+
+// twoFactorFixture login fixture where ada confirmed 2fa
+type twoFactorFixture struct {
+	*loginFixture
+	secret        string
+	recoveryCodes []string
+}
+
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	f := newLoginFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.BackoffBase = 0
+	})
+	f.router.POST("/api/users/login/2fa", f.h.LoginTwoFactor)
+	ctx := context.Background()
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	twoFactor := &models.TwoFactor{User: f.ada.ID, Secret: secret, CreatedAt: time.Now()}
+	if err := f.h.TwoFactors.Save(ctx, twoFactor); err != nil {
+		t.Fatal(err)
+	}
+	codes, err := f.h.enableTwoFactor(ctx, twoFactor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &twoFactorFixture{loginFixture: f, secret: secret, recoveryCodes: codes}
+}
+
+// challenge of a login of ada with the right password
+func (f *twoFactorFixture) challenge() string {
+	f.t.Helper()
+	w := f.login("ada@example.com", "correct horse", "")
+	f.expect(w, http.StatusOK, "password step")
+	var body struct {
+		Challenge string       `json:"challenge"`
+		User      *models.User `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		f.t.Fatal(err)
+	}
+	if body.Challenge == "" || body.User != nil {
+		f.t.Fatalf("password step of a 2fa login: %s", w.Body)
+	}
+	return body.Challenge
+}
+
+// code current code of ada
+func (f *twoFactorFixture) code() string {
+	f.t.Helper()
+	code, err := totp.Code(f.secret, totp.Step(time.Now()))
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return code
+}
+
+// second answer challenge with data
+func (f *twoFactorFixture) second(challenge string, data TwoFactorInput) *httptest.ResponseRecorder {
+	return f.post("/api/users/login/2fa", LoginTwoFactorInput{Challenge: challenge, TwoFactorInput: data}, "")
+}
+
+func TestLoginTwoFactorCode(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	code := f.code()
+	w := f.second(f.challenge(), TwoFactorInput{Code: code})
+	f.expect(w, http.StatusOK, "current code")
+	var body struct {
+		User models.User `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.User.Token == "" || body.User.RefreshToken == "" {
+		t.Fatalf("no tokens: %s", w.Body)
+	}
+
+	// a code is accepted once, even with a new challenge
+	f.expect(f.second(f.challenge(), TwoFactorInput{Code: code}), http.StatusUnauthorized, "used code")
+	f.expect(f.second(f.challenge(), TwoFactorInput{Code: "000000"}), http.StatusUnauthorized, "wrong code")
+}
+
+func TestLoginTwoFactorChallengeOnce(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	challenge := f.challenge()
+	f.expect(f.second(challenge, TwoFactorInput{RecoveryCode: f.recoveryCodes[0]}), http.StatusOK, "first use of the challenge")
+	f.expect(f.second(challenge, TwoFactorInput{RecoveryCode: f.recoveryCodes[1]}), http.StatusUnauthorized, "challenge reused")
+
+	// a wrong code uses up the challenge as well
+	challenge = f.challenge()
+	f.expect(f.second(challenge, TwoFactorInput{Code: "000000"}), http.StatusUnauthorized, "wrong code")
+	f.expect(f.second(challenge, TwoFactorInput{RecoveryCode: f.recoveryCodes[1]}), http.StatusUnauthorized, "challenge after a wrong code")
+
+	f.expect(f.second("not a token", TwoFactorInput{Code: f.code()}), http.StatusUnauthorized, "malformed challenge")
+	// an access token is no challenge
+	token, err := models.GenerateJwtToken(f.ada.ID, f.h.Keys, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.expect(f.second(token, TwoFactorInput{RecoveryCode: f.recoveryCodes[1]}), http.StatusUnauthorized, "access token as challenge")
+}
+
+func TestLoginTwoFactorRecoveryCodeOnce(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	// case and dashes do not matter
+	code := strings.ToUpper(strings.Replace(f.recoveryCodes[0], "-", "", 1))
+	f.expect(f.second(f.challenge(), TwoFactorInput{RecoveryCode: code}), http.StatusOK, "recovery code")
+	f.expect(f.second(f.challenge(), TwoFactorInput{RecoveryCode: f.recoveryCodes[0]}), http.StatusUnauthorized, "used recovery code")
+	f.expect(f.second(f.challenge(), TwoFactorInput{RecoveryCode: "aaaaa-aaaaa"}), http.StatusUnauthorized, "unknown recovery code")
+
+	twoFactor, err := f.h.TwoFactors.Find(context.Background(), f.ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(twoFactor.RecoveryCodes) != recoveryCodeCount-1 {
+		t.Fatalf("%d recovery codes left, want %d", len(twoFactor.RecoveryCodes), recoveryCodeCount-1)
+	}
+}
+
+func TestLoginTwoFactorLocks(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	f.h.Config.Auth.Lockout.Threshold = 5
+	ctx := context.Background()
+	failures := func() int {
+		attempt, err := f.h.LoginAttempts.Find(ctx, models.AccountAttemptKey(f.ada.ID))
+		if err != nil {
+			return 0
+		}
+		return attempt.Failures
+	}
+
+	// the password alone signs nobody in, it stays counted
+	challenge := f.challenge()
+	if got := failures(); got != 1 {
+		t.Fatalf("%d failures after the password step, want 1", got)
+	}
+	f.expect(f.second(challenge, TwoFactorInput{Code: f.code()}), http.StatusOK, "right code")
+	if got := failures(); got != 0 {
+		t.Fatalf("%d failures after signing in, want 0", got)
+	}
+
+	// each round of the right password and a wrong code counts twice
+	f.expect(f.second(f.challenge(), TwoFactorInput{Code: "000000"}), http.StatusUnauthorized, "first wrong code")
+	held := f.challenge()
+	f.expect(f.second(f.challenge(), TwoFactorInput{Code: "000000"}), http.StatusUnauthorized, "second wrong code")
+	f.expect(f.login("ada@example.com", "correct horse", ""), http.StatusLocked, "password of a locked account")
+	f.expect(f.second(held, TwoFactorInput{Code: f.code()}), http.StatusLocked, "right code of a locked account")
+}
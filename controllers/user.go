@@ -1,14 +1,15 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/events"
 	"github.com/jameslahm/conduit-server-gin/middlewares"
 	"github.com/jameslahm/conduit-server-gin/models"
-	"github.com/jameslahm/conduit-server-gin/utils"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
+	"github.com/jameslahm/conduit-server-gin/store"
 )
 
 // LoginInput login post data
@@ -17,8 +18,11 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// Login login handler
-func Login(c *gin.Context) {
+// Login login handler. Failed logins are counted per account and client
+// ip, too many of them throttle or lock before the password is checked.
+// The counts are only cleared once tokens are issued, with 2fa on that
+// waits for LoginTwoFactor.
+func (h *Handler) Login(c *gin.Context) {
 
 	var data LoginInput
 	if err := c.ShouldBindJSON(&data); err != nil {
@@ -28,48 +32,107 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	err := userCollection.FindOne(ctx, bson.M{
-		"email": data.Email,
-	}).Decode(&user)
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+	now := time.Now()
+	// every login counts as failed until its password checked out
+	refused, err := h.reserveIP(ctx, ip, now)
+	var user *models.User
+	if err == nil && refused == nil {
+		user, err = h.Users.FindByEmail(ctx, data.Email)
+	}
+	if err == nil && refused == nil {
+		refused, err = h.reserveAccount(ctx, user, now)
+	}
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{
+		c.JSON(validationStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	if err := models.VerifyPassword(user.Password, data.Password); err != nil {
+	if refused != nil {
+		refuse(c, refused)
+		return
+	}
+	if err := h.Passwords.Verify(user.Password, data.Password); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	if user.Token, err = models.GenerateJwtToken(user.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if h.Passwords.NeedsRehash(user.Password) {
+		h.rehash(c, user, data.Password)
+	}
+	if h.signIn(c, user) {
+		h.loggedIn(c, user)
+	}
+}
+
+// rehash store password of user again with the current algorithm and
+// cost. The old hash still works, a failure is only logged. A password
+// longer than bcrypt reads keeps its old hash.
+func (h *Handler) rehash(c *gin.Context, user *models.User, password string) {
+	if max := h.PasswordPolicy.MaxBytes; max > 0 && len(password) > max {
+		return
+	}
+	ctx := c.Request.Context()
+	hash, err := h.Passwords.Hash(password)
+	if err == nil {
+		_, err = h.Users.Update(ctx, user.ID, store.UserUpdate{Password: &hash})
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	h.forgetUser(ctx, user.ID)
+}
+
+// signIn respond with tokens of the authenticated user and report whether
+// it did. With 2fa on that only earns a challenge for LoginTwoFactor.
+func (h *Handler) signIn(c *gin.Context, user *models.User) bool {
+	twoFactor, err := h.TwoFactors.Find(c.Request.Context(), user.ID)
+	if err == nil && twoFactor.Enabled {
+		challenge, err := models.GenerateChallengeToken(user.ID, h.Keys, h.Config.Auth.TwoFactor.ChallengeTTL)
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return false
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"challenge": challenge,
+		})
+		return false
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
-		return
+		return false
+	}
+	if err := h.issueTokens(c.Request.Context(), user, ""); err != nil {
+		c.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return false
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"user": user,
 	})
+	return true
 }
 
 // RegisterInput register post data
 type RegisterInput struct {
 	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
 
 // Register register handler
-func Register(c *gin.Context) {
+func (h *Handler) Register(c *gin.Context) {
 	var data RegisterInput
-	var err error
 	if err := c.ShouldBindJSON(&data); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -77,96 +140,90 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	client, ctx, cancel := utils.GetConnection()
-	userCollection := client.Database("conduit").Collection("users")
-	defer cancel()
-	user := models.User{
-		Email:    data.Email,
-		Password: data.Password,
-		Username: data.Username,
-	}
-	user.Password = models.GenerateHashPassword(user.Password)
-
-	if _, err := userCollection.InsertOne(ctx, user); err != nil {
+	if err := h.PasswordPolicy.Check(data.Password, data.Username, data.Email); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-
-	if user.Token, err = models.GenerateJwtToken(user.ID); err != nil {
+	hash, err := h.Passwords.Hash(data.Password)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"user": user,
-	})
-}
-
-// GetCurrentUser get current user
-func GetCurrentUser(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": err.Error(),
-		})
-		return
+	user := models.User{
+		Email:    data.Email,
+		Password: hash,
+		Username: data.Username,
 	}
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if err := h.Users.Create(c.Request.Context(), &user); err != nil {
+		c.JSON(validationStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	var user models.User
-	err = userCollection.FindOne(ctx, bson.M{
-		"_id": id,
-	}).Decode(&user)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+	h.Events.Publish(c.Request.Context(), events.UserRegistered{User: user.ID, Username: user.Username, Email: user.Email})
+
+	if err := h.issueTokens(c.Request.Context(), &user, ""); err != nil {
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	user.Token, err = models.GenerateJwtToken(user.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
-		return
+	// the account exists either way, a failed mail is logged and can be
+	// sent again
+	if err := h.mailVerification(c.Request.Context(), &user); err != nil {
+		c.Error(err)
 	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"user": user,
 	})
-
 }
 
-// UpdateUserInput update user post data
-type UpdateUserInput struct {
-	Email    string `json:"email" bson:"email,omitempty"`
-	Bio      string `json:"bio" bson:"bio,omitempty"`
-	Image    string `json:"image" bson:"image:omitempty"`
-	Password string `json:"password" bson:"password,omitempty"`
-	Username string `json:"username" bson:"username,omitempty"`
+// GetCurrentUser get current user
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	user := middlewares.CurrentUser(c)
+	user.Token = middlewares.CurrentToken(c)
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+
 }
 
-// UpdateUser update user
-func UpdateUser(c *gin.Context) {
-	claims, err := middlewares.Authenticate(c)
+// DeleteCurrentUser delete current user with their articles, comments,
+// favorites and follows
+func (h *Handler) DeleteCurrentUser(c *gin.Context) {
+	id := middlewares.CurrentUser(c).ID
+	report, err := h.Users.Remove(c.Request.Context(), id, CommentPolicy(h.Config))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	h.forgetUser(c.Request.Context(), id)
+	h.Cache.Delete(c.Request.Context(), tagsKey)
+	c.JSON(http.StatusOK, gin.H{
+		"removed": report,
+	})
+}
 
+// UpdateUserInput update user post data, without a password the password
+// stays
+type UpdateUserInput struct {
+	Email    string  `json:"email" bson:"email,omitempty" binding:"omitempty,email"`
+	Bio      string  `json:"bio" bson:"bio,omitempty"`
+	Image    string  `json:"image" bson:"image,omitempty"`
+	Password *string `json:"password" bson:"password,omitempty"`
+	Username string  `json:"username" bson:"username,omitempty"`
+}
+
+// UpdateUser update user
+func (h *Handler) UpdateUser(c *gin.Context) {
 	var data UpdateUserInput
 	if err := c.ShouldBindJSON(&data); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -175,22 +232,86 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	client, ctx, cancel := utils.GetConnection()
-	defer cancel()
-	userCollection := client.Database("conduit").Collection("users")
-	id, err := primitive.ObjectIDFromHex(claims.UserID)
+	current := middlewares.CurrentUser(c)
+	id := current.ID
+
+	var update store.UserUpdate
+	emailChanged := data.Email != "" && data.Email != current.Email
+	if emailChanged {
+		verified := false
+		update.Email, update.EmailVerified = &data.Email, &verified
+	}
+	if data.Bio != "" {
+		update.Bio = &data.Bio
+	}
+	if data.Image != "" {
+		update.Image = &data.Image
+	}
+	if data.Password != nil {
+		// checked against the username and email the user ends up with
+		username, email := current.Username, current.Email
+		if data.Username != "" {
+			username = data.Username
+		}
+		if data.Email != "" {
+			email = data.Email
+		}
+		if err := h.PasswordPolicy.Check(*data.Password, username, email); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		hash, err := h.Passwords.Hash(*data.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		update.Password = &hash
+	}
+	if data.Username != "" {
+		update.Username = &data.Username
+	}
+	ctx := c.Request.Context()
+	user, err := h.Users.Update(ctx, id, update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
-	if data.Password != "" {
-		data.Password = models.GenerateHashPassword(data.Password)
+	h.forgetUser(ctx, user.ID)
+
+	user.Token = middlewares.CurrentToken(c)
+	if update.Password != nil || emailChanged {
+		// changed credentials end every session, the caller gets a new one
+		err = h.revokeUser(ctx, user.ID)
+		if err == nil {
+			err = h.revokeToken(ctx, middlewares.CurrentClaims(c))
+		}
+		if err == nil {
+			// as in ResetPassword, tokens may have been minted by whoever
+			// had the old credentials
+			err = h.AccessTokens.DeleteUser(ctx, user.ID)
+		}
+		if err == nil {
+			err = h.issueTokens(ctx, user, "")
+		}
+		if err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	if emailChanged {
+		if err := h.mailVerification(ctx, user); err != nil {
+			c.Error(err)
+		}
 	}
-	userCollection.UpdateOne(ctx, bson.M{
-		"_id": id,
-	}, bson.M{
-		"$set": data,
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
 	})
 }
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/password"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+func TestLoginRehashes(t *testing.T) {
+	f := newLoginFixture(t, func(cfg *config.Config) {
+		cfg.Auth.Lockout.BackoffBase = 0
+	})
+	// ada's bcrypt hash predates the switch to argon2id
+	f.h.Passwords = &password.Hasher{Algorithm: password.Argon2id, Argon2: password.Argon2Params{Time: 1, Memory: 64, Threads: 1}}
+	ctx := context.Background()
+
+	f.expect(f.login("ada@example.com", "wrong", ""), http.StatusUnprocessableEntity, "wrong password")
+	user, err := f.h.Users.FindByID(ctx, f.ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != f.ada.Password {
+		t.Fatal("a failed login rehashed the password")
+	}
+
+	f.expect(f.login("ada@example.com", "correct horse", ""), http.StatusOK, "right password")
+	if user, err = f.h.Users.FindByID(ctx, f.ada.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(user.Password, "$argon2id$") || f.h.Passwords.NeedsRehash(user.Password) {
+		t.Fatalf("password hash %s after login", user.Password)
+	}
+	f.expect(f.login("ada@example.com", "correct horse", ""), http.StatusOK, "login with the new hash")
+}
+
+func TestLoginKeepsHashBcryptWouldTruncate(t *testing.T) {
+	f := newLoginFixture(t, nil)
+	ctx := context.Background()
+	long := strings.Repeat("ß", 40)
+	argon2 := &password.Hasher{Algorithm: password.Argon2id, Argon2: password.Argon2Params{Time: 1, Memory: 64, Threads: 1}}
+	hash, err := argon2.Hash(long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.h.Users.Update(ctx, f.ada.ID, store.UserUpdate{Password: &hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fixture hashes with bcrypt, which reads the first 72 of 80 bytes
+	f.expect(f.login("ada@example.com", long, ""), http.StatusOK, "long password")
+	user, err := f.h.Users.FindByID(ctx, f.ada.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Password != hash {
+		t.Fatalf("password hash %s after login, want the argon2id one", user.Password)
+	}
+	f.expect(f.login("ada@example.com", strings.Repeat("ß", 36)+"x", ""), http.StatusUnprocessableEntity, "first 72 bytes only")
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/mail"
+	"github.com/jameslahm/conduit-server-gin/middlewares"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errVerifyInvalid malformed or expired verification token, or one mailed
+// to an email the user no longer has
+var errVerifyInvalid = errors.New("error: verification token invalid")
+
+// mailVerification mail user a link verifying their current email
+func (h *Handler) mailVerification(ctx context.Context, user *models.User) error {
+	cfg := h.Config.Auth.EmailVerification
+	token, err := models.GenerateEmailToken(user, h.Keys, cfg.TTL)
+	if err != nil {
+		return err
+	}
+	return h.Mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Verify your Conduit email",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"please confirm that this is your email by opening this link within %d hours:\n\n"+
+			"%s%s\n\n"+
+			"If you did not sign up for Conduit, ignore this mail.\n",
+			user.Username, int(cfg.TTL.Hours()), cfg.URL, token),
+	})
+}
+
+// VerifyEmail mark the email of the user of the token verified. Verifying
+// twice is fine.
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+	claims, err := models.VerifyEmailToken(c.Query("token"), h.Keys)
+	var id primitive.ObjectID
+	if err == nil {
+		id, err = primitive.ObjectIDFromHex(claims.UserID)
+	}
+	var user *models.User
+	if err == nil {
+		user, err = h.Users.FindByID(ctx, id)
+	}
+	if err == nil && user.Email != claims.Email {
+		err = errVerifyInvalid
+	}
+	if err == nil && !user.EmailVerified {
+		verified := true
+		user, err = h.Users.Update(ctx, id, store.UserUpdate{EmailVerified: &verified})
+	}
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusInternalServerError || status == http.StatusNotFound {
+			status, err = http.StatusBadRequest, errVerifyInvalid
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// resendVerification mail user a new verification link, unless the limit
+// of links resent to them within the window was reached
+func (h *Handler) resendVerification(ctx context.Context, user *models.User) error {
+	cfg := h.Config.Auth.EmailVerification
+	key := models.VerificationResendKey(user.ID)
+	sent, err := h.LoginAttempts.Find(ctx, key)
+	if err == nil && sent.Failures >= cfg.Limit {
+		return nil
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if _, err := h.LoginAttempts.Fail(ctx, key, time.Now(), cfg.Window); err != nil {
+		return err
+	}
+	return h.mailVerification(ctx, user)
+}
+
+// ResendVerification mail the current user a new verification link.
+// Nothing is sent once the email is verified, and requests over the rate
+// limit of the user are dropped silently.
+func (h *Handler) ResendVerification(c *gin.Context) {
+	user := middlewares.CurrentUser(c)
+	if !user.EmailVerified {
+		if err := h.resendVerification(c.Request.Context(), user); err != nil {
+			c.JSON(errorStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{})
+}
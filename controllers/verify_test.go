@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+type userBody struct {
+	User models.User `json:"user"`
+}
+
+func TestVerifyEmail(t *testing.T) {
+	f := newAPIFixture(t, nil)
+	f.register("ada")
+	token, mails := f.mailed("ada")
+	if mails != 1 {
+		t.Fatalf("%d mails after registering, want 1", mails)
+	}
+
+	f.do(http.MethodGet, "/api/users/verify?token=not-a-token", "", nil, http.StatusBadRequest, nil)
+	f.do(http.MethodGet, "/api/users/verify", "", nil, http.StatusBadRequest, nil)
+	var body userBody
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, &body)
+	if body.User.Username != "ada" || !body.User.EmailVerified {
+		t.Fatalf("verified %+v", body.User)
+	}
+	// verifying twice is fine
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, nil)
+
+	// a new email takes a new link, the one of the old email is void
+	bob := f.register("bob")
+	old, _ := f.mailed("bob")
+	f.do(http.MethodPut, "/api/user", bob, UpdateUserInput{Email: "bobby@example.com"}, http.StatusOK, &body)
+	if body.User.EmailVerified {
+		t.Fatalf("new email verified %+v", body.User)
+	}
+	f.do(http.MethodGet, "/api/users/verify?token="+old, "", nil, http.StatusBadRequest, nil)
+	token, _ = f.mailed("bobby")
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, &body)
+	if body.User.Email != "bobby@example.com" || !body.User.EmailVerified {
+		t.Fatalf("verified %+v", body.User)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	f := newAPIFixture(t, func(cfg *config.Config) {
+		cfg.Auth.EmailVerification.Limit = 2
+	})
+	ada, bob := f.register("ada"), f.register("bob")
+	f.do(http.MethodPost, "/api/user/verify", "", nil, http.StatusUnauthorized, nil)
+
+	// registering mailed the first link, the limit is on resent ones
+	for want := 2; want <= 3; want++ {
+		f.do(http.MethodPost, "/api/user/verify", ada, nil, http.StatusAccepted, nil)
+		if _, mails := f.mailed("ada"); mails != want {
+			t.Fatalf("%d mails, want %d", mails, want)
+		}
+	}
+	f.do(http.MethodPost, "/api/user/verify", ada, nil, http.StatusAccepted, nil)
+	token, mails := f.mailed("ada")
+	if mails != 3 {
+		t.Fatalf("%d mails over the limit, want 3", mails)
+	}
+	// the limit is per user
+	f.do(http.MethodPost, "/api/user/verify", bob, nil, http.StatusAccepted, nil)
+	if _, mails := f.mailed("bob"); mails != 2 {
+		t.Fatalf("%d mails to bob, want 2", mails)
+	}
+
+	// every link mailed works
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, nil)
+	bob2 := f.session("bob").Token
+	token, _ = f.mailed("bob")
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, nil)
+	// a verified email gets no more links
+	f.do(http.MethodPost, "/api/user/verify", bob2, nil, http.StatusAccepted, nil)
+	if _, mails := f.mailed("bob"); mails != 2 {
+		t.Fatalf("%d mails to bob once verified, want 2", mails)
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	f := newAPIFixture(t, func(cfg *config.Config) {
+		cfg.Auth.EmailVerification.Required = true
+	})
+	ada, bob := f.register("ada"), f.register("bob")
+	token, _ := f.mailed("bob")
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, nil)
+	var created articleBody
+	f.do(http.MethodPost, "/api/articles", bob, CreateArticleInput{Title: "Hello"}, http.StatusOK, &created)
+	comments := "/api/articles/" + created.Article.Slug + "/comments"
+
+	f.do(http.MethodPost, "/api/articles", ada, CreateArticleInput{Title: "Mine"}, http.StatusForbidden, nil)
+	f.do(http.MethodPost, comments, ada, AddCommentInput{Body: "nice"}, http.StatusForbidden, nil)
+	// the rest stays open to unverified users
+	f.do(http.MethodGet, "/api/articles/"+created.Article.Slug, ada, nil, http.StatusOK, nil)
+	f.do(http.MethodPost, "/api/articles/"+created.Article.Slug+"/favorite", ada, nil, http.StatusOK, nil)
+	f.do(http.MethodPut, "/api/user", ada, UpdateUserInput{Bio: "unverified"}, http.StatusOK, nil)
+
+	// the token in hand passes once the email is verified
+	token, _ = f.mailed("ada")
+	f.do(http.MethodGet, "/api/users/verify?token="+token, "", nil, http.StatusOK, nil)
+	f.do(http.MethodPost, "/api/articles", ada, CreateArticleInput{Title: "Mine"}, http.StatusOK, nil)
+	f.do(http.MethodPost, comments, ada, AddCommentInput{Body: "nice"}, http.StatusOK, nil)
+}
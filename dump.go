@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/dump"
+)
+
+// exportCommand conduit export -dir DIR
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory to write the dump to")
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		return errors.New("usage: conduit export -dir DIR")
+	}
+	if err := cfg.Store.Validate(); err != nil {
+		return err
+	}
+
+	s, err := openStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close(context.Background())
+	exporter, ok := s.(store.Exporter)
+	if !ok {
+		return errors.New("store does not support export")
+	}
+
+	manifest, err := dump.Export(context.Background(), exporter, *dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d users, %d articles, %d comments to %s\n", manifest.Users, manifest.Articles, manifest.Comments, *dir)
+	return nil
+}
+
+// importCommand conduit import -dir DIR [-wipe] [-verify-only]
+func importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory of the dump to import")
+	wipe := flags.Bool("wipe", false, "delete all existing data first")
+	verifyOnly := flags.Bool("verify-only", false, "verify the dump without importing it")
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		return err
+	}
+	if *dir == "" {
+		return errors.New("usage: conduit import -dir DIR [-wipe] [-verify-only]")
+	}
+	if *verifyOnly {
+		manifest, err := dump.Verify(*dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Verified %d users, %d articles, %d comments in %s\n", manifest.Users, manifest.Articles, manifest.Comments, *dir)
+		return nil
+	}
+	if err := cfg.Store.Validate(); err != nil {
+		return err
+	}
+	if cfg.Store.Driver == "memory" {
+		return errors.New("the memory store is gone when import exits, import into a persistent store")
+	}
+
+	s, err := openStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close(context.Background())
+	if err := checkMigrations(s); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *wipe {
+		wiper, ok := s.(store.Wiper)
+		if !ok {
+			return errors.New("store does not support wiping")
+		}
+		// verify before wiping so a broken dump never costs the current data
+		if _, err := dump.Verify(*dir); err != nil {
+			return err
+		}
+		if err := wiper.Wipe(ctx); err != nil {
+			return fmt.Errorf("wipe: %w", err)
+		}
+	} else if exporter, ok := s.(store.Exporter); ok {
+		if err := dump.Empty(ctx, exporter); err != nil {
+			if errors.Is(err, dump.ErrNotEmpty) {
+				return fmt.Errorf("%w, run with -wipe to replace its data", err)
+			}
+			return err
+		}
+	}
+
+	manifest, err := dump.Import(ctx, s, *dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d users, %d articles, %d comments from %s\n", manifest.Users, manifest.Articles, manifest.Comments, *dir)
+	return nil
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Handler subscriber callback
+type Handler func(ctx context.Context, event Event)
+
+// subscriber handler and the names it listens to, all when empty
+type subscriber struct {
+	names   map[string]bool
+	handler Handler
+	// queue of asynchronous subscribers, nil for synchronous ones
+	queue chan Event
+}
+
+func (s *subscriber) wants(event Event) bool {
+	return len(s.names) == 0 || s.names[event.EventName()]
+}
+
+// Bus in-process publish/subscribe, safe for concurrent use
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// NewBus create bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func newSubscriber(handler Handler, names []string) *subscriber {
+	s := &subscriber{handler: handler, names: make(map[string]bool)}
+	for _, name := range names {
+		s.names[name] = true
+	}
+	return s
+}
+
+// Subscribe call handler inside Publish for events of names, or every
+// event without names. Publish waits for it, so it should be quick.
+func (b *Bus) Subscribe(handler Handler, names ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, newSubscriber(handler, names))
+}
+
+// SubscribeAsync call handler on its own goroutine for events of names, or
+// every event without names, in publish order. Up to buffer events wait
+// for it before Publish blocks.
+func (b *Bus) SubscribeAsync(buffer int, handler Handler, names ...string) {
+	s := newSubscriber(handler, names)
+	s.queue = make(chan Event, buffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for event := range s.queue {
+			// the request publishing the event is long gone
+			deliver(context.Background(), s.handler, event)
+		}
+	}()
+}
+
+// Publish deliver event to every interested subscriber. A panicking
+// subscriber is logged and skipped, the write behind the event already
+// succeeded.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, s := range b.subscribers {
+		if !s.wants(event) {
+			continue
+		}
+		if s.queue != nil {
+			s.queue <- event
+			continue
+		}
+		deliver(ctx, s.handler, event)
+	}
+}
+
+// Close stop accepting events and wait for asynchronous subscribers to
+// drain their queues
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subscribers {
+			if s.queue != nil {
+				close(s.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+func deliver(ctx context.Context, handler Handler, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: %s subscriber panicked: %v", event.EventName(), r)
+		}
+	}()
+	handler(ctx, event)
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recorder handler keeping the events it got
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *recorder) handle(ctx context.Context, event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recorder) got() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func TestBusSubscribe(t *testing.T) {
+	b := NewBus()
+	var all, follows recorder
+	b.Subscribe(all.handle)
+	b.Subscribe(follows.handle, UserFollowedName, UserUnfollowedName)
+	// a panicking subscriber does not keep others from their events
+	b.Subscribe(func(ctx context.Context, event Event) { panic("subscriber bug") })
+
+	followed := UserFollowed{Follower: primitive.NewObjectID(), Followee: primitive.NewObjectID()}
+	ctx := context.Background()
+	b.Publish(ctx, followed)
+	b.Publish(ctx, ArticleDeleted{Slug: "hello"})
+	b.Publish(ctx, UserUnfollowed{Follower: followed.Follower, Followee: followed.Followee})
+
+	if got := all.got(); len(got) != 3 {
+		t.Fatalf("all events: %v", got)
+	}
+	got := follows.got()
+	if len(got) != 2 || got[0] != followed || got[1].EventName() != UserUnfollowedName {
+		t.Fatalf("follow events: %v", got)
+	}
+}
+
+func TestBusAsyncOrder(t *testing.T) {
+	const count = 100
+	b := NewBus()
+	var r recorder
+	b.SubscribeAsync(4, func(ctx context.Context, event Event) {
+		// a slow subscriber fills its queue and Publish waits
+		time.Sleep(time.Millisecond / 10)
+		r.handle(ctx, event)
+	}, ArticleDeletedName)
+	for i := 0; i < count; i++ {
+		b.Publish(context.Background(), ArticleDeleted{Slug: string(rune('a' + i%26)), Author: primitive.NewObjectID()})
+	}
+	b.Close()
+
+	// Close drained the queue
+	got := r.got()
+	if len(got) != count {
+		t.Fatalf("%d events delivered, want %d", len(got), count)
+	}
+	for i, event := range got {
+		if slug := event.(ArticleDeleted).Slug; slug != string(rune('a'+i%26)) {
+			t.Fatalf("event %d of slug %s out of order", i, slug)
+		}
+	}
+}
+
+func TestBusClose(t *testing.T) {
+	b := NewBus()
+	var direct, queued recorder
+	b.Subscribe(direct.handle)
+	b.SubscribeAsync(1, queued.handle)
+	b.Publish(context.Background(), ArticleDeleted{Slug: "before"})
+	b.Close()
+	// closing twice is harmless, events after it are dropped
+	b.Close()
+	b.Publish(context.Background(), ArticleDeleted{Slug: "after"})
+
+	for name, r := range map[string]*recorder{"synchronous": &direct, "asynchronous": &queued} {
+		if got := r.got(); len(got) != 1 || got[0].(ArticleDeleted).Slug != "before" {
+			t.Errorf("%s subscriber got %v", name, got)
+		}
+	}
+}
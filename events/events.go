@@ -0,0 +1,123 @@
+// Package events typed domain events and an in-process bus delivering
+// them to synchronous and asynchronous subscribers
+package events
+
+import (
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event domain event, published after the write it describes succeeded
+type Event interface {
+	// EventName name subscribers filter on
+	EventName() string
+}
+
+// event names
+const (
+	ArticleCreatedName     = "ArticleCreated"
+	ArticleUpdatedName     = "ArticleUpdated"
+	ArticleDeletedName     = "ArticleDeleted"
+	CommentAddedName       = "CommentAdded"
+	CommentDeletedName     = "CommentDeleted"
+	UserFollowedName       = "UserFollowed"
+	UserUnfollowedName     = "UserUnfollowed"
+	ArticleFavoritedName   = "ArticleFavorited"
+	ArticleUnfavoritedName = "ArticleUnfavorited"
+	UserRegisteredName     = "UserRegistered"
+)
+
+// ArticleCreated article was created
+type ArticleCreated struct {
+	Article models.Article
+}
+
+// ArticleUpdated article was updated, Article is the new state
+type ArticleUpdated struct {
+	Article models.Article
+}
+
+// ArticleDeleted article of author was deleted
+type ArticleDeleted struct {
+	Slug   string
+	Author primitive.ObjectID
+}
+
+// CommentAdded comment was added to the article of ArticleSlug
+type CommentAdded struct {
+	Comment     models.Comment
+	ArticleSlug string
+}
+
+// CommentDeleted comment of author was deleted from the article of
+// ArticleSlug
+type CommentDeleted struct {
+	ID          primitive.ObjectID
+	Author      primitive.ObjectID
+	ArticleSlug string
+}
+
+// UserFollowed follower started following followee
+type UserFollowed struct {
+	Follower primitive.ObjectID
+	Followee primitive.ObjectID
+}
+
+// UserUnfollowed follower stopped following followee
+type UserUnfollowed struct {
+	Follower primitive.ObjectID
+	Followee primitive.ObjectID
+}
+
+// ArticleFavorited user favorited article
+type ArticleFavorited struct {
+	Article        primitive.ObjectID
+	Slug           string
+	User           primitive.ObjectID
+	FavoritesCount int
+}
+
+// ArticleUnfavorited user unfavorited article
+type ArticleUnfavorited struct {
+	Article        primitive.ObjectID
+	Slug           string
+	User           primitive.ObjectID
+	FavoritesCount int
+}
+
+// UserRegistered user signed up
+type UserRegistered struct {
+	User     primitive.ObjectID
+	Username string
+	Email    string
+}
+
+// EventName ArticleCreatedName
+func (ArticleCreated) EventName() string { return ArticleCreatedName }
+
+// EventName ArticleUpdatedName
+func (ArticleUpdated) EventName() string { return ArticleUpdatedName }
+
+// EventName ArticleDeletedName
+func (ArticleDeleted) EventName() string { return ArticleDeletedName }
+
+// EventName CommentAddedName
+func (CommentAdded) EventName() string { return CommentAddedName }
+
+// EventName CommentDeletedName
+func (CommentDeleted) EventName() string { return CommentDeletedName }
+
+// EventName UserFollowedName
+func (UserFollowed) EventName() string { return UserFollowedName }
+
+// EventName UserUnfollowedName
+func (UserUnfollowed) EventName() string { return UserUnfollowedName }
+
+// EventName ArticleFavoritedName
+func (ArticleFavorited) EventName() string { return ArticleFavoritedName }
+
+// EventName ArticleUnfavoritedName
+func (ArticleUnfavorited) EventName() string { return ArticleUnfavoritedName }
+
+// EventName UserRegisteredName
+func (UserRegistered) EventName() string { return UserRegisteredName }
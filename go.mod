@@ -13,7 +13,10 @@ require (
 	github.com/gosimple/slug v1.9.0
 	github.com/joho/godotenv v1.3.0
 	github.com/json-iterator/go v1.1.10 // indirect
+	github.com/lib/pq v1.10.9
 	github.com/mailru/easyjson v0.7.1 // indirect
+	github.com/mattn/go-sqlite3 v1.14.10
+	github.com/swaggo/files v0.0.0-20190704085106-630677cd5c14
 	github.com/swaggo/gin-swagger v1.2.0
 	github.com/swaggo/swag v1.6.7
 	go.mongodb.org/mongo-driver v1.3.5
@@ -22,6 +25,5 @@ require (
 	golang.org/x/sys v0.0.0-20200724161237-0e2f3a69832c // indirect
 	golang.org/x/tools v0.0.0-20200725200936-102e7d357031 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
-	github.com/swaggo/files v0.0.0-20190704085106-630677cd5c14
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,177 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// JWK public json web key of RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// N, E rsa modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv, X, Y curve and coordinates of ecdsa keys, Crv and X of Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS json web key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS public keys of the ring, the secret is never published
+func (r *Ring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range r.Keys() {
+		jwk, err := publicJWK(key.Public)
+		if err != nil {
+			// ParsePEM only accepts keys publicJWK knows
+			panic(err)
+		}
+		jwk.Kid, jwk.Use, jwk.Alg = key.ID, "sig", key.Method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// padded big endian bytes of n, left padded to size
+func padded(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// publicJWK members of public that identify it, without kid, use and alg
+func publicJWK(public crypto.PublicKey) (JWK, error) {
+	switch k := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: encode(k.N.Bytes()), E: encode(big.NewInt(int64(k.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return JWK{Kty: "EC", Crv: k.Curve.Params().Name, X: encode(padded(k.X, size)), Y: encode(padded(k.Y, size))}, nil
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: encode(k)}, nil
+	}
+	return JWK{}, fmt.Errorf("unsupported key type %T", public)
+}
+
+// PublicKey public key of k, for keys published by others. RSA, EC of
+// P-256, P-384 and P-521, and Ed25519 keys are known.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("jwk %s: bad key member", k.Kid)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < minRSABits {
+			return nil, fmt.Errorf("jwk %s: rsa key of %d bits, at least %d required", k.Kid, n.BitLen(), minRSABits)
+		}
+		if !e.IsInt64() || e.Int64() > math.MaxInt32 {
+			return nil, fmt.Errorf("jwk %s: rsa exponent out of range", k.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("jwk %s: point not on curve", k.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		b, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(b) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %s: unsupported or bad OKP key", k.Kid)
+		}
+		return ed25519.PublicKey(b), nil
+	}
+	return nil, fmt.Errorf("jwk %s: unsupported key type %q", k.Kid, k.Kty)
+}
+
+// thumbprint RFC 7638 thumbprint of public, the required members in
+// lexicographic order hashed with sha256
+func thumbprint(public crypto.PublicKey) (string, error) {
+	jwk, err := publicJWK(public)
+	if err != nil {
+		return "", err
+	}
+	// encoding/json writes struct fields in declaration order and the
+	// values need no escaping
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return encode(sum[:]), nil
+}
@@ -0,0 +1,220 @@
+// Package keys signing keys of access tokens. Tokens are signed by one key
+// and verified by any key of the ring, found by the kid header, so a new
+// signing key can be rolled out while tokens of the previous one are still
+// valid.
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// minRSABits smallest accepted rsa modulus
+const minRSABits = 2048
+
+// ErrUnknownKey token names a kid that is not in the ring
+var ErrUnknownKey = errors.New("error: unknown signing key")
+
+// Key public key with its private half when it can sign
+type Key struct {
+	// ID kid, the RFC 7638 thumbprint of the public key
+	ID     string
+	Method jwt.SigningMethod
+	Public crypto.PublicKey
+	// Private nil for keys that only verify
+	Private crypto.PrivateKey
+}
+
+// Ring keys verifying tokens, one of them signing new ones. Without keys
+// tokens are signed and verified HS256 with the secret.
+type Ring struct {
+	mu      sync.RWMutex
+	secret  []byte
+	signing *Key
+	keys    []*Key
+}
+
+// New ring of secret and the PEM key files, see Load
+func New(secret []byte, files []string) (*Ring, error) {
+	r := &Ring{secret: secret}
+	if err := r.Load(files); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Load replace the keys with those of the PEM files. The first file must
+// hold a private key and signs from now on, the others may hold public
+// keys only and verify tokens signed before a rotation.
+func (r *Ring) Load(files []string) error {
+	if len(files) == 0 && len(r.secret) == 0 {
+		return errors.New("keys: neither key files nor a secret")
+	}
+	var loaded []*Key
+	seen := make(map[string]string)
+	for i, file := range files {
+		key, err := ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if i == 0 && key.Private == nil {
+			return fmt.Errorf("keys: %s: signing key has no private key", file)
+		}
+		if other, ok := seen[key.ID]; ok {
+			return fmt.Errorf("keys: %s: same key as %s", file, other)
+		}
+		seen[key.ID] = file
+		loaded = append(loaded, key)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys = loaded
+	r.signing = nil
+	if len(loaded) > 0 {
+		r.signing = loaded[0]
+	}
+	return nil
+}
+
+// Keys keys of the ring, the signing key first
+func (r *Ring) Keys() []*Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]*Key(nil), r.keys...)
+}
+
+// Sign signed token of claims, with its kid header set
+func (r *Ring) Sign(claims jwt.Claims) (string, error) {
+	r.mu.RLock()
+	signing := r.signing
+	r.mu.RUnlock()
+	if signing == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(r.secret)
+	}
+	token := jwt.NewWithClaims(signing.Method, claims)
+	token.Header["kid"] = signing.ID
+	return token.SignedString(signing.Private)
+}
+
+// Parse verify ss and decode its claims into claims. A token without kid
+// is only accepted HS256 when the ring has a secret and no keys, once keys
+// are loaded the secret verifies nothing and clients refresh their tokens.
+func (r *Ring) Parse(ss string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(ss, claims, r.keyFunc)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("error: token invalid")
+	}
+	return nil
+}
+
+// keyFunc verification key of token, its algorithm must be the one of the
+// key so a public key is never used as an hmac secret
+func (r *Ring) keyFunc(token *jwt.Token) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// anyone holding the secret could sign tokens that never expire
+		// if it still verified next to the keys
+		if token.Method != jwt.SigningMethodHS256 || len(r.secret) == 0 || len(r.keys) > 0 {
+			return nil, errors.New("error: unexpected signing method")
+		}
+		return r.secret, nil
+	}
+	for _, key := range r.keys {
+		if key.ID == kid {
+			if token.Method != key.Method {
+				return nil, errors.New("error: unexpected signing method")
+			}
+			return key.Public, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// ReadFile key of the PEM file at path
+func ReadFile(path string) (*Key, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("keys: %w", err)
+	}
+	key, err := ParsePEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("keys: %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// ParsePEM key of the first PEM block of data. Private keys may be PKCS #8,
+// PKCS #1 or SEC 1, public keys PKIX.
+func ParsePEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case *ecdsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Private, key.Public = k, k.Public()
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		key.Public = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	switch public := key.Public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("rsa key of %d bits, at least %d required", public.N.BitLen(), minRSABits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		if public.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ecdsa curve %s, only P-256 is supported", public.Curve.Params().Name)
+		}
+		key.Method = jwt.SigningMethodES256
+	case ed25519.PublicKey:
+		key.Method = SigningMethodEdDSA
+	}
+	if key.ID, err = thumbprint(key.Public); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
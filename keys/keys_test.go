@@ -0,0 +1,340 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// rsaKey shared 2048 bit key, generating one per test is slow
+var rsaKey *rsa.PrivateKey
+
+func init() {
+	var err error
+	if rsaKey, err = rsa.GenerateKey(rand.Reader, minRSABits); err != nil {
+		panic(err)
+	}
+}
+
+func pemOf(t *testing.T, typ string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+}
+
+func pkcs8(t *testing.T, private interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	return pemOf(t, "PRIVATE KEY", der, err)
+}
+
+func pkix(t *testing.T, public interface{}) []byte {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	return pemOf(t, "PUBLIC KEY", der, err)
+}
+
+// tempDir removed when t ends
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// writeKey file in dir holding data
+func writeKey(t *testing.T, dir string, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func claims() jwt.StandardClaims {
+	return jwt.StandardClaims{Subject: "1", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+}
+
+func TestParsePEM(t *testing.T) {
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecP384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallRSA, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, err := x509.MarshalECPrivateKey(ec)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		method  jwt.SigningMethod
+		private bool
+	}{
+		{"rsa pkcs1", pemOf(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), nil), jwt.SigningMethodRS256, true},
+		{"rsa pkcs8", pkcs8(t, rsaKey), jwt.SigningMethodRS256, true},
+		{"rsa public", pkix(t, &rsaKey.PublicKey), jwt.SigningMethodRS256, false},
+		{"ec sec1", pemOf(t, "EC PRIVATE KEY", sec1, err), jwt.SigningMethodES256, true},
+		{"ec pkcs8", pkcs8(t, ec), jwt.SigningMethodES256, true},
+		{"ec public", pkix(t, &ec.PublicKey), jwt.SigningMethodES256, false},
+		{"ed25519 pkcs8", pkcs8(t, edPrivate), SigningMethodEdDSA, true},
+		{"ed25519 public", pkix(t, edPublic), SigningMethodEdDSA, false},
+	}
+	for _, tt := range tests {
+		key, err := ParsePEM(tt.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if key.Method != tt.method {
+			t.Errorf("%s: method %s, want %s", tt.name, key.Method.Alg(), tt.method.Alg())
+		}
+		if (key.Private != nil) != tt.private {
+			t.Errorf("%s: private key %v, want %v", tt.name, key.Private != nil, tt.private)
+		}
+		if want, _ := thumbprint(key.Public); key.ID != want {
+			t.Errorf("%s: kid %s, want the thumbprint %s", tt.name, key.ID, want)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"rsa of 1024 bits": pkcs8(t, smallRSA),
+		"ec of P-384":      pkcs8(t, ecP384),
+		"no pem":           []byte("not a key"),
+	} {
+		if _, err := ParsePEM(data); err == nil {
+			t.Errorf("%s: parsed", name)
+		}
+	}
+}
+
+func TestThumbprint(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	tests := []struct {
+		name   string
+		public crypto.PublicKey
+		want   string
+	}{
+		// RFC 7638 section 3.1
+		{"rsa", &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")),
+			E: 65537,
+		}, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"},
+		// RFC 8037 appendix A.3
+		{"ed25519", ed25519.PublicKey(decode("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")), "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"},
+	}
+	for _, tt := range tests {
+		got, err := thumbprint(tt.public)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: thumbprint %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDir(t)
+	ring, err := New([]byte("secret"), []string{
+		writeKey(t, dir, "ed.pem", pkcs8(t, edPrivate)),
+		writeKey(t, dir, "ec.pem", pkix(t, &ec.PublicKey)),
+		writeKey(t, dir, "rsa.pem", pkix(t, &rsaKey.PublicKey)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := ring.JWKS()
+	keys := ring.Keys()
+	if len(set.Keys) != len(keys) {
+		t.Fatalf("%d jwks keys, want %d", len(set.Keys), len(keys))
+	}
+	for i, jwk := range set.Keys {
+		if jwk.Kid != keys[i].ID || jwk.Use != "sig" || jwk.Alg != keys[i].Method.Alg() {
+			t.Errorf("jwk %d: kid %s use %s alg %s", i, jwk.Kid, jwk.Use, jwk.Alg)
+		}
+		// the published key reads back as the public key of the ring
+		public, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("jwk %d: %v", i, err)
+		}
+		if id, _ := thumbprint(public); id != jwk.Kid {
+			t.Errorf("jwk %d: thumbprint %s, want %s", i, id, jwk.Kid)
+		}
+	}
+	if ring, _ := New([]byte("secret"), nil); len(ring.JWKS().Keys) != 0 {
+		t.Error("the secret is published")
+	}
+}
+
+func TestEdDSA(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := New(nil, []string{writeKey(t, tempDir(t), "ed.pem", pkcs8(t, edPrivate))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := ring.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed jwt.StandardClaims
+	if err := ring.Parse(ss, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Subject != "1" {
+		t.Fatalf("subject %q", parsed.Subject)
+	}
+	// a flipped signature byte
+	sig := []byte(ss)
+	sig[len(sig)-2] ^= 1
+	if err := ring.Parse(string(sig), &jwt.StandardClaims{}); err == nil {
+		t.Fatal("tampered signature verified")
+	}
+}
+
+func TestParseSecret(t *testing.T) {
+	hs256, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring, err := New([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Parse(hs256, &jwt.StandardClaims{}); err != nil {
+		t.Fatalf("secret without keys: %v", err)
+	}
+	if err := ring.Load([]string{writeKey(t, tempDir(t), "rsa.pem", pkcs8(t, rsaKey))}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Parse(hs256, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("secret signed token verified next to keys")
+	}
+}
+
+func TestParseAlgorithmOfKey(t *testing.T) {
+	ring, err := New(nil, []string{writeKey(t, tempDir(t), "rsa.pem", pkcs8(t, rsaKey))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the public key used as an hmac secret
+	public, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	token.Header["kid"] = ring.Keys()[0].ID
+	ss, err := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Parse(ss, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("HS256 verified with the public key")
+	}
+
+	token = jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+	token.Header["kid"] = "unknown"
+	if ss, err = token.SignedString(rsaKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Parse(ss, &jwt.StandardClaims{}); err == nil {
+		t.Fatal("unknown kid verified")
+	}
+}
+
+func TestLoadRotation(t *testing.T) {
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := tempDir(t)
+	oldFile := writeKey(t, dir, "old.pem", pkcs8(t, rsaKey))
+	newFile := writeKey(t, dir, "new.pem", pkcs8(t, edPrivate))
+	ring, err := New(nil, []string{oldFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := ring.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the new key signs, the old one still verifies its tokens
+	if err := ring.Load([]string{newFile, oldFile}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Parse(old, &jwt.StandardClaims{}); err != nil {
+		t.Fatalf("token of the old key: %v", err)
+	}
+	ss, err := ring.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := jwt.Parse(ss, nil); token.Header["alg"] != "EdDSA" {
+		t.Fatalf("signed %v after the rotation", token.Header["alg"])
+	}
+
+	// a failed reload keeps the keys
+	keys := ring.Keys()
+	bad := writeKey(t, dir, "bad.pem", []byte("not a key"))
+	for name, files := range map[string][]string{
+		"missing file":      {filepath.Join(dir, "missing.pem"), oldFile},
+		"bad file":          {newFile, bad},
+		"public signing":    {writeKey(t, dir, "public.pem", pkix(t, &rsaKey.PublicKey))},
+		"same key twice":    {newFile, newFile},
+		"no keys or secret": nil,
+	} {
+		if err := ring.Load(files); err == nil {
+			t.Fatalf("%s: loaded", name)
+		}
+		if got := ring.Keys(); len(got) != len(keys) || got[0] != keys[0] || got[1] != keys[1] {
+			t.Fatalf("%s: keys replaced", name)
+		}
+	}
+	if err := ring.Parse(ss, &jwt.StandardClaims{}); err != nil {
+		t.Fatalf("after failed reloads: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Outbox mailer writing each message as an .eml file into Dir, which is
+// created on first use
+type Outbox struct {
+	Dir  string
+	From string
+
+	mu sync.Mutex
+	// seq orders messages written within the same nanosecond
+	seq int
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]+`)
+
+// Send write msg to a new file named after the time and recipient
+func (m *Outbox) Send(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return err
+	}
+	now := time.Now()
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d-%s.eml", now.UTC().Format("20060102T150405.000000000"), m.seq%10000,
+		unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	m.mu.Unlock()
+	return ioutil.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg, now), 0600)
+}
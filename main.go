@@ -1,14 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
 
-	"github.com/gin-gonic/gin"
-	"github.com/jameslahm/conduit-server-gin/controllers"
 	_ "github.com/jameslahm/conduit-server-gin/docs" // docs is generated by Swag CLI, you have to import it.
 	"github.com/joho/godotenv"
-	swaggerFiles "github.com/swaggo/files"
-	"github.com/swaggo/gin-swagger"
 )
 
 // @title Conduit Server
@@ -24,37 +22,31 @@ func main() {
 	if err != nil {
 		log.Println("Load .env error")
 	}
-	r := gin.Default()
 
-	url := ginSwagger.URL("http://localhost:8080/swagger/doc.json")
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
-
-	api := r.Group("/api")
-
-	api.POST("/users/login", controllers.Login)
-	api.POST("/users", controllers.Register)
-	api.GET("/user", controllers.GetCurrentUser)
-	api.PUT("/user", controllers.UpdateUser)
-
-	api.GET("/profiles/:username", controllers.GetProfile)
-	api.POST("/profiles/:username/follow", controllers.FollowUser)
-	api.DELETE("/profiles/:username/follow", controllers.UnFollowUser)
-
-	api.GET("/articles", controllers.GetAllArticles)
-	api.GET("/articles/:slug", controllers.GetArticle)
-	api.GET("/feed", controllers.GetFeedArticles)
-	api.POST("/articles", controllers.CreateArticle)
-	api.PUT("/articles/:slug", controllers.UpdateArticle)
-	api.DELETE("/articles/:slug", controllers.DeleteArticle)
-
-	api.POST("/articles/:slug/comments", controllers.AddComment)
-	api.GET("/articles/:slug/comments", controllers.GetComments)
-	api.DELETE("/articles/:slug/comments/:id", controllers.DeleteComment)
-
-	api.POST("/articles/:slug/favorite", controllers.FavoriteArticle)
-	api.DELETE("/articles/:slug/favorite", controllers.UnFavoriteArticle)
-
-	api.GET("/tags", controllers.GetTags)
-
-	r.Run()
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "serve":
+		err = serve(args)
+	case "migrate":
+		err = migrate(args)
+	case "seed":
+		err = seed(args)
+	case "export":
+		err = exportCommand(args)
+	case "import":
+		err = importCommand(args)
+	case "config":
+		err = configCommand(args)
+	case "role":
+		err = roleCommand(args)
+	default:
+		err = fmt.Errorf("unknown command %q, expected serve, migrate, seed, export, import, config or role", command)
+	}
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 }
@@ -1,31 +1,291 @@
 package middlewares
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/keys"
 	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// TokenInput toekn header
-type TokenInput struct {
-	TokenHeader string `header:"Authorization" binding:"required"`
+// gin context keys of the authenticated *models.User, the token it
+// presented and the claims of that token, or the personal access token it
+// presented instead
+const (
+	userKey        = "conduit.user"
+	tokenKey       = "conduit.token"
+	claimsKey      = "conduit.claims"
+	accessTokenKey = "conduit.accessToken"
+)
+
+// touchInterval how stale LastUsedAt of a personal access token gets
+// before a request updates it
+const touchInterval = time.Minute
+
+// ErrUnauthorized missing, malformed or invalid credentials
+var ErrUnauthorized = errors.New("error: unauthorized")
+
+// ErrEmailUnverified the current user has not verified their email yet
+var ErrEmailUnverified = errors.New("error: email not verified")
+
+// ErrForbidden the role of the current user lacks the permission
+var ErrForbidden = errors.New("error: forbidden")
+
+// ErrSessionRequired the route does not accept personal access tokens
+var ErrSessionRequired = errors.New("error: personal access tokens are not accepted here")
+
+// errNoToken request carries no Authorization header
+var errNoToken = errors.New("error: no token")
+
+// ScopeError the personal access token lacks Scope for the route
+type ScopeError struct {
+	Scope string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("error: token lacks scope %s", e.Scope)
+}
+
+// tokenFromHeader jwt or personal access token of an
+// "Authorization: Token <jwt>" or "Authorization: Bearer <jwt>" header
+func tokenFromHeader(c *gin.Context) (string, error) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return "", errNoToken
+	}
+	parts := strings.Fields(header)
+	if len(parts) != 2 {
+		return "", ErrUnauthorized
+	}
+	if !strings.EqualFold(parts[0], "Token") && !strings.EqualFold(parts[0], "Bearer") {
+		return "", ErrUnauthorized
+	}
+	return parts[1], nil
+}
+
+// Revoked whether claims were revoked on their own or by a revocation of
+// all tokens of their user
+func Revoked(ctx context.Context, claims *models.JwtClaims, user primitive.ObjectID, revocations store.RevocationStore) (bool, error) {
+	found, err := revocations.Find(ctx, claims.Id, models.UserRevocationID(user))
+	if err != nil {
+		return false, err
+	}
+	for _, r := range found {
+		if r.ID == claims.Id {
+			return true, nil
+		}
+		if claims.Issued().Before(r.RevokedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// authenticate user of the request token, errNoToken without one. The
+// token and its claims, or the personal access token, are kept in the
+// context next to the user.
+func authenticate(c *gin.Context, ring *keys.Ring, users store.UserStore, revocations store.RevocationStore, tokens store.AccessTokenStore) error {
+	ss, err := tokenFromHeader(c)
+	if err != nil {
+		return err
+	}
+	if models.IsAccessToken(ss) {
+		return authenticateAccessToken(c, ss, users, tokens)
+	}
+	claims, err := models.VerifyToken(ss, ring)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	id, err := primitive.ObjectIDFromHex(claims.UserID)
+	if err != nil {
+		return ErrUnauthorized
+	}
+	if r, err := Revoked(c.Request.Context(), claims, id, revocations); err != nil || r {
+		if err == nil {
+			err = ErrUnauthorized
+		}
+		return err
+	}
+	user, err := users.FindByID(c.Request.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		// the token outlived its user
+		return ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+	c.Set(userKey, user)
+	c.Set(tokenKey, ss)
+	c.Set(claimsKey, claims)
+	return nil
+}
+
+// authenticateAccessToken user of personal access token ss
+func authenticateAccessToken(c *gin.Context, ss string, users store.UserStore, tokens store.AccessTokenStore) error {
+	ctx := c.Request.Context()
+	token, err := tokens.FindByHash(ctx, models.HashToken(ss))
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if token.Expired(now) {
+		return ErrUnauthorized
+	}
+	user, err := users.FindByID(ctx, token.User)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= touchInterval {
+		// bookkeeping only, a failure does not fail the request
+		if err := tokens.Touch(ctx, token.ID, now); err != nil {
+			c.Error(err)
+		}
+	}
+	c.Set(userKey, user)
+	c.Set(accessTokenKey, token)
+	return nil
+}
+
+// authorize check the personal access token of the request, if any, holds
+// every scope. Without scopes the route takes no personal access tokens.
+func authorize(c *gin.Context, scopes []string) error {
+	token := CurrentAccessToken(c)
+	if token == nil {
+		return nil
+	}
+	if len(scopes) == 0 {
+		return ErrSessionRequired
+	}
+	for _, scope := range scopes {
+		if !token.HasScope(scope) {
+			return &ScopeError{Scope: scope}
+		}
+	}
+	return nil
+}
+
+// abort end the request with the status of err
+func abort(c *gin.Context, err error) {
+	status := http.StatusUnauthorized
+	var scope *ScopeError
+	switch {
+	case errors.Is(err, store.ErrUnavailable):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, ErrSessionRequired), errors.As(err, &scope):
+		status = http.StatusForbidden
+	case errors.Is(err, ErrUnauthorized), errors.Is(err, errNoToken):
+		c.Header("WWW-Authenticate", `Token realm="conduit"`)
+		err = ErrUnauthorized
+	default:
+		status = http.StatusInternalServerError
+	}
+	c.AbortWithStatusJSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
+
+// RequireAuth load the user of the request token into the context,
+// answering 401 when there is no valid or unrevoked token. Personal access
+// tokens must hold every one of scopes, answering 403 otherwise, and are
+// refused on routes without scopes.
+func RequireAuth(ring *keys.Ring, users store.UserStore, revocations store.RevocationStore, tokens store.AccessTokenStore, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := authenticate(c, ring, users, revocations, tokens)
+		if err == nil {
+			err = authorize(c, scopes)
+		}
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.Next()
+	}
 }
 
-// Authenticate auth
-func Authenticate(c *gin.Context) (*models.JwtClaims, error) {
+// OptionalAuth like RequireAuth but a request without Authorization header
+// continues anonymously. A header that does not authenticate is still a
+// 401. Personal access tokens of any scope are accepted.
+func OptionalAuth(ring *keys.Ring, users store.UserStore, revocations store.RevocationStore, tokens store.AccessTokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := authenticate(c, ring, users, revocations, tokens)
+		if err != nil && err != errNoToken {
+			abort(c, err)
+			return
+		}
+		c.Next()
+	}
+}
 
-	var authHeader TokenInput
-	if err := c.ShouldBindHeader(&authHeader); err != nil {
-		return nil, err
+// CurrentUser user loaded by RequireAuth or OptionalAuth, nil for
+// anonymous requests
+func CurrentUser(c *gin.Context) *models.User {
+	if user, ok := c.Get(userKey); ok {
+		return user.(*models.User)
 	}
+	return nil
+}
+
+// CurrentToken access token the current user authenticated with, empty
+// for personal access tokens
+func CurrentToken(c *gin.Context) string {
+	return c.GetString(tokenKey)
+}
 
-	splitStrings := strings.Split(authHeader.TokenHeader, " ")
-	if len(splitStrings) != 2 {
-		return nil, errors.New("error: Bad Request")
+// CurrentAccessToken personal access token the current user authenticated
+// with, nil for sessions and anonymous requests
+func CurrentAccessToken(c *gin.Context) *models.AccessToken {
+	if token, ok := c.Get(accessTokenKey); ok {
+		return token.(*models.AccessToken)
 	}
-	claims, err := models.VerifyToken(splitStrings[1])
-	return claims, err
+	return nil
+}
 
+// CurrentClaims claims of CurrentToken, nil for personal access tokens and
+// anonymous requests
+func CurrentClaims(c *gin.Context) *models.JwtClaims {
+	if claims, ok := c.Get(claimsKey); ok {
+		return claims.(*models.JwtClaims)
+	}
+	return nil
+}
+
+// RequireVerifiedEmail answer 403 to users whose email is not verified,
+// after RequireAuth. Everyone passes when required is false.
+func RequireVerifiedEmail(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); required && (user == nil || !user.EmailVerified) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": ErrEmailUnverified.Error(),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission answer 403 to users whose role lacks permission, after
+// RequireAuth
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); user == nil || !user.Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": ErrForbidden.Error(),
+			})
+			return
+		}
+		c.Next()
+	}
 }
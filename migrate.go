@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+// migrate conduit migrate up|down|status [-dry-run] [-steps N]
+func migrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: conduit migrate up|down|status [-dry-run] [-steps N]")
+	}
+	action := args[0]
+	if action != "up" && action != "down" && action != "status" {
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", action)
+	}
+	flags := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print changes without applying them")
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	cfg, err := config.Load(flags, args[1:])
+	if err != nil {
+		return err
+	}
+	if err := cfg.Store.Validate(); err != nil {
+		return err
+	}
+
+	s, err := openStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close(context.Background())
+	migrator, ok := s.(store.Migrator)
+	if !ok {
+		return errors.New("store does not support migrations")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	switch action {
+	case "status":
+		status, err := migrator.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, m := range status {
+			appliedAt := "pending"
+			if !m.AppliedAt.IsZero() {
+				appliedAt = m.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, appliedAt)
+		}
+		return w.Flush()
+	case "up":
+		applied, err := migrator.MigrateUp(ctx, *dryRun)
+		printSteps("up", applied, *dryRun)
+		return err
+	case "down":
+		if *steps < 1 {
+			return errors.New("steps must be at least 1")
+		}
+		reverted, err := migrator.MigrateDown(ctx, *steps, *dryRun)
+		printSteps("down", reverted, *dryRun)
+		return err
+	}
+	return nil
+}
+
+// printSteps report migrations run in direction
+func printSteps(direction string, steps []store.MigrationStep, dryRun bool) {
+	if len(steps) == 0 {
+		fmt.Println("Nothing to migrate")
+		return
+	}
+	verb := "Migrated"
+	if dryRun {
+		verb = "Would migrate"
+	}
+	for _, step := range steps {
+		fmt.Printf("%s %s %d %s\n", verb, direction, step.Version, step.Name)
+		for _, change := range step.Changes {
+			fmt.Printf("  %s\n", change)
+		}
+	}
+}
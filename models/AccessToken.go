@@ -0,0 +1,83 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccessTokenPrefix start of every personal access token, telling them
+// apart from jwts
+const AccessTokenPrefix = "cpat_"
+
+// scopes of personal access tokens
+const (
+	// ScopeArticlesWrite create, edit, delete, restore and favorite articles
+	ScopeArticlesWrite = "articles:write"
+	// ScopeCommentsWrite add and delete comments
+	ScopeCommentsWrite = "comments:write"
+	// ScopeProfileRead read the current user and their feed
+	ScopeProfileRead = "profile:read"
+	// ScopeProfileWrite follow and unfollow users
+	ScopeProfileWrite = "profile:write"
+)
+
+// Scopes every scope a personal access token can have
+var Scopes = []string{ScopeArticlesWrite, ScopeCommentsWrite, ScopeProfileRead, ScopeProfileWrite}
+
+// AccessToken named personal access token of a user for automation. Only
+// the hash of the token is stored.
+type AccessToken struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	User      primitive.ObjectID `bson:"user" json:"-"`
+	Name      string             `bson:"name" json:"name"`
+	Hash      string             `bson:"hash" json:"-"`
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	// ExpiresAt nil for tokens that never expire
+	ExpiresAt *time.Time `bson:"expiresAt" json:"expiresAt"`
+	// LastUsedAt updated at most once a minute
+	LastUsedAt *time.Time `bson:"lastUsedAt" json:"lastUsedAt"`
+	// Token plain token, only set in the response creating it
+	Token string `bson:"-" json:"token,omitempty"`
+}
+
+// GenerateAccessToken random personal access token
+func GenerateAccessToken() (string, error) {
+	token, err := RandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	return AccessTokenPrefix + token, nil
+}
+
+// IsAccessToken whether token is a personal access token rather than a jwt
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// ValidScope whether scope is one of Scopes
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope whether the token was granted scope
+func (token *AccessToken) HasScope(scope string) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired whether the token expired at now
+func (token *AccessToken) Expired(now time.Time) bool {
+	return token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)
+}
@@ -17,6 +17,8 @@ type ArticleBase struct {
 	CreatedAt      time.Time          `json:"created_at" bson:"createdAt,omitempty"`
 	UpdatedAt      time.Time          `json:"updated_at" bson:"updatedAt,omitempty"`
 	FavoritesCount int                `json:"favoritesCount" bson:"favoritesCount"`
+	// DeletedAt set while the article is soft deleted
+	DeletedAt *time.Time `json:"-" bson:"deletedAt,omitempty"`
 }
 
 // Article article struct
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// audit actions
+const (
+	AuditRoleChanged    = "user.role"
+	AuditUserUnlocked   = "user.unlock"
+	AuditArticleUpdated = "article.update"
+	AuditArticleDeleted = "article.delete"
+	AuditCommentDeleted = "comment.delete"
+)
+
+// AuditEntry privileged action, who did what to which target. Entries
+// outlive their actor, so the username is kept as it was.
+type AuditEntry struct {
+	ID primitive.ObjectID `bson:"_id" json:"id"`
+	// Actor zero for actions from the command line
+	Actor         primitive.ObjectID `bson:"actor" json:"-"`
+	ActorUsername string             `bson:"actorUsername" json:"actor"`
+	Action        string             `bson:"action" json:"action"`
+	// Target username, slug or comment id the action was taken on
+	Target    string    `bson:"target" json:"target"`
+	Detail    string    `bson:"detail" json:"detail"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
@@ -8,8 +8,8 @@ import (
 // CommentBase comment base
 type CommentBase struct {
 	ID        primitive.ObjectID `bson:"_id" json:"-"`
-	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
 	Body      string             `bson:"body" json:"body"`
 	Article   primitive.ObjectID `bson:"article" json:"-"`
 }
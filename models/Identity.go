@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Identity account of a user at an OpenID Connect provider, found by the
+// subject the provider names it with
+type Identity struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Provider string             `bson:"provider"`
+	Subject  string             `bson:"subject"`
+	User     primitive.ObjectID `bson:"user"`
+	// Email the provider reported when the identity was linked
+	Email     string    `bson:"email"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// AudienceOIDC audience of login sessions waiting for the provider to
+// send the user back
+const AudienceOIDC = "conduit:oidc"
+
+// OIDCClaims claims of a login session at Provider, holding what the
+// callback checks and redeems the code with
+type OIDCClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.StandardClaims
+}
+
+// GenerateOIDCToken generate login session of claims signed by the signing
+// key of ring, valid for ttl from now
+func GenerateOIDCToken(claims OIDCClaims, ring *keys.Ring, ttl time.Duration) (string, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        jti,
+		Audience:  AudienceOIDC,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+		Issuer:    "conduit",
+	}
+	return ring.Sign(&claims)
+}
+
+// VerifyOIDCToken verify login session signed by a key of ring
+func VerifyOIDCToken(ss string, ring *keys.Ring) (*OIDCClaims, error) {
+	claims := &OIDCClaims{}
+	if err := ring.Parse(ss, claims); err != nil || !claims.VerifyAudience(AudienceOIDC, true) {
+		return nil, errors.New("error: token invalid")
+	}
+	return claims, nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt failed logins counted for one account or client ip since
+// the counter last started over
+type LoginAttempt struct {
+	// Key AccountAttemptKey, IPAttemptKey or VerificationResendKey
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"lastFailure"`
+	// PreviousFailure the failure before LastFailure, nil for the first
+	PreviousFailure *time.Time `bson:"previousFailure"`
+	// ExpiresAt when the failures are forgotten
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// AccountAttemptKey key of the failed logins of user
+func AccountAttemptKey(user primitive.ObjectID) string {
+	return "account:" + user.Hex()
+}
+
+// IPAttemptKey key of the failed logins from client ip
+func IPAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// VerificationResendKey key counting the verification links resent to
+// user, not failures but limited the same way
+func VerificationResendKey(user primitive.ObjectID) string {
+	return "verify:" + user.Hex()
+}
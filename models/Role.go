@@ -0,0 +1,55 @@
+package models
+
+// Role what a user may do beyond their own content
+type Role string
+
+// roles, users stored before roles existed have RoleUser
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Roles every role, least privileged first
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+// Permission action guarded by role
+type Permission string
+
+// permissions granted by roles
+const (
+	// PermissionModerate edit and delete articles and comments of anyone
+	PermissionModerate Permission = "content:moderate"
+	// PermissionManageUsers list and search users and change their roles
+	PermissionManageUsers Permission = "users:manage"
+	// PermissionReadAudit read the audit log
+	PermissionReadAudit Permission = "audit:read"
+	// PermissionReadStats read the cache counters
+	PermissionReadStats Permission = "stats:read"
+)
+
+// rolePermissions permissions of each role, RoleUser has none
+var rolePermissions = map[Role][]Permission{
+	RoleModerator: {PermissionModerate},
+	RoleAdmin:     {PermissionModerate, PermissionManageUsers, PermissionReadAudit, PermissionReadStats},
+}
+
+// ValidRole whether role is one of Roles
+func ValidRole(role Role) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can whether role grants permission
+func (role Role) Can(permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
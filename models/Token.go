@@ -0,0 +1,69 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken server side state of a refresh token. Only the hash of the
+// token is stored. Each use rotates it to a new token of the same family.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Hash      string             `bson:"hash"`
+	Family    string             `bson:"family"`
+	User      primitive.ObjectID `bson:"user"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	// UsedAt set once the token was exchanged for its successor
+	UsedAt *time.Time `bson:"usedAt,omitempty"`
+	// RevokedAt set when the family was revoked
+	RevokedAt *time.Time `bson:"revokedAt,omitempty"`
+}
+
+// RandomToken url safe random token of n bytes
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken hex sha256 of token, tokens are random so no salt is needed
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Revocation revoked access token jti, or for an id of UserRevocationID
+// every access token of that user issued before RevokedAt. It is kept
+// until ExpiresAt, when the tokens it covers have expired anyway.
+type Revocation struct {
+	ID        string    `bson:"_id"`
+	RevokedAt time.Time `bson:"revokedAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// UserRevocationID revocation id covering all access tokens of user
+func UserRevocationID(user primitive.ObjectID) string {
+	return "user:" + user.Hex()
+}
+
+// PasswordReset server side state of a password reset token mailed to
+// Email, only its hash is stored
+type PasswordReset struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Hash      string             `bson:"hash"`
+	User      primitive.ObjectID `bson:"user"`
+	Email     string             `bson:"email"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	// UsedAt set once the token reset the password, or another token of
+	// the user did
+	UsedAt *time.Time `bson:"usedAt,omitempty"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TwoFactor TOTP second factor of a user, in force once Enabled after the
+// first code was confirmed
+type TwoFactor struct {
+	User   primitive.ObjectID `bson:"_id"`
+	Secret string             `bson:"secret"`
+	// Enabled set when enrollment was confirmed with a valid code
+	Enabled bool `bson:"enabled"`
+	// LastStep time step of the last accepted code, each code is accepted
+	// only once
+	LastStep int64 `bson:"lastStep"`
+	// RecoveryCodes hashes of the unused recovery codes
+	RecoveryCodes []string  `bson:"recoveryCodes"`
+	CreatedAt     time.Time `bson:"createdAt"`
+}
+
+// recoveryEncoding lowercase base32 of recovery codes, its digits 2 to 7
+// don't read like letters
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCode random one-time recovery code formatted as
+// xxxxx-xxxxx
+func GenerateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := recoveryEncoding.EncodeToString(b)[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// HashRecoveryCode hash of a recovery code of user, see HashToken. Case,
+// spaces and dashes do not matter.
+func HashRecoveryCode(user primitive.ObjectID, code string) string {
+	code = strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+	return HashToken(user.Hex() + ":" + code)
+}
+
+// AudienceTwoFactor audience of login challenges waiting for a second
+// factor
+const AudienceTwoFactor = "conduit:two-factor"
+
+// GenerateChallengeToken generate login challenge of user ID signed by the
+// signing key of ring, valid for ttl from now
+func GenerateChallengeToken(ID primitive.ObjectID, ring *keys.Ring, ttl time.Duration) (string, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return ring.Sign(&JwtClaims{
+		UserID:     ID.Hex(),
+		IssuedAtMs: now.UnixNano() / int64(time.Millisecond),
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Audience:  AudienceTwoFactor,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Issuer:    "conduit",
+		},
+	})
+}
+
+// VerifyChallengeToken verify login challenge signed by a key of ring
+func VerifyChallengeToken(ss string, ring *keys.Ring) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+	if err := ring.Parse(ss, claims); err != nil || !claims.VerifyAudience(AudienceTwoFactor, true) {
+		return nil, errors.New("error: token invalid")
+	}
+	return claims, nil
+}
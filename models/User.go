@@ -2,32 +2,45 @@ package models
 
 import (
 	"errors"
-	"log"
-	"os"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/jameslahm/conduit-server-gin/keys"
 	"github.com/jameslahm/conduit-server-gin/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // JwtClaims jwt claims
 type JwtClaims struct {
 	UserID string `json:"id"`
+	// IssuedAtMs issue time in unix milliseconds, iat only has seconds
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.StandardClaims
 }
 
+// Issued issue time of the claims, to the millisecond when known
+func (claims *JwtClaims) Issued() time.Time {
+	if claims.IssuedAtMs != 0 {
+		return time.Unix(0, claims.IssuedAtMs*int64(time.Millisecond))
+	}
+	return time.Unix(claims.IssuedAt, 0)
+}
+
 // User User struct
 type User struct {
-	ID        primitive.ObjectID   `bson:"_id,omitempty" json:"-"`
-	Email     string               `bson:"email,omitempty" json:"email"`
-	Username  string               `bson:"username,omitempty" json:"username"`
-	Password  string               `bson:"password,omitempty" json:"-"`
-	Bio       string               `bson:"bio,omitempty" json:"bio"`
-	Image     string               `bson:"image,omitempty" json:"image"`
-	Token     string               `bson:"-" json:"token"`
-	Following []primitive.ObjectID `bson:"following,omitempty" json:"following"`
-	Favorites []primitive.ObjectID `bson:"favorites,omitempty" json:"favorites"`
+	ID           primitive.ObjectID   `bson:"_id,omitempty" json:"-"`
+	Email        string               `bson:"email,omitempty" json:"email"`
+	Username     string               `bson:"username,omitempty" json:"username"`
+	Password     string               `bson:"password,omitempty" json:"-"`
+	Bio          string               `bson:"bio,omitempty" json:"bio"`
+	Image        string               `bson:"image,omitempty" json:"image"`
+	Token        string               `bson:"-" json:"token"`
+	RefreshToken string               `bson:"-" json:"refreshToken,omitempty"`
+	Following    []primitive.ObjectID `bson:"following,omitempty" json:"following"`
+	Favorites    []primitive.ObjectID `bson:"favorites,omitempty" json:"favorites"`
+	// EmailVerified set once the user followed the link mailed to Email
+	EmailVerified bool `bson:"emailVerified" json:"emailVerified"`
+	Role          Role `bson:"role" json:"role"`
 }
 
 // Profile Profile struct
@@ -38,45 +51,77 @@ type Profile struct {
 	Following bool   `json:"following"`
 }
 
-// GenerateHashPassword generate passsword hash using bcrypt
-func GenerateHashPassword(password string) string {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+// GenerateJwtToken generate access token of user ID signed by the signing
+// key of ring, valid for ttl from now
+func GenerateJwtToken(ID primitive.ObjectID, ring *keys.Ring, ttl time.Duration) (string, error) {
+	jti, err := RandomToken(16)
 	if err != nil {
-		log.Fatal("Error: Generate Password Hash Failed!")
+		return "", err
 	}
-	return string(hash)
-}
-
-// VerifyPassword verify password
-func VerifyPassword(hash string, password string) error {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err
-}
-
-// GenerateJwtToken generate token
-func GenerateJwtToken(ID primitive.ObjectID) (string, error) {
+	now := time.Now()
 	claims := JwtClaims{
 		ID.Hex(),
+		now.UnixNano() / int64(time.Millisecond),
 		jwt.StandardClaims{
-			ExpiresAt: 15000,
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
 			Issuer:    "conduit",
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
-	ss, err := token.SignedString([]byte(os.Getenv("SECRET")))
-	return ss, err
+	return ring.Sign(&claims)
 }
 
-// VerifyToken verify token
-func VerifyToken(ss string) (*JwtClaims, error) {
-	var claims *JwtClaims
-	token, err := jwt.ParseWithClaims(ss, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("SECRET")), nil
+// VerifyToken verify access token signed by a key of ring. Tokens for an
+// audience, like email verification tokens, are no access tokens.
+func VerifyToken(ss string, ring *keys.Ring) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+	if err := ring.Parse(ss, claims); err != nil || claims.Audience != "" {
+		return nil, errors.New("error: token invalid")
+	}
+	return claims, nil
+}
+
+// AudienceVerifyEmail audience of email verification tokens
+const AudienceVerifyEmail = "conduit:verify-email"
+
+// EmailClaims claims of an email verification token, only valid while the
+// user still has the email it was mailed to
+type EmailClaims struct {
+	UserID string `json:"id"`
+	Email  string `json:"email"`
+	jwt.StandardClaims
+}
+
+// GenerateEmailToken generate email verification token of user signed by
+// the signing key of ring, valid for ttl from now
+func GenerateEmailToken(user *User, ring *keys.Ring, ttl time.Duration) (string, error) {
+	now := time.Now()
+	return ring.Sign(&EmailClaims{
+		user.ID.Hex(),
+		user.Email,
+		jwt.StandardClaims{
+			Audience:  AudienceVerifyEmail,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+			Issuer:    "conduit",
+		},
 	})
-	if claims, ok := token.Claims.(*JwtClaims); ok && token.Valid && (err == nil) {
-		return claims, nil
+}
+
+// VerifyEmailToken verify email verification token signed by a key of ring
+func VerifyEmailToken(ss string, ring *keys.Ring) (*EmailClaims, error) {
+	claims := &EmailClaims{}
+	if err := ring.Parse(ss, claims); err != nil || !claims.VerifyAudience(AudienceVerifyEmail, true) {
+		return nil, errors.New("error: token invalid")
 	}
-	return nil, errors.New("error: token invalid")
+	return claims, nil
+}
+
+// Can whether the role of user grants permission
+func (user *User) Can(permission Permission) bool {
+	return user.Role.Can(permission)
 }
 
 // ToProfile to profile
@@ -93,38 +138,3 @@ func (user *User) ToProfile(loginUser *User) Profile {
 	}
 	return profile
 }
-
-// Follow follow
-func (user *User) Follow(userToFollow *User) {
-	user.Following = append(user.Following, userToFollow.ID)
-}
-
-// UnFollow unfollow
-func (user *User) UnFollow(userToUnFollow *User) error {
-	index := utils.IndexOf(user.Following, userToUnFollow.ID)
-	if index == -1 {
-		return errors.New("error: bad request")
-	}
-	user.Following = append(user.Following[0:index], user.Following[index+1:]...)
-	return nil
-}
-
-// Favorite favorite
-func (user *User) Favorite(article *Article) error {
-	index := utils.IndexOf(user.Favorites, article.ID)
-	if index == -1 {
-		user.Favorites = append(user.Favorites, article.ID)
-		return nil
-	}
-	return errors.New("error: already favorite")
-}
-
-// UnFavorite unfavorite
-func (user *User) UnFavorite(article *Article) error {
-	index := utils.IndexOf(user.Favorites, article.ID)
-	if index == -1 {
-		return errors.New("error: no favorite")
-	}
-	user.Favorites = append(user.Favorites[:index], user.Favorites[index+1:]...)
-	return nil
-}
@@ -0,0 +1,204 @@
+// Package oidc relying party of OpenID Connect providers, signing users in
+// with the authorization code flow and PKCE
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRejected the provider refused the code, or the id token it returned
+// does not verify. Anything else went wrong talking to the provider.
+var ErrRejected = errors.New("oidc: rejected")
+
+// maxResponseSize bytes read of a provider response
+const maxResponseSize = 1 << 20
+
+// Provider OpenID Connect provider of Issuer that this server is a client
+// of. Its metadata and keys are discovered on first use.
+type Provider struct {
+	// Issuer url, the metadata is discovered at
+	// Issuer/.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL page the provider sends the code to
+	RedirectURL string
+	Scopes      []string
+	// Client http client, http.DefaultClient when nil
+	Client *http.Client
+
+	mu       sync.Mutex
+	metadata *Metadata
+	keys     keySet
+}
+
+// Metadata provider metadata of OpenID Connect Discovery
+type Metadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	JWKSURI                       string   `json:"jwks_uri"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+func (p *Provider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return http.DefaultClient
+}
+
+// getJSON decode the json response of a GET of u into v
+func (p *Provider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("oidc: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s: %s", u, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("oidc: GET %s: %w", u, err)
+	}
+	return nil
+}
+
+// Discover metadata of the provider, fetched once and kept after that
+func (p *Provider) Discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	metadata := p.metadata
+	p.mu.Unlock()
+	if metadata != nil {
+		return metadata, nil
+	}
+
+	metadata = &Metadata{}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, err
+	}
+	// OpenID Connect Discovery 4.3, tokens of another issuer never verify
+	if metadata.Issuer != p.Issuer {
+		return nil, fmt.Errorf("oidc: metadata of issuer %q, expected %q", metadata.Issuer, p.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: metadata of %s lacks an endpoint", p.Issuer)
+	}
+	if methods := metadata.CodeChallengeMethodsSupported; len(methods) > 0 && !contains(methods, "S256") {
+		return nil, fmt.Errorf("oidc: %s does not support PKCE with S256", p.Issuer)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// AuthCodeURL authorization endpoint url the user is sent to, asking for a
+// code bound to the challenge of verifier and an id token carrying nonce
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("oidc: authorization endpoint: %w", err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(p.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", Challenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// tokenResponse token endpoint response, or its error of RFC 6749 5.2
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeem code with the verifier of its challenge and return the
+// verified id token, which must carry nonce
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (*IDToken, error) {
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		// client_secret_basic, RFC 6749 2.3.1 form encodes both parts first
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("oidc: token endpoint %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode == http.StatusBadRequest && token.Error != "" {
+		// invalid_grant and friends, the code or verifier is to blame
+		return nil, fmt.Errorf("%w: %s %s", ErrRejected, token.Error, token.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc: token endpoint: %s %s", resp.Status, token.Error)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id token, is the openid scope requested?", ErrRejected)
+	}
+	return p.Verify(ctx, token.IDToken, nonce)
+}
+
+// tokenLeeway clock skew tolerated on exp and iat
+const tokenLeeway = time.Minute
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/jameslahm/conduit-server-gin/oidc"
+	"github.com/jameslahm/conduit-server-gin/oidc/oidctest"
+)
+
+// start stand-in provider and a client of it
+func start(t *testing.T, secret string) (*oidctest.Provider, *oidc.Provider) {
+	server, err := oidctest.NewProvider("conduit", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	return server, &oidc.Provider{
+		Issuer:       server.URL,
+		ClientID:     "conduit",
+		ClientSecret: secret,
+		RedirectURL:  "http://localhost:4100/oidc/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}
+}
+
+// authorize sign in user at server and return the code granted to a
+// login of p with verifier and nonce
+func authorize(t *testing.T, server *oidctest.Provider, p *oidc.Provider, user oidctest.User, verifier string, nonce string) string {
+	server.SignIn(user)
+	authURL, err := p.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	code, state, err := server.Authorize(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state" {
+		t.Fatalf("state %q came back", state)
+	}
+	return code
+}
+
+func TestExchange(t *testing.T) {
+	for _, secret := range []string{"s3cret:with&specials", ""} {
+		server, p := start(t, secret)
+		user := oidctest.User{Subject: "42", Email: "ada@example.com", EmailVerified: true, PreferredUsername: "ada"}
+		verifier, err := oidc.GenerateVerifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := authorize(t, server, p, user, verifier, "nonce")
+
+		token, err := p.Exchange(context.Background(), code, verifier, "nonce")
+		if err != nil {
+			t.Fatalf("exchange with secret %q: %v", secret, err)
+		}
+		if token.Subject != "42" || token.Email != "ada@example.com" || !bool(token.EmailVerified) || token.PreferredUsername != "ada" {
+			t.Fatalf("claims %+v", token)
+		}
+
+		if _, err := p.Exchange(context.Background(), code, verifier, "nonce"); !errors.Is(err, oidc.ErrRejected) {
+			t.Fatalf("code redeemed twice: %v", err)
+		}
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	server, p := start(t, "secret")
+	user := oidctest.User{Subject: "42", Email: "ada@example.com"}
+	verifier, _ := oidc.GenerateVerifier()
+	other, _ := oidc.GenerateVerifier()
+
+	code := authorize(t, server, p, user, verifier, "nonce")
+	if _, err := p.Exchange(context.Background(), code, other, "nonce"); !errors.Is(err, oidc.ErrRejected) {
+		t.Fatalf("wrong verifier: %v", err)
+	}
+
+	code = authorize(t, server, p, user, verifier, "nonce")
+	if _, err := p.Exchange(context.Background(), code, verifier, "other nonce"); !errors.Is(err, oidc.ErrRejected) {
+		t.Fatalf("wrong nonce: %v", err)
+	}
+
+	wrongClient := &oidc.Provider{Issuer: p.Issuer, ClientID: p.ClientID, ClientSecret: "guess", RedirectURL: p.RedirectURL}
+	code = authorize(t, server, p, user, verifier, "nonce")
+	if _, err := wrongClient.Exchange(context.Background(), code, verifier, "nonce"); err == nil || errors.Is(err, oidc.ErrRejected) {
+		t.Fatalf("wrong client secret is a configuration error, not a rejection: %v", err)
+	}
+}
+
+func TestVerifyRejectsForeignTokens(t *testing.T) {
+	_, p := start(t, "secret")
+	other, q := start(t, "secret")
+	verifier, _ := oidc.GenerateVerifier()
+	code := authorize(t, other, q, oidctest.User{Subject: "42"}, verifier, "nonce")
+
+	// redeem by hand for the raw id token
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {code},
+		"redirect_uri": {q.RedirectURL}, "code_verifier": {verifier},
+		"client_id": {"conduit"}, "client_secret": {"secret"}}
+	resp, err := http.PostForm(other.URL+"/token", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.IDToken == "" {
+		t.Fatalf("token endpoint: %v", err)
+	}
+
+	if _, err := q.Verify(context.Background(), body.IDToken, "nonce"); err != nil {
+		t.Fatalf("own token: %v", err)
+	}
+	if _, err := p.Verify(context.Background(), body.IDToken, "nonce"); !errors.Is(err, oidc.ErrRejected) {
+		t.Fatalf("token of another provider: %v", err)
+	}
+	tampered := body.IDToken[:len(body.IDToken)-4] + "AAAA"
+	if _, err := q.Verify(context.Background(), tampered, "nonce"); !errors.Is(err, oidc.ErrRejected) {
+		t.Fatalf("tampered token: %v", err)
+	}
+}
+
+func TestDiscoverChecksIssuer(t *testing.T) {
+	server, p := start(t, "secret")
+	p.Issuer = server.URL + "/"
+	if _, err := p.Discover(context.Background()); err == nil {
+		t.Fatal("metadata of another issuer accepted")
+	}
+}
@@ -0,0 +1,268 @@
+// Package oidctest stand-in OpenID Connect provider for tests, issuing
+// RS256 id tokens for whichever user was signed in last
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"github.com/jameslahm/conduit-server-gin/oidc"
+)
+
+// User identity the provider signs in
+type User struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+// grant code waiting to be redeemed at the token endpoint
+type grant struct {
+	user        User
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// Provider stand-in provider serving discovery, jwks, authorize and token
+// endpoints. The authorize endpoint redirects right away, signed in as the
+// user of the last SignIn.
+type Provider struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	ring   *keys.Ring
+	keyDir string
+
+	mu     sync.Mutex
+	user   *User
+	grants map[string]*grant
+}
+
+// NewProvider start a provider for the client clientID, clientSecret.
+// Close it when done.
+func NewProvider(clientID string, clientSecret string) (*Provider, error) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "oidctest")
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	ring, err := keys.New(nil, []string{file})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	p := &Provider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		ring:         ring,
+		keyDir:       dir,
+		grants:       make(map[string]*grant),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	return p, nil
+}
+
+// Close shut the server down
+func (p *Provider) Close() {
+	p.Server.Close()
+	os.RemoveAll(p.keyDir)
+}
+
+// SignIn user the authorize endpoint grants codes for from now on
+func (p *Provider) SignIn(user User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = &user
+}
+
+// Authorize follow authURL like a browser would and return the code and
+// state the provider redirects back with
+func (p *Provider) Authorize(authURL string) (code string, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", errors.New("oidctest: authorize: " + resp.Status)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return "", "", err
+	}
+	query := location.Query()
+	if e := query.Get("error"); e != "" {
+		return "", "", errors.New("oidctest: authorize: " + e)
+	}
+	return query.Get("code"), query.Get("state"), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Metadata{
+		Issuer:                        p.URL,
+		AuthorizationEndpoint:         p.URL + "/authorize",
+		TokenEndpoint:                 p.URL + "/token",
+		JWKSURI:                       p.URL + "/jwks",
+		CodeChallengeMethodsSupported: []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.ring.JWKS())
+}
+
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() || query.Get("client_id") != p.ClientID {
+		http.Error(w, "invalid client or redirect uri", http.StatusBadRequest)
+		return
+	}
+	back := redirect.Query()
+	back.Set("state", query.Get("state"))
+	p.mu.Lock()
+	user := p.user
+	p.mu.Unlock()
+	switch {
+	case query.Get("response_type") != "code":
+		back.Set("error", "unsupported_response_type")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		back.Set("error", "invalid_request")
+	case user == nil:
+		back.Set("error", "access_denied")
+	default:
+		code, err := randomString()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.mu.Lock()
+		p.grants[code] = &grant{
+			user:        *user,
+			redirectURI: query.Get("redirect_uri"),
+			challenge:   query.Get("code_challenge"),
+			nonce:       query.Get("nonce"),
+		}
+		p.mu.Unlock()
+		back.Set("code", code)
+	}
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if id != p.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(p.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	// codes are single use, a failed attempt burns them too
+	p.mu.Lock()
+	g, ok := p.grants[r.PostForm.Get("code")]
+	delete(p.grants, r.PostForm.Get("code"))
+	p.mu.Unlock()
+	if !ok || g.redirectURI != r.PostForm.Get("redirect_uri") || oidc.Challenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	idToken, err := p.ring.Sign(jwt.MapClaims{
+		"iss":                p.URL,
+		"sub":                g.user.Subject,
+		"aud":                p.ClientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              g.nonce,
+		"email":              g.user.Email,
+		"email_verified":     g.user.EmailVerified,
+		"name":               g.user.Name,
+		"preferred_username": g.user.PreferredUsername,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	accessToken, err := randomString()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+// randomString random url safe string for codes and access tokens
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
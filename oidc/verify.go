@@ -0,0 +1,179 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jameslahm/conduit-server-gin/keys"
+)
+
+// keysRefreshInterval least time between two fetches of the jwks, so
+// tokens with made up kids cannot make us hammer the provider
+const keysRefreshInterval = time.Minute
+
+// keySet jwks of the provider by kid
+type keySet struct {
+	keys    map[string]keys.JWK
+	fetched time.Time
+}
+
+// IDToken claims of a verified id token
+type IDToken struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience audience `json:"aud"`
+	// AuthorizedParty client the token was issued to when it has several
+	// audiences
+	AuthorizedParty   string   `json:"azp"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// Valid times of the token, the rest is checked by Verify
+func (t *IDToken) Valid() error {
+	now := time.Now()
+	if t.ExpiresAt == 0 || now.After(time.Unix(t.ExpiresAt, 0).Add(tokenLeeway)) {
+		return errors.New("token is expired")
+	}
+	if now.Add(tokenLeeway).Before(time.Unix(t.IssuedAt, 0)) {
+		return errors.New("token used before issued")
+	}
+	return nil
+}
+
+// audience aud claim, a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// flexBool boolean claim some providers send as the string "true"
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*b = v == true || v == "true"
+	return nil
+}
+
+// Verify check the signature, issuer, audience, expiry and nonce of the
+// id token raw and return its claims
+func (p *Provider) Verify(ctx context.Context, raw string, nonce string) (*IDToken, error) {
+	token := &IDToken{}
+	// a provider that cannot be reached rejects nothing
+	var fetchErr error
+	_, err := jwt.ParseWithClaims(raw, token, func(t *jwt.Token) (interface{}, error) {
+		// only asymmetric algorithms, the client secret is no key
+		switch t.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		default:
+			if t.Method != keys.SigningMethodEdDSA {
+				return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+			}
+		}
+		kid, _ := t.Header["kid"].(string)
+		jwk, err := p.key(ctx, kid)
+		if err != nil {
+			if err != keys.ErrUnknownKey {
+				fetchErr = err
+			}
+			return nil, err
+		}
+		if jwk.Alg != "" && jwk.Alg != t.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s, not %s", kid, jwk.Alg, t.Method.Alg())
+		}
+		return jwk.PublicKey()
+	})
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: id token: %v", ErrRejected, err)
+	}
+	switch {
+	case token.Issuer != p.Issuer:
+		err = fmt.Errorf("issuer %q", token.Issuer)
+	case !contains(token.Audience, p.ClientID):
+		err = fmt.Errorf("audience %q", token.Audience)
+	case len(token.Audience) > 1 && token.AuthorizedParty != p.ClientID:
+		err = fmt.Errorf("authorized party %q", token.AuthorizedParty)
+	case token.Nonce != nonce:
+		err = errors.New("nonce mismatch")
+	case token.Subject == "":
+		err = errors.New("no subject")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: id token: %v", ErrRejected, err)
+	}
+	return token, nil
+}
+
+// key jwk of kid, fetching the jwks again when kid is new, as after a key
+// rotation of the provider. A token without kid takes the only key.
+func (p *Provider) key(ctx context.Context, kid string) (keys.JWK, error) {
+	p.mu.Lock()
+	set := p.keys
+	p.mu.Unlock()
+	if jwk, ok := set.find(kid); ok {
+		return jwk, nil
+	}
+	if time.Since(set.fetched) < keysRefreshInterval {
+		return keys.JWK{}, keys.ErrUnknownKey
+	}
+
+	metadata, err := p.Discover(ctx)
+	if err != nil {
+		return keys.JWK{}, err
+	}
+	var jwks keys.JWKS
+	if err := p.getJSON(ctx, metadata.JWKSURI, &jwks); err != nil {
+		return keys.JWK{}, err
+	}
+	set = keySet{keys: make(map[string]keys.JWK), fetched: time.Now()}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use == "" || jwk.Use == "sig" {
+			set.keys[jwk.Kid] = jwk
+		}
+	}
+	p.mu.Lock()
+	p.keys = set
+	p.mu.Unlock()
+
+	if jwk, ok := set.find(kid); ok {
+		return jwk, nil
+	}
+	return keys.JWK{}, keys.ErrUnknownKey
+}
+
+func (s keySet) find(kid string) (keys.JWK, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, jwk := range s.keys {
+			return jwk, true
+		}
+	}
+	jwk, ok := s.keys[kid]
+	return jwk, ok
+}
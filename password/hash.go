@@ -0,0 +1,133 @@
+// Package password hashing of user passwords with bcrypt or argon2id, and
+// the policy new passwords must follow
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// algorithms of new hashes
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+// Algorithms every algorithm new hashes can use
+var Algorithms = []string{Bcrypt, Argon2id}
+
+// BcryptMaxBytes longest password bcrypt tells apart, it ignores the bytes
+// after
+const BcryptMaxBytes = 72
+
+const (
+	// saltSize bytes of an argon2id salt
+	saltSize = 16
+	// keySize bytes of an argon2id key
+	keySize = 32
+)
+
+// ErrMismatch password does not match the hash, or there is no valid hash
+// like for users who only log in through a provider
+var ErrMismatch = errors.New("error: password incorrect")
+
+// errMalformed hash is no argon2id hash in the PHC string format
+var errMalformed = errors.New("password: malformed argon2id hash")
+
+var encoding = base64.RawStdEncoding
+
+// Argon2Params cost of argon2id hashes, see argon2.IDKey
+type Argon2Params struct {
+	Time uint32
+	// Memory KiB
+	Memory  uint32
+	Threads uint8
+}
+
+// Hasher hash new passwords with Algorithm, verifying hashes of every
+// algorithm and cost
+type Hasher struct {
+	// Algorithm Bcrypt or Argon2id
+	Algorithm  string
+	BcryptCost int
+	Argon2     Argon2Params
+}
+
+// Hash hash of password with the algorithm and cost of h
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.Algorithm == Argon2id {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		p := h.Argon2
+		key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, keySize)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Memory, p.Time, p.Threads, encoding.EncodeToString(salt), encoding.EncodeToString(key)), nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Verify whether password matches hash, ErrMismatch when it does not
+func (h *Hasher) Verify(hash string, password string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return ErrMismatch
+		}
+		other := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash whether hash was made with another algorithm or cost than h
+// would use now
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if h.Algorithm == Argon2id {
+		p, _, _, err := parseArgon2id(hash)
+		return err != nil || p != h.Argon2
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.BcryptCost
+}
+
+// parseArgon2id parameters, salt and key of an argon2id hash in the PHC
+// string format
+func parseArgon2id(hash string) (p Argon2Params, salt []byte, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return p, nil, nil, errMalformed
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errMalformed
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return p, nil, nil, errMalformed
+	}
+	if salt, err = encoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, errMalformed
+	}
+	if key, err = encoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, errMalformed
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,88 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap hashers of both algorithms, the defaults are slow on purpose
+var (
+	bcryptHasher = &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	argonHasher  = &Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Time: 1, Memory: 64, Threads: 1}}
+)
+
+func TestHashVerify(t *testing.T) {
+	for _, h := range []*Hasher{bcryptHasher, argonHasher} {
+		hash, err := h.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("%s: %v", h.Algorithm, err)
+		}
+		if h.Algorithm == Argon2id && !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+			t.Errorf("argon2id hash %s", hash)
+		}
+		if err := h.Verify(hash, "correct horse"); err != nil {
+			t.Errorf("%s: right password: %v", h.Algorithm, err)
+		}
+		if err := h.Verify(hash, "Correct horse"); err != ErrMismatch {
+			t.Errorf("%s: wrong password: %v", h.Algorithm, err)
+		}
+		// hashes of any algorithm verify, whatever h hashes with
+		for _, other := range []*Hasher{bcryptHasher, argonHasher} {
+			if err := other.Verify(hash, "correct horse"); err != nil {
+				t.Errorf("%s hash verified by %s: %v", h.Algorithm, other.Algorithm, err)
+			}
+		}
+		if again, _ := h.Hash("correct horse"); again == hash {
+			t.Errorf("%s: hashes without salt", h.Algorithm)
+		}
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+		"$2a$04$short",
+	} {
+		if err := argonHasher.Verify(hash, ""); err != ErrMismatch {
+			t.Errorf("%q: %v", hash, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := bcryptHasher.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argonHash, err := argonHasher.Hash("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	costlier := &Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost + 1}
+	moreMemory := &Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Time: 1, Memory: 128, Threads: 1}}
+	tests := []struct {
+		h    *Hasher
+		hash string
+		want bool
+	}{
+		{bcryptHasher, bcryptHash, false},
+		{argonHasher, argonHash, false},
+		{bcryptHasher, argonHash, true},
+		{argonHasher, bcryptHash, true},
+		{costlier, bcryptHash, true},
+		{moreMemory, argonHash, true},
+		{argonHasher, "", true},
+		{bcryptHasher, "", true},
+	}
+	for _, tt := range tests {
+		if got := tt.h.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s %+v of %q: %v, want %v", tt.h.Algorithm, tt.h, tt.hash, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package password
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// errBanned password is on the banned list
+	errBanned = errors.New("error: password is too common")
+	// errContainsUsername password contains the username
+	errContainsUsername = errors.New("error: password must not contain the username")
+	// errContainsEmail password contains the email or its local part
+	errContainsEmail = errors.New("error: password must not contain the email")
+)
+
+// minIdentity shortest username or local part of an email looked for in
+// passwords, shorter ones would reject too many passwords by chance
+const minIdentity = 3
+
+// Policy rules new passwords must follow
+type Policy struct {
+	// MinLength fewest characters
+	MinLength int
+	// MaxLength most characters, none when 0
+	MaxLength int
+	// MaxBytes most bytes of the UTF-8 encoding, none when 0. Set to
+	// BcryptMaxBytes under bcrypt, a few multi-byte characters reach it
+	// before MaxLength.
+	MaxBytes int
+	// Banned lower case passwords rejected regardless of case
+	Banned map[string]bool
+}
+
+// LoadBanned passwords of the file at path, one per line, skipping blank
+// lines and lines starting with #. An empty path bans none.
+func LoadBanned(path string) (map[string]bool, error) {
+	banned := make(map[string]bool)
+	if path == "" {
+		return banned, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("banned passwords: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			banned[strings.ToLower(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("banned passwords: %s: %w", path, err)
+	}
+	return banned, nil
+}
+
+// Check whether password may be the new password of the user with
+// username and email, ignoring case when comparing
+func (p *Policy) Check(password string, username string, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("error: password must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("error: password must be at most %d characters", p.MaxLength)
+	}
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return fmt.Errorf("error: password must be at most %d bytes, accented letters and symbols take more than one", p.MaxBytes)
+	}
+	lower := strings.ToLower(password)
+	if p.Banned[lower] {
+		return errBanned
+	}
+	if username = strings.ToLower(username); len(username) >= minIdentity && strings.Contains(lower, username) {
+		return errContainsUsername
+	}
+	email = strings.ToLower(email)
+	if at := strings.LastIndex(email, "@"); at >= minIdentity && strings.Contains(lower, email[:at]) {
+		return errContainsEmail
+	}
+	if email != "" && strings.Contains(lower, email) {
+		return errContainsEmail
+	}
+	return nil
+}
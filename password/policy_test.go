@@ -0,0 +1,85 @@
+package password
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p := &Policy{MinLength: 8, MaxLength: 16, Banned: map[string]bool{"password1": true}}
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"correct horse", true},
+		{"short", false},
+		// characters, not bytes
+		{"ßßßßßßßß", true},
+		{"seventeen letters", false},
+		{"sixteen  letters", true},
+		{"PassWord1", false},
+		{"i am ada99 ok", false},
+		{"ADA99 rules", false},
+		{"x ada@example.com", false},
+		{"from example.com", true},
+	}
+	for _, tt := range tests {
+		if err := p.Check(tt.password, "ada99", "ada@example.com"); (err == nil) != tt.ok {
+			t.Errorf("%q: %v, want ok %v", tt.password, err, tt.ok)
+		}
+	}
+
+	// identities shorter than minIdentity are not looked for
+	if err := p.Check("the zoo keeper", "zo", "zo@example.com"); err != nil {
+		t.Errorf("short identity: %v", err)
+	}
+	// no limit without MaxLength
+	if err := (&Policy{MinLength: 1}).Check("a very long password that goes on and on", "", ""); err != nil {
+		t.Errorf("without MaxLength: %v", err)
+	}
+
+	// 64 characters of two bytes each are more than bcrypt reads
+	bcrypt := &Policy{MinLength: 8, MaxLength: 64, MaxBytes: BcryptMaxBytes}
+	for _, tt := range []struct {
+		password string
+		ok       bool
+	}{
+		{strings.Repeat("a", 64), true},
+		{strings.Repeat("ß", 36), true},
+		{strings.Repeat("ß", 37), false},
+		{strings.Repeat("ß", 64), false},
+	} {
+		if err := bcrypt.Check(tt.password, "", ""); (err == nil) != tt.ok {
+			t.Errorf("%d bytes: %v, want ok %v", len(tt.password), err, tt.ok)
+		}
+	}
+}
+
+func TestLoadBanned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "banned.txt")
+	if err := ioutil.WriteFile(path, []byte("# common\nPassword1\n\n  qwertyuiop  \n#commented\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	banned, err := LoadBanned(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(banned) != 2 || !banned["password1"] || !banned["qwertyuiop"] {
+		t.Fatalf("banned %v", banned)
+	}
+
+	if banned, err := LoadBanned(""); err != nil || len(banned) != 0 {
+		t.Fatalf("no file: %v %v", banned, err)
+	}
+	if _, err := LoadBanned(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Fatal("missing file loaded")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+// roleCommand conduit role <username> <role> [flags], how the first admin
+// is made
+func roleCommand(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: conduit role <username> user|moderator|admin [flags]")
+	}
+	username, role := args[0], models.Role(args[1])
+	if !models.ValidRole(role) {
+		return fmt.Errorf("unknown role %q, expected user, moderator or admin", role)
+	}
+	cfg, err := config.Load(flag.NewFlagSet("role", flag.ContinueOnError), args[2:])
+	if err != nil {
+		return err
+	}
+	if err := cfg.Store.Validate(); err != nil {
+		return err
+	}
+	if cfg.Store.Driver == "memory" {
+		return errors.New("the memory store is gone when role exits, use a persistent store")
+	}
+
+	s, err := openStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close(context.Background())
+	if err := checkMigrations(s); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	user, err := s.Users().FindByUsername(ctx, username)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", username, err)
+	}
+	previous := user.Role
+	if _, err := s.Users().Update(ctx, user.ID, store.UserUpdate{Role: &role}); err != nil {
+		return err
+	}
+	// no actor, the command line is trusted like the store itself
+	err = s.Audit().Create(ctx, &models.AuditEntry{
+		ActorUsername: "(command line)",
+		Action:        models.AuditRoleChanged,
+		Target:        username,
+		Detail:        fmt.Sprintf("%s -> %s", previous, role),
+		CreatedAt:     time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	fmt.Printf("%s is now %s\n", username, role)
+	return nil
+}
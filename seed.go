@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/gosimple/slug"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/controllers"
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+// seed conduit seed [-users N] [-articles N] [-comments N] [-favorites N]
+// [-follows N] [-seed N] [-password P] [-wipe]
+func seed(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := flags.Int("users", 20, "number of users")
+	articles := flags.Int("articles", 5, "max articles per user")
+	comments := flags.Int("comments", 4, "max comments per article")
+	favorites := flags.Int("favorites", 10, "max favorites per user")
+	follows := flags.Int("follows", 8, "max users followed per user")
+	seedValue := flags.Int64("seed", 1, "random seed, the same seed yields the same data")
+	password := flags.String("password", "password", "password of every seeded user")
+	wipe := flags.Bool("wipe", false, "delete all existing data first")
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Store.Validate(); err != nil {
+		return err
+	}
+	if cfg.Store.Driver == "memory" {
+		return errors.New("the memory store is gone when seed exits, seed a persistent store")
+	}
+	if *users < 1 {
+		return errors.New("users must be at least 1")
+	}
+
+	s, err := openStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	defer s.Close(context.Background())
+	if err := checkMigrations(s); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if *wipe {
+		wiper, ok := s.(store.Wiper)
+		if !ok {
+			return errors.New("store does not support wiping")
+		}
+		if err := wiper.Wipe(ctx); err != nil {
+			return fmt.Errorf("wipe: %w", err)
+		}
+	}
+
+	g := &seeder{
+		store: s,
+		rng:   rand.New(rand.NewSource(*seedValue)),
+		now:   time.Now(),
+		slugs: make(map[string]bool),
+	}
+	// every user shares one password, hash it once instead of per user
+	hash, err := controllers.PasswordHasher(cfg).Hash(*password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	if err := g.run(ctx, *users, *articles, *comments, *favorites, *follows, hash); err != nil {
+		var duplicate *store.DuplicateError
+		if errors.As(err, &duplicate) {
+			return fmt.Errorf("%w, the store already holds seeded data, run with -wipe", err)
+		}
+		return err
+	}
+	fmt.Printf("Seeded %d users, %d follows, %d articles, %d comments, %d favorites with seed %d, password %q\n",
+		len(g.users), g.follows, len(g.articles), g.comments, g.favorites, *seedValue, *password)
+	return nil
+}
+
+// seeder generates data from one random source so a seed replays exactly
+type seeder struct {
+	store store.Store
+	rng   *rand.Rand
+	now   time.Time
+	slugs map[string]bool
+
+	users     []*models.User
+	articles  []*models.Article
+	follows   int
+	comments  int
+	favorites int
+}
+
+func (g *seeder) run(ctx context.Context, users, articles, comments, favorites, follows int, hash string) error {
+	for i := 0; i < users; i++ {
+		if err := g.createUser(ctx, i, hash); err != nil {
+			return fmt.Errorf("create user: %w", err)
+		}
+	}
+	for _, user := range g.users {
+		for n := g.rng.Intn(articles + 1); n > 0; n-- {
+			if err := g.createArticle(ctx, user); err != nil {
+				return fmt.Errorf("create article: %w", err)
+			}
+		}
+	}
+	for _, user := range g.users {
+		for _, i := range g.pick(len(g.users), follows) {
+			if g.users[i].ID == user.ID {
+				continue
+			}
+			if _, err := g.store.Users().Follow(ctx, user.ID, g.users[i].ID); err != nil {
+				return fmt.Errorf("follow: %w", err)
+			}
+			g.follows++
+		}
+		for _, i := range g.pick(len(g.articles), favorites) {
+			if _, err := g.store.Articles().Favorite(ctx, g.articles[i].ID, user.ID); err != nil {
+				return fmt.Errorf("favorite: %w", err)
+			}
+			g.favorites++
+		}
+	}
+	for _, article := range g.articles {
+		for n := g.rng.Intn(comments + 1); n > 0; n-- {
+			if err := g.createComment(ctx, article); err != nil {
+				return fmt.Errorf("create comment: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// pick up to max distinct indexes below n
+func (g *seeder) pick(n int, max int) []int {
+	if n == 0 {
+		return nil
+	}
+	perm := g.rng.Perm(n)
+	k := g.rng.Intn(max + 1)
+	if k > n {
+		k = n
+	}
+	return perm[:k]
+}
+
+func (g *seeder) createUser(ctx context.Context, i int, hash string) error {
+	first := firstNames[g.rng.Intn(len(firstNames))]
+	last := lastNames[g.rng.Intn(len(lastNames))]
+	// the index keeps usernames and emails unique however names repeat
+	username := fmt.Sprintf("%s%s%d", strings.ToLower(first), strings.ToLower(last), i+1)
+	user := &models.User{
+		Email:    username + "@example.com",
+		Username: username,
+		Password: hash,
+		Bio:      g.sentence(),
+		// example.com mailboxes could never verify
+		EmailVerified: true,
+	}
+	if err := g.store.Users().Create(ctx, user); err != nil {
+		return err
+	}
+	g.users = append(g.users, user)
+	return nil
+}
+
+func (g *seeder) createArticle(ctx context.Context, author *models.User) error {
+	var article models.Article
+	article.Title = g.title()
+	article.Description = g.sentence()
+	article.Body = g.markdown()
+	article.TagList = g.tags()
+	article.Author = author.ID
+	article.Slug = slug.Make(article.Title)
+	for n := 2; g.slugs[article.Slug]; n++ {
+		article.Slug = fmt.Sprintf("%s-%d", slug.Make(article.Title), n)
+	}
+	g.slugs[article.Slug] = true
+	article.CreatedAt = g.now.Add(-time.Duration(g.rng.Int63n(int64(90 * 24 * time.Hour))))
+	article.UpdatedAt = article.CreatedAt
+	if err := g.store.Articles().Create(ctx, &article); err != nil {
+		return err
+	}
+	g.articles = append(g.articles, &article)
+	return nil
+}
+
+func (g *seeder) createComment(ctx context.Context, article *models.Article) error {
+	var comment models.Comment
+	comment.Body = g.paragraph(1 + g.rng.Intn(3))
+	comment.Article = article.ID
+	comment.Author = g.users[g.rng.Intn(len(g.users))].ID
+	// comments follow their article, never later than now
+	comment.CreatedAt = article.CreatedAt.Add(time.Duration(g.rng.Int63n(int64(g.now.Sub(article.CreatedAt)) + 1)))
+	comment.UpdatedAt = comment.CreatedAt
+	if err := g.store.Comments().Create(ctx, &comment); err != nil {
+		return err
+	}
+	g.comments++
+	return nil
+}
+
+func (g *seeder) words(n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = loremWords[g.rng.Intn(len(loremWords))]
+	}
+	return words
+}
+
+func (g *seeder) title() string {
+	words := g.words(3 + g.rng.Intn(5))
+	for i, word := range words {
+		words[i] = strings.Title(word)
+	}
+	return strings.Join(words, " ")
+}
+
+func (g *seeder) sentence() string {
+	sentence := strings.Join(g.words(6+g.rng.Intn(10)), " ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
+
+func (g *seeder) paragraph(sentences int) string {
+	parts := make([]string, sentences)
+	for i := range parts {
+		parts[i] = g.sentence()
+	}
+	return strings.Join(parts, " ")
+}
+
+// markdown body with headings, paragraphs, a list and sometimes a quote or
+// code block
+func (g *seeder) markdown() string {
+	var b strings.Builder
+	b.WriteString(g.paragraph(2 + g.rng.Intn(3)))
+	for sections := 1 + g.rng.Intn(3); sections > 0; sections-- {
+		fmt.Fprintf(&b, "\n\n## %s\n\n%s", g.title(), g.paragraph(2+g.rng.Intn(4)))
+		switch g.rng.Intn(4) {
+		case 0:
+			fmt.Fprintf(&b, "\n\n> %s", g.sentence())
+		case 1:
+			fmt.Fprintf(&b, "\n\n```go\nfunc %s() error {\n\treturn nil\n}\n```", strings.Join(g.words(2), "_"))
+		}
+		b.WriteString("\n")
+		for items := 2 + g.rng.Intn(3); items > 0; items-- {
+			fmt.Fprintf(&b, "\n- **%s** %s", g.words(1)[0], g.sentence())
+		}
+	}
+	return b.String()
+}
+
+// tags one to four distinct tags
+func (g *seeder) tags() []string {
+	perm := g.rng.Perm(len(seedTags))
+	tags := make([]string, 1+g.rng.Intn(4))
+	for i := range tags {
+		tags[i] = seedTags[perm[i]]
+	}
+	return tags
+}
+
+var firstNames = []string{
+	"Ada", "Alan", "Barbara", "Brian", "Katherine", "Dennis", "Donald", "Edsger",
+	"Frances", "Grace", "Guido", "Hedy", "Ken", "Linus", "Margaret", "Niklaus",
+	"Radia", "Rob", "Shafi", "Sophie", "Tim", "Yukihiro",
+}
+
+var lastNames = []string{
+	"Allen", "Backus", "Cerf", "Dijkstra", "Goldwasser", "Hamilton", "Hopper",
+	"Kernighan", "Knuth", "Lamarr", "Liskov", "Lovelace", "Perlman", "Pike",
+	"Ritchie", "Shannon", "Thompson", "Torvalds", "Turing", "Wilson", "Wirth",
+}
+
+var seedTags = []string{
+	"go", "gin", "mongodb", "postgres", "sqlite", "react", "vue", "angular",
+	"testing", "devops", "docker", "kubernetes", "security", "performance",
+	"design", "career", "opensource", "tutorial", "architecture", "realworld",
+}
+
+var loremWords = strings.Fields(`lorem ipsum dolor sit amet consectetur
+	adipiscing elit sed do eiusmod tempor incididunt ut labore et dolore magna
+	aliqua enim ad minim veniam quis nostrud exercitation ullamco laboris nisi
+	aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate
+	velit esse cillum fugiat nulla pariatur excepteur sint occaecat cupidatat
+	non proident sunt culpa qui officia deserunt mollit anim id est laborum
+	server request handler cache query index schema deploy latency token`)
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/controllers"
+	"github.com/jameslahm/conduit-server-gin/keys"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/sqlstore"
+	swaggerFiles "github.com/swaggo/files"
+	"github.com/swaggo/gin-swagger"
+)
+
+// serve run the api server until SIGINT or SIGTERM, SIGHUP reloads the
+// signing keys
+func serve(args []string) error {
+	cfg, err := config.Load(flag.NewFlagSet("serve", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	ring, err := keys.New([]byte(cfg.Auth.JWTSecret), cfg.Auth.KeyFiles)
+	if err != nil {
+		return err
+	}
+
+	s, err := openStore(cfg.Store)
+	if err != nil {
+		return fmt.Errorf("open store: %w", err)
+	}
+	if err := checkMigrations(s); err != nil {
+		s.Close(context.Background())
+		return err
+	}
+	if indexer, ok := s.(store.Indexer); ok {
+		ensureIndexes(indexer)
+	}
+	h, err := controllers.NewHandler(s, cfg, ring)
+	if err != nil {
+		s.Close(context.Background())
+		return err
+	}
+
+	r := gin.Default()
+	// a spoofed X-Forwarded-For would dodge the failed login limits per ip
+	r.ForwardedByClientIP = cfg.Server.TrustProxy
+
+	url := ginSwagger.URL(cfg.Server.SwaggerURL)
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
+	h.Routes(r)
+
+	srv := &http.Server{
+		Addr:    ":" + strconv.Itoa(cfg.Server.Port),
+		Handler: r,
+	}
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	go purgeArticles(purgeCtx, s.Articles(), cfg)
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Error: listen: %v", err)
+		}
+	}()
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go reloadKeys(reload, ring, args)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+	signal.Stop(reload)
+	close(reload)
+	stopPurge()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Error: shutdown server: %v", err)
+	}
+	h.Events.Close()
+	if err := s.Close(ctx); err != nil {
+		log.Printf("Error: close store: %v", err)
+	}
+	return nil
+}
+
+// reloadKeys load the key files of the config again on every signal of
+// reload, a failed reload keeps the current keys
+func reloadKeys(reload <-chan os.Signal, ring *keys.Ring, args []string) {
+	for range reload {
+		cfg, err := config.Load(flag.NewFlagSet("serve", flag.ContinueOnError), args)
+		if err == nil {
+			err = ring.Load(cfg.Auth.KeyFiles)
+		}
+		if err != nil {
+			log.Printf("Error: reload keys: %v", err)
+			continue
+		}
+		log.Printf("Reloaded %d signing keys", len(cfg.Auth.KeyFiles))
+	}
+}
+
+// checkMigrations apply schema migrations of sql stores, the tables must
+// exist before serving. Elsewhere data migrations are run explicitly with
+// conduit migrate up, and serving refuses to start while one is pending
+// since handlers expect the fields they add.
+func checkMigrations(s store.Store) error {
+	migrator, ok := s.(store.Migrator)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, ok := s.(*sqlstore.Store); ok {
+		steps, err := migrator.MigrateUp(ctx, false)
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+		for _, step := range steps {
+			log.Printf("Applied migration %d %s", step.Version, step.Name)
+		}
+		return nil
+	}
+	status, err := migrator.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("migration status: %w", err)
+	}
+	var pending []string
+	for _, m := range status {
+		if m.AppliedAt.IsZero() {
+			pending = append(pending, fmt.Sprintf("%d %s", m.Version, m.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations %s, run conduit migrate up first", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// purgeArticles delete articles past the retention window every purge
+// interval until ctx is done
+func purgeArticles(ctx context.Context, articles store.ArticleStore, cfg *config.Config) {
+	ticker := time.NewTicker(cfg.Articles.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := articles.Purge(ctx, time.Now().Add(-cfg.Articles.Retention), controllers.CommentPolicy(cfg))
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Error: purge articles: %v", err)
+				}
+				continue
+			}
+			if report.Articles > 0 {
+				log.Printf("Purged %d deleted articles, %d comments deleted, %d archived, %d favorites removed",
+					report.Articles, report.Comments, report.ArchivedComments, report.Favorites)
+			}
+		}
+	}
+}
+
+// ensureIndexes create missing indexes and log any drift
+func ensureIndexes(indexer store.Indexer) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	report, err := indexer.EnsureIndexes(ctx)
+	if err != nil {
+		log.Printf("Error: ensure indexes: %v", err)
+		return
+	}
+	for _, index := range report.Created {
+		log.Printf("Created index %s", index)
+	}
+	for _, drift := range report.Drift {
+		log.Printf("Warning: index drift %s", drift)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/config"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/memory"
+	"github.com/jameslahm/conduit-server-gin/store/mongostore"
+	"github.com/jameslahm/conduit-server-gin/store/sqlstore"
+)
+
+// openStore open store selected by cfg.Driver, one of mongo, memory,
+// sqlite or postgres
+func openStore(cfg config.StoreConfig) (store.Store, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	switch cfg.Driver {
+	case "memory":
+		return memory.New(), nil
+	case "sqlite":
+		return sqlstore.Open(ctx, sqlstore.SQLite, cfg.DatabaseURL)
+	case "postgres":
+		return sqlstore.Open(ctx, sqlstore.Postgres, cfg.DatabaseURL)
+	case "mongo":
+		return mongostore.Connect(ctx, mongostore.Options{
+			URI:                    cfg.Mongo.URI,
+			Database:               cfg.Mongo.Database,
+			MaxPoolSize:            cfg.Mongo.MaxPoolSize,
+			MinPoolSize:            cfg.Mongo.MinPoolSize,
+			ConnectTimeout:         cfg.Mongo.ConnectTimeout,
+			ServerSelectionTimeout: cfg.Mongo.ServerSelectionTimeout,
+			SocketTimeout:          cfg.Mongo.SocketTimeout,
+			MaxConnIdleTime:        cfg.Mongo.MaxConnIdleTime,
+			ReadPreference:         cfg.Mongo.ReadPreference,
+		})
+	}
+	return nil, fmt.Errorf("unknown store driver %q", cfg.Driver)
+}
@@ -0,0 +1,463 @@
+// Package dump logical backups of a store as newline-delimited json, one
+// file per collection next to a manifest
+package dump
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SchemaVersion version of the record layout written by Export, bump it
+// whenever a record changes incompatibly. Version 2 added deletedAt to
+// articles, version 3 emailVerified and version 4 role to users, older
+// dumps still import.
+const SchemaVersion = 4
+
+// file names inside a dump directory
+const (
+	ManifestFile = "manifest.json"
+	UsersFile    = "users.ndjson"
+	ArticlesFile = "articles.ndjson"
+	CommentsFile = "comments.ndjson"
+)
+
+// Manifest describes a dump
+type Manifest struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	ExportedAt    time.Time `json:"exportedAt"`
+	Users         int64     `json:"users"`
+	Articles      int64     `json:"articles"`
+	Comments      int64     `json:"comments"`
+}
+
+// userRecord line of users.ndjson
+type userRecord struct {
+	ID        primitive.ObjectID   `json:"id"`
+	Email     string               `json:"email"`
+	Username  string               `json:"username"`
+	Password  string               `json:"password"`
+	Bio       string               `json:"bio"`
+	Image     string               `json:"image"`
+	Following []primitive.ObjectID `json:"following"`
+	Favorites []primitive.ObjectID `json:"favorites"`
+	// EmailVerified since version 3, users of older dumps count as verified
+	EmailVerified bool `json:"emailVerified"`
+	// Role since version 4, users of older dumps get models.RoleUser
+	Role models.Role `json:"role,omitempty"`
+}
+
+// articleRecord line of articles.ndjson
+type articleRecord struct {
+	ID             primitive.ObjectID `json:"id"`
+	Slug           string             `json:"slug"`
+	Title          string             `json:"title"`
+	Description    string             `json:"description"`
+	Body           string             `json:"body"`
+	TagList        []string           `json:"tagList"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt"`
+	FavoritesCount int                `json:"favoritesCount"`
+	Author         primitive.ObjectID `json:"author"`
+	DeletedAt      *time.Time         `json:"deletedAt,omitempty"`
+}
+
+// commentRecord line of comments.ndjson
+type commentRecord struct {
+	ID        primitive.ObjectID `json:"id"`
+	Article   primitive.ObjectID `json:"article"`
+	Author    primitive.ObjectID `json:"author"`
+	Body      string             `json:"body"`
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+}
+
+// Export write every user, article and comment of e into dir, the manifest
+// last so a dump without one is known to be incomplete
+func Export(ctx context.Context, e store.Exporter, dir string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{SchemaVersion: SchemaVersion, ExportedAt: time.Now().UTC()}
+
+	err := writeLines(filepath.Join(dir, UsersFile), func(encode func(v interface{}) error) error {
+		return e.EachUser(ctx, func(user *models.User) error {
+			manifest.Users++
+			return encode(userRecord{
+				ID: user.ID, Email: user.Email, Username: user.Username, Password: user.Password,
+				Bio: user.Bio, Image: user.Image, Following: user.Following, Favorites: user.Favorites,
+				EmailVerified: user.EmailVerified, Role: user.Role,
+			})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export users: %w", err)
+	}
+	err = writeLines(filepath.Join(dir, ArticlesFile), func(encode func(v interface{}) error) error {
+		return e.EachArticle(ctx, func(article *models.Article) error {
+			manifest.Articles++
+			return encode(articleRecord{
+				ID: article.ID, Slug: article.Slug, Title: article.Title, Description: article.Description,
+				Body: article.Body, TagList: article.TagList, CreatedAt: article.CreatedAt, UpdatedAt: article.UpdatedAt,
+				FavoritesCount: article.FavoritesCount, Author: article.Author, DeletedAt: article.DeletedAt,
+			})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export articles: %w", err)
+	}
+	err = writeLines(filepath.Join(dir, CommentsFile), func(encode func(v interface{}) error) error {
+		return e.EachComment(ctx, func(comment *models.Comment) error {
+			manifest.Comments++
+			return encode(commentRecord{
+				ID: comment.ID, Article: comment.Article, Author: comment.Author, Body: comment.Body,
+				CreatedAt: comment.CreatedAt, UpdatedAt: comment.UpdatedAt,
+			})
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("export comments: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// writeLines create path and let fn encode one json value per line
+func writeLines(path string, fn func(encode func(v interface{}) error) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := fn(json.NewEncoder(w).Encode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readLines decode each line of path into a fresh value from newValue and
+// pass it to fn with its line number
+func readLines(path string, newValue func() interface{}, fn func(line int, v interface{}) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	decoder := json.NewDecoder(bufio.NewReader(f))
+	decoder.DisallowUnknownFields()
+	for line := 1; ; line++ {
+		v := newValue()
+		if err := decoder.Decode(v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s:%d: %w", filepath.Base(path), line, err)
+		}
+		if err := fn(line, v); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadManifest manifest of the dump in dir, rejecting unknown schema versions
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestFile, err)
+	}
+	if manifest.SchemaVersion < 1 || manifest.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("dump schema version %d is not supported, expected 1 to %d", manifest.SchemaVersion, SchemaVersion)
+	}
+	return &manifest, nil
+}
+
+// IntegrityError problems found verifying a dump
+type IntegrityError struct {
+	Problems []string
+}
+
+func (e *IntegrityError) Error() string {
+	return "dump failed verification:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// maxProblems problems reported before verification gives up
+const maxProblems = 50
+
+// Verify check the dump in dir against its manifest: unique ids, unique
+// emails and usernames, slugs unique among articles that are not deleted,
+// and that every author, article, following and favorites reference points
+// at a record in the dump
+func Verify(dir string) (*Manifest, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	report := func(format string, args ...interface{}) error {
+		problems = append(problems, fmt.Sprintf(format, args...))
+		if len(problems) >= maxProblems {
+			return &IntegrityError{Problems: append(problems, "too many problems, giving up")}
+		}
+		return nil
+	}
+
+	users := make(map[primitive.ObjectID]bool)
+	articles := make(map[primitive.ObjectID]bool)
+	emails := make(map[string]bool)
+	usernames := make(map[string]bool)
+	// slugs of live articles, a deleted article leaves its slug for reuse
+	slugs := make(map[string]bool)
+	// references are checked once every user and article id is known
+	var following, favorites []reference
+	var count int64
+
+	err = readLines(filepath.Join(dir, UsersFile), func() interface{} { return &userRecord{} }, func(line int, v interface{}) error {
+		user := v.(*userRecord)
+		count++
+		where := fmt.Sprintf("%s:%d", UsersFile, line)
+		for _, check := range []struct {
+			ok      bool
+			problem string
+		}{
+			{!user.ID.IsZero(), "missing id"},
+			{!users[user.ID], "duplicate id " + user.ID.Hex()},
+			{!emails[user.Email], "duplicate email " + user.Email},
+			{!usernames[user.Username], "duplicate username " + user.Username},
+		} {
+			if !check.ok {
+				if err := report("%s: %s", where, check.problem); err != nil {
+					return err
+				}
+			}
+		}
+		users[user.ID], emails[user.Email], usernames[user.Username] = true, true, true
+		for _, id := range user.Following {
+			following = append(following, reference{where, id})
+		}
+		for _, id := range user.Favorites {
+			favorites = append(favorites, reference{where, id})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count != manifest.Users {
+		if err := report("%s: %d users, manifest says %d", UsersFile, count, manifest.Users); err != nil {
+			return nil, err
+		}
+	}
+
+	count = 0
+	err = readLines(filepath.Join(dir, ArticlesFile), func() interface{} { return &articleRecord{} }, func(line int, v interface{}) error {
+		article := v.(*articleRecord)
+		count++
+		where := fmt.Sprintf("%s:%d", ArticlesFile, line)
+		live := article.DeletedAt == nil
+		for _, check := range []struct {
+			ok      bool
+			problem string
+		}{
+			{!article.ID.IsZero(), "missing id"},
+			{!articles[article.ID], "duplicate id " + article.ID.Hex()},
+			{!live || !slugs[article.Slug], "duplicate slug " + article.Slug},
+			{users[article.Author], "author " + article.Author.Hex() + " is not in " + UsersFile},
+		} {
+			if !check.ok {
+				if err := report("%s: %s", where, check.problem); err != nil {
+					return err
+				}
+			}
+		}
+		articles[article.ID] = true
+		if live {
+			slugs[article.Slug] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count != manifest.Articles {
+		if err := report("%s: %d articles, manifest says %d", ArticlesFile, count, manifest.Articles); err != nil {
+			return nil, err
+		}
+	}
+
+	count = 0
+	comments := make(map[primitive.ObjectID]bool)
+	err = readLines(filepath.Join(dir, CommentsFile), func() interface{} { return &commentRecord{} }, func(line int, v interface{}) error {
+		comment := v.(*commentRecord)
+		count++
+		where := fmt.Sprintf("%s:%d", CommentsFile, line)
+		for _, check := range []struct {
+			ok      bool
+			problem string
+		}{
+			{!comment.ID.IsZero(), "missing id"},
+			{!comments[comment.ID], "duplicate id " + comment.ID.Hex()},
+			{articles[comment.Article], "article " + comment.Article.Hex() + " is not in " + ArticlesFile},
+			{users[comment.Author], "author " + comment.Author.Hex() + " is not in " + UsersFile},
+		} {
+			if !check.ok {
+				if err := report("%s: %s", where, check.problem); err != nil {
+					return err
+				}
+			}
+		}
+		comments[comment.ID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if count != manifest.Comments {
+		if err := report("%s: %d comments, manifest says %d", CommentsFile, count, manifest.Comments); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ref := range following {
+		if !users[ref.id] {
+			if err := report("%s: following %s is not in %s", ref.where, ref.id.Hex(), UsersFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, ref := range favorites {
+		if !articles[ref.id] {
+			if err := report("%s: favorite %s is not in %s", ref.where, ref.id.Hex(), ArticlesFile); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &IntegrityError{Problems: problems}
+	}
+	return manifest, nil
+}
+
+// reference id found at where
+type reference struct {
+	where string
+	id    primitive.ObjectID
+}
+
+// Import verify the dump in dir and load it into s, keeping every id.
+// Follows and favorites are applied last through the store, favorites of
+// soft deleted articles included, so favorite counts are recounted from the
+// imported favorites rather than taken from favoritesCount. s should be
+// empty.
+func Import(ctx context.Context, s store.Store, dir string) (*Manifest, error) {
+	importer, ok := s.(store.Importer)
+	if !ok {
+		return nil, errors.New("store does not support import")
+	}
+	manifest, err := Verify(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	err = readLines(filepath.Join(dir, UsersFile), func() interface{} { return &userRecord{} }, func(line int, v interface{}) error {
+		user := v.(*userRecord)
+		return s.Users().Create(ctx, &models.User{
+			ID: user.ID, Email: user.Email, Username: user.Username, Password: user.Password,
+			Bio: user.Bio, Image: user.Image, EmailVerified: user.EmailVerified || manifest.SchemaVersion < 3,
+			Role: user.Role,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import users: %w", err)
+	}
+
+	err = readLines(filepath.Join(dir, ArticlesFile), func() interface{} { return &articleRecord{} }, func(line int, v interface{}) error {
+		record := v.(*articleRecord)
+		var article models.Article
+		article.ID = record.ID
+		article.Slug = record.Slug
+		article.Title = record.Title
+		article.Description = record.Description
+		article.Body = record.Body
+		article.TagList = record.TagList
+		article.CreatedAt = record.CreatedAt
+		article.UpdatedAt = record.UpdatedAt
+		article.Author = record.Author
+		article.DeletedAt = record.DeletedAt
+		return s.Articles().Create(ctx, &article)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import articles: %w", err)
+	}
+
+	err = readLines(filepath.Join(dir, CommentsFile), func() interface{} { return &commentRecord{} }, func(line int, v interface{}) error {
+		record := v.(*commentRecord)
+		var comment models.Comment
+		comment.ID = record.ID
+		comment.Article = record.Article
+		comment.Author = record.Author
+		comment.Body = record.Body
+		comment.CreatedAt = record.CreatedAt
+		comment.UpdatedAt = record.UpdatedAt
+		return s.Comments().Create(ctx, &comment)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import comments: %w", err)
+	}
+
+	err = readLines(filepath.Join(dir, UsersFile), func() interface{} { return &userRecord{} }, func(line int, v interface{}) error {
+		user := v.(*userRecord)
+		for _, followee := range user.Following {
+			if _, err := s.Users().Follow(ctx, user.ID, followee); err != nil {
+				return err
+			}
+		}
+		for _, article := range user.Favorites {
+			// a restored article gets its favorites back
+			if err := importer.ImportFavorite(ctx, user.ID, article); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import follows and favorites: %w", err)
+	}
+	return manifest, nil
+}
+
+// ErrNotEmpty store already holds data
+var ErrNotEmpty = errors.New("error: store is not empty")
+
+// Empty ErrNotEmpty when e holds any user
+func Empty(ctx context.Context, e store.Exporter) error {
+	return e.EachUser(ctx, func(user *models.User) error {
+		return ErrNotEmpty
+	})
+}
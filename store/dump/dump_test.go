@@ -0,0 +1,274 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// tempDir removed when t ends
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// writeDump write the records into a new dump directory with a manifest
+// counting them, then let edit change the manifest
+func writeDump(t *testing.T, users []userRecord, articles []articleRecord, comments []commentRecord, edit func(m *Manifest)) string {
+	t.Helper()
+	dir := tempDir(t)
+	manifest := &Manifest{
+		SchemaVersion: SchemaVersion, ExportedAt: time.Now().UTC(),
+		Users: int64(len(users)), Articles: int64(len(articles)), Comments: int64(len(comments)),
+	}
+	if edit != nil {
+		edit(manifest)
+	}
+	for _, file := range []struct {
+		name    string
+		records []interface{}
+	}{
+		{UsersFile, interfaces(len(users), func(i int) interface{} { return users[i] })},
+		{ArticlesFile, interfaces(len(articles), func(i int) interface{} { return articles[i] })},
+		{CommentsFile, interfaces(len(comments), func(i int) interface{} { return comments[i] })},
+	} {
+		err := writeLines(filepath.Join(dir, file.name), func(encode func(v interface{}) error) error {
+			for _, record := range file.records {
+				if err := encode(record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ManifestFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func interfaces(n int, at func(i int) interface{}) []interface{} {
+	values := make([]interface{}, n)
+	for i := range values {
+		values[i] = at(i)
+	}
+	return values
+}
+
+// expectProblems expect Verify of dir to fail with exactly want, each
+// problem matched by substring
+func expectProblems(t *testing.T, dir string, want ...string) {
+	t.Helper()
+	_, err := Verify(dir)
+	var integrity *IntegrityError
+	if !errors.As(err, &integrity) {
+		t.Fatalf("verify = %v, want problems %q", err, want)
+	}
+	if len(integrity.Problems) != len(want) {
+		t.Fatalf("problems %q, want %q", integrity.Problems, want)
+	}
+	for i, problem := range integrity.Problems {
+		if !strings.Contains(problem, want[i]) {
+			t.Errorf("problem %q, want %q", problem, want[i])
+		}
+	}
+}
+
+func TestVerifyCounts(t *testing.T) {
+	ada := userRecord{ID: primitive.NewObjectID(), Email: "ada@example.com", Username: "ada"}
+	article := articleRecord{ID: primitive.NewObjectID(), Slug: "hello", Author: ada.ID}
+	comment := commentRecord{ID: primitive.NewObjectID(), Article: article.ID, Author: ada.ID}
+
+	dir := writeDump(t, []userRecord{ada}, []articleRecord{article}, []commentRecord{comment}, nil)
+	if _, err := Verify(dir); err != nil {
+		t.Fatalf("verify a consistent dump: %v", err)
+	}
+
+	// a truncated file reads as fewer records than the manifest promises
+	dir = writeDump(t, []userRecord{ada}, []articleRecord{article}, []commentRecord{comment}, func(m *Manifest) {
+		m.Users, m.Articles, m.Comments = 2, 3, 0
+	})
+	expectProblems(t, dir,
+		"users.ndjson: 1 users, manifest says 2",
+		"articles.ndjson: 1 articles, manifest says 3",
+		"comments.ndjson: 1 comments, manifest says 0")
+
+	dir = writeDump(t, nil, nil, nil, func(m *Manifest) { m.SchemaVersion = SchemaVersion + 1 })
+	if _, err := Verify(dir); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("verify a newer schema = %v", err)
+	}
+}
+
+func TestVerifyReferences(t *testing.T) {
+	ada := userRecord{ID: primitive.NewObjectID(), Email: "ada@example.com", Username: "ada"}
+	missingUser, missingArticle := primitive.NewObjectID(), primitive.NewObjectID()
+	bob := userRecord{
+		ID: primitive.NewObjectID(), Email: "bob@example.com", Username: "bob",
+		Following: []primitive.ObjectID{ada.ID, missingUser},
+		Favorites: []primitive.ObjectID{missingArticle},
+	}
+	article := articleRecord{ID: primitive.NewObjectID(), Slug: "hello", Author: ada.ID}
+	orphan := articleRecord{ID: primitive.NewObjectID(), Slug: "orphan", Author: missingUser}
+	comments := []commentRecord{
+		{ID: primitive.NewObjectID(), Article: article.ID, Author: bob.ID},
+		{ID: primitive.NewObjectID(), Article: missingArticle, Author: missingUser},
+	}
+
+	dir := writeDump(t, []userRecord{ada, bob}, []articleRecord{article, orphan}, comments, nil)
+	expectProblems(t, dir,
+		"articles.ndjson:2: author "+missingUser.Hex()+" is not in users.ndjson",
+		"comments.ndjson:2: article "+missingArticle.Hex()+" is not in articles.ndjson",
+		"comments.ndjson:2: author "+missingUser.Hex()+" is not in users.ndjson",
+		"users.ndjson:2: following "+missingUser.Hex()+" is not in users.ndjson",
+		"users.ndjson:2: favorite "+missingArticle.Hex()+" is not in articles.ndjson")
+
+	// a failed verification imports nothing
+	s := memory.New()
+	if _, err := Import(context.Background(), s, dir); err == nil {
+		t.Fatal("imported a dump with dangling references")
+	}
+	if err := Empty(context.Background(), s); err != nil {
+		t.Fatalf("store after a failed import: %v", err)
+	}
+}
+
+func TestVerifyDuplicates(t *testing.T) {
+	ada := userRecord{ID: primitive.NewObjectID(), Email: "ada@example.com", Username: "ada"}
+	twin := userRecord{ID: ada.ID, Email: "ada@example.com", Username: "ada"}
+	deletedAt := time.Now()
+	articles := []articleRecord{
+		{ID: primitive.NewObjectID(), Slug: "hello", Author: ada.ID, DeletedAt: &deletedAt},
+		{ID: primitive.NewObjectID(), Slug: "hello", Author: ada.ID},
+		{ID: primitive.NewObjectID(), Slug: "hello", Author: ada.ID},
+	}
+	dir := writeDump(t, []userRecord{ada, twin}, articles, nil, nil)
+	// the deleted article left its slug to the first live one
+	expectProblems(t, dir,
+		"users.ndjson:2: duplicate id",
+		"users.ndjson:2: duplicate email ada@example.com",
+		"users.ndjson:2: duplicate username ada",
+		"articles.ndjson:3: duplicate slug hello")
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	var users []*models.User
+	for _, name := range []string{"ada", "bob", "cy"} {
+		user := &models.User{Email: name + "@example.com", Username: name, Password: "hash of " + name, Bio: name + " writes", EmailVerified: name != "cy"}
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	ada, bob, cy := users[0], users[1], users[2]
+	if _, err := s.Users().Update(ctx, ada.ID, store.UserUpdate{Role: rolePtr(models.RoleAdmin)}); err != nil {
+		t.Fatal(err)
+	}
+	var articles []*models.Article
+	for _, slug := range []string{"first", "second", "gone"} {
+		article := &models.Article{}
+		article.Slug = slug
+		article.Title = "About " + slug
+		article.Body = "body of " + slug
+		article.TagList = []string{"go", slug}
+		article.Author = ada.ID
+		if err := s.Articles().Create(ctx, article); err != nil {
+			t.Fatal(err)
+		}
+		articles = append(articles, article)
+	}
+	for _, follow := range [][2]*models.User{{bob, ada}, {cy, ada}, {cy, bob}} {
+		if _, err := s.Users().Follow(ctx, follow[0].ID, follow[1].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, user := range []*models.User{bob, cy} {
+		for _, article := range articles {
+			if _, err := s.Articles().Favorite(ctx, article.ID, user.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	comment := &models.Comment{Author: bob.ID}
+	comment.Article = articles[0].ID
+	comment.Body = "nice"
+	if err := s.Comments().Create(ctx, comment); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Articles().Delete(ctx, "gone", ada.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := tempDir(t)
+	manifest, err := Export(ctx, s, dir)
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if *manifest != (Manifest{SchemaVersion: SchemaVersion, ExportedAt: manifest.ExportedAt, Users: 3, Articles: 3, Comments: 1}) {
+		t.Fatalf("manifest %+v", manifest)
+	}
+	imported := memory.New()
+	if _, err := Import(ctx, imported, dir); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if err := Empty(ctx, imported); err != ErrNotEmpty {
+		t.Fatalf("imported store empty: %v", err)
+	}
+
+	// exporting the imported store writes the same records
+	again := tempDir(t)
+	if _, err := Export(ctx, imported, again); err != nil {
+		t.Fatalf("export the import: %v", err)
+	}
+	for _, name := range []string{UsersFile, ArticlesFile, CommentsFile} {
+		want, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(filepath.Join(again, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s after the round trip:\n%s\nwant\n%s", name, got, want)
+		}
+	}
+
+	// the deleted article comes back with its favorites
+	restored, err := imported.Articles().Restore(ctx, "gone", ada.ID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.FavoritesCount != 2 {
+		t.Fatalf("favoritesCount of the restored article = %d, want 2", restored.FavoritesCount)
+	}
+}
+
+func rolePtr(role models.Role) *models.Role {
+	return &role
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type accessTokenStore Store
+
+func copyAccessToken(token *models.AccessToken) *models.AccessToken {
+	t := *token
+	t.Scopes = append([]string(nil), token.Scopes...)
+	if token.ExpiresAt != nil {
+		expiresAt := *token.ExpiresAt
+		t.ExpiresAt = &expiresAt
+	}
+	if token.LastUsedAt != nil {
+		lastUsedAt := *token.LastUsedAt
+		t.LastUsedAt = &lastUsedAt
+	}
+	t.Token = ""
+	return &t
+}
+
+func (s *accessTokenStore) Create(ctx context.Context, token *models.AccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[token.User]; !ok {
+		return store.ErrNotFound
+	}
+	if _, ok := s.accessTokens[token.Hash]; ok {
+		return &store.DuplicateError{Field: "hash"}
+	}
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	s.accessTokens[token.Hash] = copyAccessToken(token)
+	return nil
+}
+
+func (s *accessTokenStore) FindByHash(ctx context.Context, hash string) (*models.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.accessTokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyAccessToken(token), nil
+}
+
+func (s *accessTokenStore) List(ctx context.Context, user primitive.ObjectID) ([]*models.AccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := []*models.AccessToken{}
+	for _, token := range s.accessTokens {
+		if token.User == user {
+			tokens = append(tokens, copyAccessToken(token))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+func (s *accessTokenStore) Delete(ctx context.Context, user primitive.ObjectID, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, token := range s.accessTokens {
+		if token.ID == id && token.User == user {
+			delete(s.accessTokens, hash)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
+
+func (s *accessTokenStore) DeleteUser(ctx context.Context, user primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, token := range s.accessTokens {
+		if token.User == user {
+			delete(s.accessTokens, hash)
+		}
+	}
+	return nil
+}
+
+func (s *accessTokenStore) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range s.accessTokens {
+		if token.ID == id {
+			token.LastUsedAt = &at
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
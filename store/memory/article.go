@@ -0,0 +1,265 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type articleStore Store
+
+func (s *articleStore) match(article *models.Article, filter store.ArticleFilter) bool {
+	if article.DeletedAt != nil {
+		return false
+	}
+	if filter.Authors != nil && !containsID(filter.Authors, article.Author) {
+		return false
+	}
+	if filter.IDs != nil && !containsID(filter.IDs, article.ID) {
+		return false
+	}
+	if filter.Tag != "" {
+		for _, tag := range article.TagList {
+			if tag == filter.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// sorted articles newest first, caller must hold the lock
+func (s *articleStore) sorted() []*models.Article {
+	articles := make([]*models.Article, 0, len(s.articles))
+	for _, article := range s.articles {
+		articles = append(articles, article)
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		if articles[i].CreatedAt.Equal(articles[j].CreatedAt) {
+			return articles[i].ID.Hex() > articles[j].ID.Hex()
+		}
+		return articles[i].CreatedAt.After(articles[j].CreatedAt)
+	})
+	return articles
+}
+
+// withAuthor join author, caller must hold the lock
+func (s *articleStore) withAuthor(article *models.Article) (*models.ArticleWithAuthor, bool) {
+	author, ok := s.users[article.Author]
+	if !ok {
+		return nil, false
+	}
+	return &models.ArticleWithAuthor{
+		ArticleBase: copyArticle(article).ArticleBase,
+		Author:      *copyUser(author),
+	}, true
+}
+
+func (s *articleStore) List(ctx context.Context, filter store.ArticleFilter) ([]models.ArticleWithAuthor, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []models.ArticleWithAuthor
+	for _, article := range s.sorted() {
+		if !s.match(article, filter) {
+			continue
+		}
+		if joined, ok := s.withAuthor(article); ok {
+			matched = append(matched, *joined)
+		}
+	}
+	count := int64(len(matched))
+
+	if filter.Offset >= len(matched) {
+		return []models.ArticleWithAuthor{}, count, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, count, nil
+}
+
+// findLive article of slug unless soft deleted, caller must hold the lock.
+// Soft deleted articles give up their slug, only one live article has it.
+func (s *articleStore) findLive(slug string) (*models.Article, bool) {
+	for _, article := range s.articles {
+		if article.Slug == slug && article.DeletedAt == nil {
+			return article, true
+		}
+	}
+	return nil, false
+}
+
+// findLiveByID caller must hold the lock
+func (s *articleStore) findLiveByID(id primitive.ObjectID) (*models.Article, bool) {
+	article, ok := s.articles[id]
+	if !ok || article.DeletedAt != nil {
+		return nil, false
+	}
+	return article, true
+}
+
+func (s *articleStore) FindBySlug(ctx context.Context, slug string) (*models.Article, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	article, ok := s.findLive(slug)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyArticle(article), nil
+}
+
+func (s *articleStore) FindBySlugWithAuthor(ctx context.Context, slug string) (*models.ArticleWithAuthor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	article, ok := s.findLive(slug)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	joined, ok := s.withAuthor(article)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return joined, nil
+}
+
+func (s *articleStore) Create(ctx context.Context, article *models.Article) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if article.ID.IsZero() {
+		article.ID = primitive.NewObjectID()
+	}
+	if article.CreatedAt.IsZero() {
+		article.CreatedAt = time.Now()
+	}
+	if article.UpdatedAt.IsZero() {
+		article.UpdatedAt = article.CreatedAt
+	}
+	if _, ok := s.articles[article.ID]; ok {
+		return errors.New("error: duplicate id")
+	}
+	if _, ok := s.findLive(article.Slug); ok && article.DeletedAt == nil {
+		return &store.DuplicateError{Field: "slug"}
+	}
+	s.articles[article.ID] = copyArticle(article)
+	return nil
+}
+
+func (s *articleStore) Update(ctx context.Context, slug string, author primitive.ObjectID, update store.ArticleUpdate) (*models.Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	article, ok := s.findLive(slug)
+	if !ok || article.Author != author {
+		return nil, store.ErrNotFound
+	}
+	if update.Title != nil {
+		article.Title = *update.Title
+	}
+	if update.Description != nil {
+		article.Description = *update.Description
+	}
+	if update.Body != nil {
+		article.Body = *update.Body
+	}
+	if update.TagList != nil {
+		article.TagList = copyStrings(update.TagList)
+	}
+	article.UpdatedAt = time.Now()
+	return copyArticle(article), nil
+}
+
+func (s *articleStore) Delete(ctx context.Context, slug string, author primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	article, ok := s.findLive(slug)
+	if !ok || article.Author != author {
+		return store.ErrNotFound
+	}
+	deletedAt := time.Now()
+	article.DeletedAt = &deletedAt
+	return nil
+}
+
+func (s *articleStore) Restore(ctx context.Context, slug string, author primitive.ObjectID, deletedAfter time.Time) (*models.Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the latest deletion when the slug was reused and deleted again
+	var article *models.Article
+	for _, a := range s.articles {
+		if a.Slug == slug && a.Author == author && a.DeletedAt != nil && a.DeletedAt.After(deletedAfter) &&
+			(article == nil || a.DeletedAt.After(*article.DeletedAt)) {
+			article = a
+		}
+	}
+	if article == nil {
+		return nil, store.ErrNotFound
+	}
+	if _, ok := s.findLive(slug); ok {
+		return nil, &store.DuplicateError{Field: "slug"}
+	}
+	article.DeletedAt = nil
+	return copyArticle(article), nil
+}
+
+func (s *articleStore) Favorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	article, ok := s.findLiveByID(id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	favoritedBy, ok := s.users[user]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if !containsID(favoritedBy.Favorites, id) {
+		favoritedBy.Favorites = append(favoritedBy.Favorites, id)
+		article.FavoritesCount++
+	}
+	return copyArticle(article), nil
+}
+
+func (s *articleStore) Unfavorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	article, ok := s.findLiveByID(id)
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	favoritedBy, ok := s.users[user]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if containsID(favoritedBy.Favorites, id) {
+		favoritedBy.Favorites = removeID(favoritedBy.Favorites, id)
+		article.FavoritesCount--
+	}
+	return copyArticle(article), nil
+}
+
+func (s *articleStore) Tags(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	tags := []string{}
+	for _, article := range s.articles {
+		if article.DeletedAt != nil {
+			continue
+		}
+		for _, tag := range article.TagList {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
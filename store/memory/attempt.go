@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+)
+
+type loginAttemptStore Store
+
+func copyLoginAttempt(attempt *models.LoginAttempt) *models.LoginAttempt {
+	a := *attempt
+	if attempt.PreviousFailure != nil {
+		previous := *attempt.PreviousFailure
+		a.PreviousFailure = &previous
+	}
+	return &a
+}
+
+func (s *loginAttemptStore) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	attempt, ok := s.loginAttempts[key]
+	if !ok || !attempt.ExpiresAt.After(time.Now()) {
+		return nil, store.ErrNotFound
+	}
+	return copyLoginAttempt(attempt), nil
+}
+
+// Fail count failure, sweeping expired counters on the way
+func (s *loginAttemptStore) Fail(ctx context.Context, key string, at time.Time, ttl time.Duration) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, attempt := range s.loginAttempts {
+		if !attempt.ExpiresAt.After(at) {
+			delete(s.loginAttempts, k)
+		}
+	}
+	attempt, ok := s.loginAttempts[key]
+	if !ok {
+		attempt = &models.LoginAttempt{Key: key}
+		s.loginAttempts[key] = attempt
+	}
+	if attempt.Failures > 0 {
+		previous := attempt.LastFailure
+		attempt.PreviousFailure = &previous
+	}
+	attempt.Failures++
+	attempt.LastFailure = at
+	attempt.ExpiresAt = at.Add(ttl)
+	return copyLoginAttempt(attempt), nil
+}
+
+func (s *loginAttemptStore) Reset(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.loginAttempts, key)
+	}
+	return nil
+}
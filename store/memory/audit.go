@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type auditStore Store
+
+func (s *auditStore) Create(ctx context.Context, entry *models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	e := *entry
+	s.audit = append(s.audit, &e)
+	return nil
+}
+
+func (s *auditStore) List(ctx context.Context, filter store.AuditFilter) ([]*models.AuditEntry, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var matched []*models.AuditEntry
+	// appended in order, newest last
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		entry := s.audit[i]
+		if !filter.Actor.IsZero() && entry.Actor != filter.Actor {
+			continue
+		}
+		if filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		e := *entry
+		matched = append(matched, &e)
+	}
+	count := int64(len(matched))
+
+	if filter.Offset >= len(matched) {
+		return []*models.AuditEntry{}, count, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, count, nil
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// archivedComment comment moved out of every listing by a cascade
+type archivedComment struct {
+	models.Comment
+	ArchivedAt time.Time
+}
+
+// cascade one unit of work, the caller holds the write lock for its whole
+// life so nothing else sees it half done
+type cascade struct {
+	*Store
+	policy store.CommentPolicy
+	now    time.Time
+	report store.CascadeReport
+}
+
+func (s *Store) cascade(comments store.CommentPolicy) *cascade {
+	return &cascade{Store: s, policy: comments, now: time.Now()}
+}
+
+func (c *cascade) removeComment(comment *models.Comment) {
+	if c.policy == store.ArchiveComments {
+		c.archived[comment.ID] = &archivedComment{Comment: *comment, ArchivedAt: c.now}
+		c.report.ArchivedComments++
+	} else {
+		c.report.Comments++
+	}
+	delete(c.comments, comment.ID)
+}
+
+func (c *cascade) removeArticle(id primitive.ObjectID) {
+	for _, comment := range c.comments {
+		if comment.Article == id {
+			c.removeComment(comment)
+		}
+	}
+	for _, user := range c.users {
+		if containsID(user.Favorites, id) {
+			user.Favorites = removeID(user.Favorites, id)
+			c.report.Favorites++
+		}
+	}
+	delete(c.articles, id)
+	c.report.Articles++
+}
+
+func (c *cascade) removeUser(user *models.User) {
+	for id, article := range c.articles {
+		if article.Author == user.ID {
+			c.removeArticle(id)
+		}
+	}
+	for _, comment := range c.comments {
+		if comment.Author == user.ID {
+			c.removeComment(comment)
+		}
+	}
+	for _, id := range user.Favorites {
+		if article, ok := c.articles[id]; ok {
+			article.FavoritesCount--
+			c.report.Favorites++
+		}
+	}
+	for _, other := range c.users {
+		if containsID(other.Following, user.ID) {
+			other.Following = removeID(other.Following, user.ID)
+			c.report.Follows++
+		}
+	}
+	for hash, token := range c.refreshTokens {
+		if token.User == user.ID {
+			delete(c.refreshTokens, hash)
+		}
+	}
+	for hash, reset := range c.passwordResets {
+		if reset.User == user.ID {
+			delete(c.passwordResets, hash)
+		}
+	}
+	delete(c.twoFactors, user.ID)
+	for key, identity := range c.identities {
+		if identity.User == user.ID {
+			delete(c.identities, key)
+		}
+	}
+	for hash, token := range c.accessTokens {
+		if token.User == user.ID {
+			delete(c.accessTokens, hash)
+		}
+	}
+	delete(c.users, user.ID)
+	c.report.Users++
+}
+
+func (s *articleStore) Remove(ctx context.Context, id primitive.ObjectID, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.articles[id]; !ok {
+		return nil, store.ErrNotFound
+	}
+	c := (*Store)(s).cascade(comments)
+	c.removeArticle(id)
+	return &c.report, nil
+}
+
+func (s *articleStore) Purge(ctx context.Context, deletedBefore time.Time, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := (*Store)(s).cascade(comments)
+	for id, article := range s.articles {
+		if article.DeletedAt != nil && article.DeletedAt.Before(deletedBefore) {
+			c.removeArticle(id)
+		}
+	}
+	return &c.report, nil
+}
+
+func (s *userStore) Remove(ctx context.Context, id primitive.ObjectID, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	c := (*Store)(s).cascade(comments)
+	c.removeUser(user)
+	return &c.report, nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type commentStore Store
+
+func (s *commentStore) Create(ctx context.Context, comment *models.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	if comment.UpdatedAt.IsZero() {
+		comment.UpdatedAt = comment.CreatedAt
+	}
+	if _, ok := s.comments[comment.ID]; ok {
+		return errors.New("error: duplicate id")
+	}
+	c := *comment
+	s.comments[comment.ID] = &c
+	return nil
+}
+
+func (s *commentStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	comment, ok := s.comments[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	c := *comment
+	return &c, nil
+}
+
+func (s *commentStore) Delete(ctx context.Context, id primitive.ObjectID, author primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, ok := s.comments[id]
+	if !ok || comment.Author != author {
+		return store.ErrNotFound
+	}
+	delete(s.comments, id)
+	return nil
+}
+
+func (s *commentStore) ListByArticle(ctx context.Context, article primitive.ObjectID) ([]models.CommentWithAuthor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	comments := []models.CommentWithAuthor{}
+	for _, comment := range s.comments {
+		if comment.Article != article {
+			continue
+		}
+		author, ok := s.users[comment.Author]
+		if !ok {
+			continue
+		}
+		comments = append(comments, models.CommentWithAuthor{
+			CommentBase: comment.CommentBase,
+			Author:      *copyUser(author),
+		})
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].ID.Hex() < comments[j].ID.Hex()
+		}
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+	return comments, nil
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sortedIDs ids ordered like the other stores order them
+func sortedIDs(ids []primitive.ObjectID) []primitive.ObjectID {
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].Hex() < ids[j].Hex()
+	})
+	return ids
+}
+
+// EachUser call fn with a copy of every user ordered by id
+func (s *Store) EachUser(ctx context.Context, fn func(user *models.User) error) error {
+	s.mu.RLock()
+	users := make([]*models.User, 0, len(s.users))
+	ids := make([]primitive.ObjectID, 0, len(s.users))
+	for id := range s.users {
+		ids = append(ids, id)
+	}
+	for _, id := range sortedIDs(ids) {
+		users = append(users, copyUser(s.users[id]))
+	}
+	s.mu.RUnlock()
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EachArticle call fn with a copy of every article ordered by id
+func (s *Store) EachArticle(ctx context.Context, fn func(article *models.Article) error) error {
+	s.mu.RLock()
+	articles := make([]*models.Article, 0, len(s.articles))
+	ids := make([]primitive.ObjectID, 0, len(s.articles))
+	for id := range s.articles {
+		ids = append(ids, id)
+	}
+	for _, id := range sortedIDs(ids) {
+		articles = append(articles, copyArticle(s.articles[id]))
+	}
+	s.mu.RUnlock()
+	for _, article := range articles {
+		if err := fn(article); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EachComment call fn with a copy of every comment ordered by id
+func (s *Store) EachComment(ctx context.Context, fn func(comment *models.Comment) error) error {
+	s.mu.RLock()
+	comments := make([]*models.Comment, 0, len(s.comments))
+	ids := make([]primitive.ObjectID, 0, len(s.comments))
+	for id := range s.comments {
+		ids = append(ids, id)
+	}
+	for _, id := range sortedIDs(ids) {
+		comment := *s.comments[id]
+		comments = append(comments, &comment)
+	}
+	s.mu.RUnlock()
+	for _, comment := range comments {
+		if err := fn(comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportFavorite add article, live or soft deleted, to the favorites of user
+func (s *Store) ImportFavorite(ctx context.Context, user primitive.ObjectID, article primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	favorited, ok := s.articles[article]
+	if !ok {
+		return store.ErrNotFound
+	}
+	favoritedBy, ok := s.users[user]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if !containsID(favoritedBy.Favorites, article) {
+		favoritedBy.Favorites = append(favoritedBy.Favorites, article)
+		favorited.FavoritesCount++
+	}
+	return nil
+}
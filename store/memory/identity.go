@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type identityStore Store
+
+// identityKey key of the identity of subject at provider
+func identityKey(provider string, subject string) string {
+	return provider + "\x00" + subject
+}
+
+func (s *identityStore) Find(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	identity, ok := s.identities[identityKey(provider, subject)]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	i := *identity
+	return &i, nil
+}
+
+func (s *identityStore) Create(ctx context.Context, identity *models.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[identity.User]; !ok {
+		return store.ErrNotFound
+	}
+	key := identityKey(identity.Provider, identity.Subject)
+	if _, ok := s.identities[key]; ok {
+		return &store.DuplicateError{Field: "subject"}
+	}
+	if identity.ID.IsZero() {
+		identity.ID = primitive.NewObjectID()
+	}
+	i := *identity
+	s.identities[key] = &i
+	return nil
+}
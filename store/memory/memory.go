@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Store in-memory store, safe for concurrent use
+type Store struct {
+	mu       sync.RWMutex
+	users    map[primitive.ObjectID]*models.User
+	articles map[primitive.ObjectID]*models.Article
+	comments map[primitive.ObjectID]*models.Comment
+	// archived comments moved out by a cascade
+	archived map[primitive.ObjectID]*archivedComment
+	// refreshTokens by hash
+	refreshTokens map[string]*models.RefreshToken
+	// revocations by id, swept of expired entries on every Revoke
+	revocations map[string]*models.Revocation
+	// passwordResets by hash
+	passwordResets map[string]*models.PasswordReset
+	twoFactors     map[primitive.ObjectID]*models.TwoFactor
+	identities     map[string]*models.Identity
+	accessTokens   map[string]*models.AccessToken
+	audit          []*models.AuditEntry
+	// loginAttempts by key, swept of expired entries on every Fail
+	loginAttempts map[string]*models.LoginAttempt
+}
+
+// New create empty in-memory store
+func New() *Store {
+	return &Store{
+		users:    make(map[primitive.ObjectID]*models.User),
+		articles: make(map[primitive.ObjectID]*models.Article),
+		comments: make(map[primitive.ObjectID]*models.Comment),
+		archived: make(map[primitive.ObjectID]*archivedComment),
+
+		refreshTokens:  make(map[string]*models.RefreshToken),
+		revocations:    make(map[string]*models.Revocation),
+		passwordResets: make(map[string]*models.PasswordReset),
+		twoFactors:     make(map[primitive.ObjectID]*models.TwoFactor),
+		identities:     make(map[string]*models.Identity),
+		accessTokens:   make(map[string]*models.AccessToken),
+		loginAttempts:  make(map[string]*models.LoginAttempt),
+	}
+}
+
+// Users user store
+func (s *Store) Users() store.UserStore {
+	return (*userStore)(s)
+}
+
+// Articles article store
+func (s *Store) Articles() store.ArticleStore {
+	return (*articleStore)(s)
+}
+
+// Comments comment store
+func (s *Store) Comments() store.CommentStore {
+	return (*commentStore)(s)
+}
+
+// RefreshTokens refresh token store
+func (s *Store) RefreshTokens() store.RefreshTokenStore {
+	return (*refreshTokenStore)(s)
+}
+
+// Revocations access token revocation store
+func (s *Store) Revocations() store.RevocationStore {
+	return (*revocationStore)(s)
+}
+
+// PasswordResets password reset store
+func (s *Store) PasswordResets() store.PasswordResetStore {
+	return (*passwordResetStore)(s)
+}
+
+// TwoFactors two-factor store
+func (s *Store) TwoFactors() store.TwoFactorStore {
+	return (*twoFactorStore)(s)
+}
+
+// Identities identity store
+func (s *Store) Identities() store.IdentityStore {
+	return (*identityStore)(s)
+}
+
+// AccessTokens personal access token store
+func (s *Store) AccessTokens() store.AccessTokenStore {
+	return (*accessTokenStore)(s)
+}
+
+// Audit audit log store
+func (s *Store) Audit() store.AuditStore {
+	return (*auditStore)(s)
+}
+
+// LoginAttempts failed login counter store
+func (s *Store) LoginAttempts() store.LoginAttemptStore {
+	return (*loginAttemptStore)(s)
+}
+
+// Close nothing to release
+func (s *Store) Close(ctx context.Context) error {
+	return nil
+}
+
+func copyIDs(ids []primitive.ObjectID) []primitive.ObjectID {
+	if ids == nil {
+		return nil
+	}
+	return append([]primitive.ObjectID{}, ids...)
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+func copyUser(user *models.User) *models.User {
+	u := *user
+	u.Following = copyIDs(user.Following)
+	u.Favorites = copyIDs(user.Favorites)
+	return &u
+}
+
+func copyArticle(article *models.Article) *models.Article {
+	a := *article
+	a.TagList = copyStrings(article.TagList)
+	if article.DeletedAt != nil {
+		deletedAt := *article.DeletedAt
+		a.DeletedAt = &deletedAt
+	}
+	return &a
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+	for _, e := range ids {
+		if e == id {
+			return true
+		}
+	}
+	return false
+}
+
+// removeID ids without id, as a new slice
+func removeID(ids []primitive.ObjectID, id primitive.ObjectID) []primitive.ObjectID {
+	var kept []primitive.ObjectID
+	for _, e := range ids {
+		if e != id {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
+
+// Wipe delete all data
+func (s *Store) Wipe(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = make(map[primitive.ObjectID]*models.User)
+	s.articles = make(map[primitive.ObjectID]*models.Article)
+	s.comments = make(map[primitive.ObjectID]*models.Comment)
+	s.archived = make(map[primitive.ObjectID]*archivedComment)
+	s.refreshTokens = make(map[string]*models.RefreshToken)
+	s.revocations = make(map[string]*models.Revocation)
+	s.passwordResets = make(map[string]*models.PasswordReset)
+	s.twoFactors = make(map[primitive.ObjectID]*models.TwoFactor)
+	s.identities = make(map[string]*models.Identity)
+	s.accessTokens = make(map[string]*models.AccessToken)
+	s.audit = nil
+	s.loginAttempts = make(map[string]*models.LoginAttempt)
+	return nil
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/storetest"
+)
+
+func TestConcurrentFavorites(t *testing.T) {
+	storetest.ConcurrentFavorites(t, New())
+}
+
+func TestConcurrentLoginFailures(t *testing.T) {
+	storetest.ConcurrentLoginFailures(t, New())
+}
+
+func TestSlugReuse(t *testing.T) {
+	storetest.SlugReuse(t, func() store.Store { return New() })
+}
+
+func TestDeletedFavorites(t *testing.T) {
+	storetest.DeletedFavorites(t, func() store.Store { return New() })
+}
+
+func TestCascade(t *testing.T) {
+	storetest.Cascade(t, New())
+}
+
+func TestCommentByID(t *testing.T) {
+	storetest.CommentByID(t, New())
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type passwordResetStore Store
+
+func copyPasswordReset(reset *models.PasswordReset) *models.PasswordReset {
+	r := *reset
+	if reset.UsedAt != nil {
+		usedAt := *reset.UsedAt
+		r.UsedAt = &usedAt
+	}
+	return &r
+}
+
+func (s *passwordResetStore) Create(ctx context.Context, reset *models.PasswordReset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := time.Now().Add(-store.PasswordResetRetention)
+	for hash, r := range s.passwordResets {
+		if r.CreatedAt.Before(before) {
+			delete(s.passwordResets, hash)
+		}
+	}
+	if reset.ID.IsZero() {
+		reset.ID = primitive.NewObjectID()
+	}
+	if _, ok := s.passwordResets[reset.Hash]; ok {
+		return &store.DuplicateError{Field: "hash"}
+	}
+	s.passwordResets[reset.Hash] = copyPasswordReset(reset)
+	return nil
+}
+
+func (s *passwordResetStore) CountSince(ctx context.Context, email string, since time.Time) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int64
+	for _, r := range s.passwordResets {
+		if r.Email == email && !r.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *passwordResetStore) Find(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	reset, ok := s.passwordResets[hash]
+	if !ok || reset.UsedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	return copyPasswordReset(reset), nil
+}
+
+func (s *passwordResetStore) Use(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reset, ok := s.passwordResets[hash]
+	if !ok || reset.UsedAt != nil {
+		return nil, store.ErrNotFound
+	}
+	now := time.Now()
+	for _, r := range s.passwordResets {
+		if r.User == reset.User && r.UsedAt == nil {
+			usedAt := now
+			r.UsedAt = &usedAt
+		}
+	}
+	return copyPasswordReset(reset), nil
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type refreshTokenStore Store
+
+func copyRefreshToken(token *models.RefreshToken) *models.RefreshToken {
+	t := *token
+	if token.UsedAt != nil {
+		usedAt := *token.UsedAt
+		t.UsedAt = &usedAt
+	}
+	if token.RevokedAt != nil {
+		revokedAt := *token.RevokedAt
+		t.RevokedAt = &revokedAt
+	}
+	return &t
+}
+
+func (s *refreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	if _, ok := s.refreshTokens[token.Hash]; ok {
+		return &store.DuplicateError{Field: "hash"}
+	}
+	s.refreshTokens[token.Hash] = copyRefreshToken(token)
+	return nil
+}
+
+func (s *refreshTokenStore) Find(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyRefreshToken(token), nil
+}
+
+func (s *refreshTokenStore) Use(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.refreshTokens[hash]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		return copyRefreshToken(token), store.ErrTokenReused
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return copyRefreshToken(token), nil
+}
+
+func (s *refreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.Family == family && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+func (s *refreshTokenStore) RevokeUser(ctx context.Context, user primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, token := range s.refreshTokens {
+		if token.User == user && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+	return nil
+}
+
+type revocationStore Store
+
+func (s *revocationStore) Revoke(ctx context.Context, revocation *models.Revocation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, r := range s.revocations {
+		if !r.ExpiresAt.After(now) {
+			delete(s.revocations, id)
+		}
+	}
+	r := *revocation
+	s.revocations[r.ID] = &r
+	return nil
+}
+
+func (s *revocationStore) Find(ctx context.Context, ids ...string) ([]*models.Revocation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	var found []*models.Revocation
+	for _, id := range ids {
+		if r, ok := s.revocations[id]; ok && r.ExpiresAt.After(now) {
+			c := *r
+			found = append(found, &c)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type twoFactorStore Store
+
+func copyTwoFactor(twoFactor *models.TwoFactor) *models.TwoFactor {
+	t := *twoFactor
+	t.RecoveryCodes = append([]string(nil), twoFactor.RecoveryCodes...)
+	return &t
+}
+
+func (s *twoFactorStore) Find(ctx context.Context, user primitive.ObjectID) (*models.TwoFactor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	twoFactor, ok := s.twoFactors[user]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyTwoFactor(twoFactor), nil
+}
+
+func (s *twoFactorStore) Save(ctx context.Context, twoFactor *models.TwoFactor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[twoFactor.User]; !ok {
+		return store.ErrNotFound
+	}
+	s.twoFactors[twoFactor.User] = copyTwoFactor(twoFactor)
+	return nil
+}
+
+func (s *twoFactorStore) Delete(ctx context.Context, user primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.twoFactors, user)
+	return nil
+}
+
+func (s *twoFactorStore) UseStep(ctx context.Context, user primitive.ObjectID, step int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	twoFactor, ok := s.twoFactors[user]
+	if !ok || step <= twoFactor.LastStep {
+		return store.ErrNotFound
+	}
+	twoFactor.LastStep = step
+	return nil
+}
+
+func (s *twoFactorStore) UseRecoveryCode(ctx context.Context, user primitive.ObjectID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	twoFactor, ok := s.twoFactors[user]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for i, code := range twoFactor.RecoveryCodes {
+		if code == hash {
+			twoFactor.RecoveryCodes = append(twoFactor.RecoveryCodes[:i:i], twoFactor.RecoveryCodes[i+1:]...)
+			return nil
+		}
+	}
+	return store.ErrNotFound
+}
@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type userStore Store
+
+func (s *userStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (s *userStore) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return copyUser(user), nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *userStore) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if user.Username == username {
+			return copyUser(user), nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *userStore) List(ctx context.Context, filter store.UserFilter) ([]*models.User, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	query := strings.ToLower(filter.Query)
+	var matched []*models.User
+	for _, user := range s.users {
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(user.Username), query) && !strings.Contains(strings.ToLower(user.Email), query) {
+			continue
+		}
+		u := copyUser(user)
+		u.Following, u.Favorites = nil, nil
+		matched = append(matched, u)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Username < matched[j].Username
+	})
+	count := int64(len(matched))
+
+	if filter.Offset >= len(matched) {
+		return []*models.User{}, count, nil
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	return matched, count, nil
+}
+
+// unique DuplicateError when email or username is taken by a user other
+// than id, caller must hold the lock
+func (s *userStore) unique(id primitive.ObjectID, email string, username string) error {
+	for _, user := range s.users {
+		if user.ID == id {
+			continue
+		}
+		if user.Email == email {
+			return &store.DuplicateError{Field: "email"}
+		}
+		if user.Username == username {
+			return &store.DuplicateError{Field: "username"}
+		}
+	}
+	return nil
+}
+
+func (s *userStore) Create(ctx context.Context, user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if _, ok := s.users[user.ID]; ok {
+		return errors.New("error: duplicate id")
+	}
+	if err := s.unique(user.ID, user.Email, user.Username); err != nil {
+		return err
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	s.users[user.ID] = copyUser(user)
+	return nil
+}
+
+func (s *userStore) Update(ctx context.Context, id primitive.ObjectID, update store.UserUpdate) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	email, username := user.Email, user.Username
+	if update.Email != nil {
+		email = *update.Email
+	}
+	if update.Username != nil {
+		username = *update.Username
+	}
+	if err := s.unique(id, email, username); err != nil {
+		return nil, err
+	}
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	if update.Username != nil {
+		user.Username = *update.Username
+	}
+	if update.Password != nil {
+		user.Password = *update.Password
+	}
+	if update.Bio != nil {
+		user.Bio = *update.Bio
+	}
+	if update.Image != nil {
+		user.Image = *update.Image
+	}
+	if update.EmailVerified != nil {
+		user.EmailVerified = *update.EmailVerified
+	}
+	if update.Role != nil {
+		user.Role = *update.Role
+	}
+	return copyUser(user), nil
+}
+
+func (s *userStore) Follow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	if !containsID(user.Following, followee) {
+		user.Following = append(user.Following, followee)
+	}
+	return copyUser(user), nil
+}
+
+func (s *userStore) Unfollow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	user.Following = removeID(user.Following, followee)
+	return copyUser(user), nil
+}
@@ -0,0 +1,73 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// accessTokenStore access_tokens collection
+type accessTokenStore Store
+
+func (s *accessTokenStore) Create(ctx context.Context, token *models.AccessToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := (*Store)(s).collection("access_tokens").InsertOne(ctx, token)
+	return mapError(err)
+}
+
+func (s *accessTokenStore) FindByHash(ctx context.Context, hash string) (*models.AccessToken, error) {
+	var token models.AccessToken
+	err := (*Store)(s).collection("access_tokens").FindOne(ctx, bson.M{"hash": hash}).Decode(&token)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &token, nil
+}
+
+func (s *accessTokenStore) List(ctx context.Context, user primitive.ObjectID) ([]*models.AccessToken, error) {
+	cursor, err := (*Store)(s).collection("access_tokens").Find(ctx, bson.M{"user": user},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, mapError(err)
+	}
+	tokens := []*models.AccessToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, mapError(err)
+	}
+	return tokens, nil
+}
+
+func (s *accessTokenStore) Delete(ctx context.Context, user primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := (*Store)(s).collection("access_tokens").DeleteOne(ctx, bson.M{"_id": id, "user": user})
+	if err != nil {
+		return mapError(err)
+	}
+	if result.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *accessTokenStore) DeleteUser(ctx context.Context, user primitive.ObjectID) error {
+	_, err := (*Store)(s).collection("access_tokens").DeleteMany(ctx, bson.M{"user": user})
+	return mapError(err)
+}
+
+func (s *accessTokenStore) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	result, err := (*Store)(s).collection("access_tokens").UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{"lastUsedAt": at}})
+	if err != nil {
+		return mapError(err)
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
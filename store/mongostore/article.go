@@ -0,0 +1,240 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type articleStore Store
+
+// live matches articles that are not soft deleted, a missing deletedAt
+// matches null too
+var live = primitive.E{Key: "deletedAt", Value: nil}
+
+func articleQuery(filter store.ArticleFilter) bson.D {
+	var query bson.D = bson.D{live}
+	if filter.Authors != nil {
+		query = append(query, primitive.E{Key: "author", Value: bson.D{{Key: "$in", Value: filter.Authors}}})
+	}
+	if filter.Tag != "" {
+		query = append(query, primitive.E{Key: "tagList", Value: bson.D{{Key: "$in", Value: []string{filter.Tag}}}})
+	}
+	if filter.IDs != nil {
+		query = append(query, primitive.E{Key: "_id", Value: bson.D{{Key: "$in", Value: filter.IDs}}})
+	}
+	return query
+}
+
+func (s *articleStore) List(ctx context.Context, filter store.ArticleFilter) ([]models.ArticleWithAuthor, int64, error) {
+	articleCollection := (*Store)(s).collection("articles")
+
+	query := articleQuery(filter)
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: query}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}}},
+	}
+	pipeline = append(pipeline, authorLookup()...)
+	pipeline = append(pipeline, bson.D{{Key: "$skip", Value: filter.Offset}})
+	if filter.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: filter.Limit}})
+	}
+
+	cursor, err := articleCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	articles := []models.ArticleWithAuthor{}
+	if err := cursor.All(ctx, &articles); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	counts, err := articleCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	return articles, counts, nil
+}
+
+func (s *articleStore) FindBySlug(ctx context.Context, slug string) (*models.Article, error) {
+	articleCollection := (*Store)(s).collection("articles")
+	var article models.Article
+	if err := articleCollection.FindOne(ctx, bson.D{{Key: "slug", Value: slug}, live}).Decode(&article); err != nil {
+		return nil, mapError(err)
+	}
+	return &article, nil
+}
+
+func (s *articleStore) FindBySlugWithAuthor(ctx context.Context, slug string) (*models.ArticleWithAuthor, error) {
+	articleCollection := (*Store)(s).collection("articles")
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.D{{Key: "slug", Value: slug}, live}}}}
+	pipeline = append(pipeline, authorLookup()...)
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: 1}})
+	cursor, err := articleCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	defer cursor.Close(ctx)
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, mapError(err)
+		}
+		return nil, store.ErrNotFound
+	}
+	var article models.ArticleWithAuthor
+	if err := cursor.Decode(&article); err != nil {
+		return nil, mapError(err)
+	}
+	return &article, nil
+}
+
+func (s *articleStore) Create(ctx context.Context, article *models.Article) error {
+	articleCollection := (*Store)(s).collection("articles")
+	if article.ID.IsZero() {
+		article.ID = primitive.NewObjectID()
+	}
+	if article.CreatedAt.IsZero() {
+		article.CreatedAt = time.Now()
+	}
+	if article.UpdatedAt.IsZero() {
+		article.UpdatedAt = article.CreatedAt
+	}
+	_, err := articleCollection.InsertOne(ctx, article)
+	return mapError(err)
+}
+
+func (s *articleStore) Update(ctx context.Context, slug string, author primitive.ObjectID, update store.ArticleUpdate) (*models.Article, error) {
+	articleCollection := (*Store)(s).collection("articles")
+
+	set := bson.M{"updatedAt": time.Now()}
+	if update.Title != nil {
+		set["title"] = *update.Title
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Body != nil {
+		set["body"] = *update.Body
+	}
+	if update.TagList != nil {
+		set["tagList"] = update.TagList
+	}
+
+	var article models.Article
+	err := articleCollection.FindOneAndUpdate(ctx, bson.M{
+		"slug":      slug,
+		"author":    author,
+		"deletedAt": nil,
+	}, bson.M{
+		"$set": set,
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&article)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &article, nil
+}
+
+func (s *articleStore) Delete(ctx context.Context, slug string, author primitive.ObjectID) error {
+	articleCollection := (*Store)(s).collection("articles")
+	result, err := articleCollection.UpdateOne(ctx, bson.M{
+		"slug":      slug,
+		"author":    author,
+		"deletedAt": nil,
+	}, bson.M{"$set": bson.M{"deletedAt": time.Now()}})
+	if err != nil {
+		return mapError(err)
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *articleStore) Restore(ctx context.Context, slug string, author primitive.ObjectID, deletedAfter time.Time) (*models.Article, error) {
+	articleCollection := (*Store)(s).collection("articles")
+	var article models.Article
+	// the latest deletion when the slug was reused and deleted again, a
+	// live article that took the slug since fails the unique index
+	err := articleCollection.FindOneAndUpdate(ctx, bson.M{
+		"slug":      slug,
+		"author":    author,
+		"deletedAt": bson.M{"$gt": deletedAfter},
+	}, bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+	}, options.FindOneAndUpdate().SetSort(bson.M{"deletedAt": -1}).SetReturnDocument(options.After)).Decode(&article)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &article, nil
+}
+
+// setFavorite add or remove article id in the favorites of user, moving
+// favoritesCount by one only when the favorites actually changed
+func (s *articleStore) setFavorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID, favorite bool) (*models.Article, error) {
+	db := (*Store)(s)
+	articleCollection := db.collection("articles")
+	userCollection := db.collection("users")
+
+	filter := bson.M{"_id": user, "favorites": bson.M{"$ne": id}}
+	update := bson.M{"$addToSet": bson.M{"favorites": id}}
+	inc := 1
+	if !favorite {
+		filter = bson.M{"_id": user, "favorites": id}
+		update = bson.M{"$pull": bson.M{"favorites": id}}
+		inc = -1
+	}
+
+	var article models.Article
+	err := db.withTransaction(ctx, func(ctx context.Context) error {
+		if err := articleCollection.FindOne(ctx, bson.D{{Key: "_id", Value: id}, live}).Err(); err != nil {
+			return mapError(err)
+		}
+		result, err := userCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return mapError(err)
+		}
+		if result.ModifiedCount == 0 {
+			if err := userCollection.FindOne(ctx, bson.M{"_id": user}).Err(); err != nil {
+				return mapError(err)
+			}
+			return mapError(articleCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&article))
+		}
+		return mapError(articleCollection.FindOneAndUpdate(ctx, bson.M{"_id": id},
+			bson.M{"$inc": bson.M{"favoritesCount": inc}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&article))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+func (s *articleStore) Favorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error) {
+	return s.setFavorite(ctx, id, user, true)
+}
+
+func (s *articleStore) Unfavorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error) {
+	return s.setFavorite(ctx, id, user, false)
+}
+
+func (s *articleStore) Tags(ctx context.Context) ([]string, error) {
+	articleCollection := (*Store)(s).collection("articles")
+	distinctResult, err := articleCollection.Distinct(ctx, "tagList", bson.D{live})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	tags := make([]string, 0, len(distinctResult))
+	for _, tag := range distinctResult {
+		if tag, ok := tag.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
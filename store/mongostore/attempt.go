@@ -0,0 +1,69 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// loginAttemptStore login_attempts collection, expired counters are
+// removed by the TTL index on expiresAt
+type loginAttemptStore Store
+
+func (s *loginAttemptStore) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	// the TTL monitor runs about once a minute, filter what it has not
+	// removed yet
+	err := (*Store)(s).collection("login_attempts").FindOne(ctx,
+		bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&attempt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &attempt, nil
+}
+
+// Fail count failure with one pipeline upsert, restarting the count of an
+// expired counter the TTL monitor has not removed yet
+func (s *loginAttemptStore) Fail(ctx context.Context, key string, at time.Time, ttl time.Duration) (*models.LoginAttempt, error) {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "failures", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$gt", Value: bson.A{"$expiresAt", at}}},
+			bson.D{{Key: "$add", Value: bson.A{"$failures", 1}}},
+			1,
+		}}}},
+		{Key: "previousFailure", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$gt", Value: bson.A{"$expiresAt", at}}},
+			"$lastFailure",
+			nil,
+		}}}},
+		{Key: "lastFailure", Value: at},
+		{Key: "expiresAt", Value: at.Add(ttl)},
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var attempt models.LoginAttempt
+	var err error
+	// two concurrent upserts of a new key race on _id, the loser retries
+	// as an update
+	for try := 0; try < 2; try++ {
+		err = mapError((*Store)(s).collection("login_attempts").FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempt))
+		var duplicate *store.DuplicateError
+		if !errors.As(err, &duplicate) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (s *loginAttemptStore) Reset(ctx context.Context, keys ...string) error {
+	_, err := (*Store)(s).collection("login_attempts").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	return mapError(err)
+}
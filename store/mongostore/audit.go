@@ -0,0 +1,52 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditStore audit_log collection
+type auditStore Store
+
+func (s *auditStore) Create(ctx context.Context, entry *models.AuditEntry) error {
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := (*Store)(s).collection("audit_log").InsertOne(ctx, entry)
+	return mapError(err)
+}
+
+func (s *auditStore) List(ctx context.Context, filter store.AuditFilter) ([]*models.AuditEntry, int64, error) {
+	auditCollection := (*Store)(s).collection("audit_log")
+	query := bson.M{}
+	if !filter.Actor.IsZero() {
+		query["actor"] = filter.Actor
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(filter.Offset))
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := auditCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	entries := []*models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, mapError(err)
+	}
+	counts, err := auditCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	return entries, counts, nil
+}
@@ -0,0 +1,184 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// archivedComment document of archived_comments, the comment as it was
+// plus when a cascade moved it there
+type archivedComment struct {
+	models.Comment `bson:",inline"`
+	ArchivedAt     time.Time `bson:"archivedAt"`
+}
+
+// cascade one unit of work, run inside withTransaction
+type cascade struct {
+	*Store
+	policy store.CommentPolicy
+	now    time.Time
+	report store.CascadeReport
+}
+
+func (s *Store) cascade(comments store.CommentPolicy) *cascade {
+	return &cascade{Store: s, policy: comments, now: time.Now()}
+}
+
+// run fn in a transaction, the report starts over on every retry
+func (c *cascade) run(ctx context.Context, fn func(ctx context.Context) error) (*store.CascadeReport, error) {
+	err := c.withTransaction(ctx, func(ctx context.Context) error {
+		c.report = store.CascadeReport{}
+		return fn(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c.report, nil
+}
+
+// removeComments delete or archive the comments matching filter
+func (c *cascade) removeComments(ctx context.Context, filter bson.M) error {
+	comments := c.collection("comments")
+	if c.policy == store.ArchiveComments {
+		cursor, err := comments.Find(ctx, filter)
+		if err != nil {
+			return mapError(err)
+		}
+		var found []models.Comment
+		if err := cursor.All(ctx, &found); err != nil {
+			return mapError(err)
+		}
+		if len(found) == 0 {
+			return nil
+		}
+		docs := make([]interface{}, len(found))
+		for i, comment := range found {
+			docs[i] = archivedComment{Comment: comment, ArchivedAt: c.now}
+		}
+		if _, err := c.collection("archived_comments").InsertMany(ctx, docs); err != nil {
+			return mapError(err)
+		}
+		c.report.ArchivedComments += int64(len(found))
+	}
+	result, err := comments.DeleteMany(ctx, filter)
+	if err != nil {
+		return mapError(err)
+	}
+	if c.policy != store.ArchiveComments {
+		c.report.Comments += result.DeletedCount
+	}
+	return nil
+}
+
+// removeArticles remove the articles matching filter with their comments
+// and favorites references
+func (c *cascade) removeArticles(ctx context.Context, filter bson.M) error {
+	articles := c.collection("articles")
+	cursor, err := articles.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return mapError(err)
+	}
+	var found []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &found); err != nil {
+		return mapError(err)
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(found))
+	for i, article := range found {
+		ids[i] = article.ID
+	}
+
+	if err := c.removeComments(ctx, bson.M{"article": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	// one update per article, the modified count is then the number of
+	// references removed
+	for _, id := range ids {
+		result, err := c.collection("users").UpdateMany(ctx, bson.M{"favorites": id}, bson.M{"$pull": bson.M{"favorites": id}})
+		if err != nil {
+			return mapError(err)
+		}
+		c.report.Favorites += result.ModifiedCount
+	}
+	result, err := articles.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return mapError(err)
+	}
+	c.report.Articles += result.DeletedCount
+	return nil
+}
+
+func (s *articleStore) Remove(ctx context.Context, id primitive.ObjectID, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	c := (*Store)(s).cascade(comments)
+	return c.run(ctx, func(ctx context.Context) error {
+		if err := c.removeArticles(ctx, bson.M{"_id": id}); err != nil {
+			return err
+		}
+		if c.report.Articles == 0 {
+			return store.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s *articleStore) Purge(ctx context.Context, deletedBefore time.Time, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	c := (*Store)(s).cascade(comments)
+	return c.run(ctx, func(ctx context.Context) error {
+		return c.removeArticles(ctx, bson.M{"deletedAt": bson.M{"$lt": deletedBefore}})
+	})
+}
+
+func (s *userStore) Remove(ctx context.Context, id primitive.ObjectID, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	c := (*Store)(s).cascade(comments)
+	users := c.collection("users")
+	return c.run(ctx, func(ctx context.Context) error {
+		if err := c.removeArticles(ctx, bson.M{"author": id}); err != nil {
+			return err
+		}
+		// read after the own articles went, their favorites are pulled
+		var user models.User
+		if err := users.FindOne(ctx, bson.M{"_id": id}).Decode(&user); err != nil {
+			return mapError(err)
+		}
+		if err := c.removeComments(ctx, bson.M{"author": id}); err != nil {
+			return err
+		}
+		if len(user.Favorites) > 0 {
+			result, err := c.collection("articles").UpdateMany(ctx, bson.M{"_id": bson.M{"$in": user.Favorites}},
+				bson.M{"$inc": bson.M{"favoritesCount": -1}})
+			if err != nil {
+				return mapError(err)
+			}
+			c.report.Favorites += result.ModifiedCount
+		}
+		result, err := users.UpdateMany(ctx, bson.M{"following": id}, bson.M{"$pull": bson.M{"following": id}})
+		if err != nil {
+			return mapError(err)
+		}
+		c.report.Follows = result.ModifiedCount
+		for _, name := range []string{"refresh_tokens", "password_resets", "identities", "access_tokens"} {
+			if _, err := c.collection(name).DeleteMany(ctx, bson.M{"user": id}); err != nil {
+				return mapError(err)
+			}
+		}
+		if _, err := c.collection("two_factor").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+			return mapError(err)
+		}
+		deleted, err := users.DeleteOne(ctx, bson.M{"_id": id})
+		if err != nil {
+			return mapError(err)
+		}
+		c.report.Users = deleted.DeletedCount
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type commentStore Store
+
+func (s *commentStore) Create(ctx context.Context, comment *models.Comment) error {
+	commentCollection := (*Store)(s).collection("comments")
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	if comment.UpdatedAt.IsZero() {
+		comment.UpdatedAt = comment.CreatedAt
+	}
+	_, err := commentCollection.InsertOne(ctx, comment)
+	return mapError(err)
+}
+
+func (s *commentStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	commentCollection := (*Store)(s).collection("comments")
+	var comment models.Comment
+	if err := commentCollection.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&comment); err != nil {
+		return nil, mapError(err)
+	}
+	return &comment, nil
+}
+
+func (s *commentStore) Delete(ctx context.Context, id primitive.ObjectID, author primitive.ObjectID) error {
+	commentCollection := (*Store)(s).collection("comments")
+	result, err := commentCollection.DeleteOne(ctx, bson.M{
+		"_id":    id,
+		"author": author,
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	if result.DeletedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *commentStore) ListByArticle(ctx context.Context, article primitive.ObjectID) ([]models.CommentWithAuthor, error) {
+	commentCollection := (*Store)(s).collection("comments")
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "article", Value: article}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}}},
+	}
+	pipeline = append(pipeline, authorLookup()...)
+	cursor, err := commentCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	comments := []models.CommentWithAuthor{}
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, mapError(err)
+	}
+	return comments, nil
+}
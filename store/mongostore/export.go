@@ -0,0 +1,70 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// each decode every document of collection ordered by _id into a fresh
+// value from newValue and pass it to fn
+func (s *Store) each(ctx context.Context, collection string, newValue func() interface{}, fn func(v interface{}) error) error {
+	cursor, err := s.collection(collection).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return mapError(err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		v := newValue()
+		if err := cursor.Decode(v); err != nil {
+			return mapError(err)
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return mapError(cursor.Err())
+}
+
+// EachUser call fn for every user ordered by id
+func (s *Store) EachUser(ctx context.Context, fn func(user *models.User) error) error {
+	return s.each(ctx, "users", func() interface{} { return &models.User{} }, func(v interface{}) error {
+		return fn(v.(*models.User))
+	})
+}
+
+// EachArticle call fn for every article ordered by id
+func (s *Store) EachArticle(ctx context.Context, fn func(article *models.Article) error) error {
+	return s.each(ctx, "articles", func() interface{} { return &models.Article{} }, func(v interface{}) error {
+		return fn(v.(*models.Article))
+	})
+}
+
+// EachComment call fn for every comment ordered by id
+func (s *Store) EachComment(ctx context.Context, fn func(comment *models.Comment) error) error {
+	return s.each(ctx, "comments", func() interface{} { return &models.Comment{} }, func(v interface{}) error {
+		return fn(v.(*models.Comment))
+	})
+}
+
+// ImportFavorite add article, live or soft deleted, to the favorites of user
+func (s *Store) ImportFavorite(ctx context.Context, user primitive.ObjectID, article primitive.ObjectID) error {
+	return s.withTransaction(ctx, func(ctx context.Context) error {
+		if err := s.collection("articles").FindOne(ctx, bson.M{"_id": article}).Err(); err != nil {
+			return mapError(err)
+		}
+		result, err := s.collection("users").UpdateOne(ctx, bson.M{"_id": user, "favorites": bson.M{"$ne": article}},
+			bson.M{"$addToSet": bson.M{"favorites": article}})
+		if err != nil {
+			return mapError(err)
+		}
+		if result.ModifiedCount == 0 {
+			return mapError(s.collection("users").FindOne(ctx, bson.M{"_id": user}).Err())
+		}
+		_, err = s.collection("articles").UpdateOne(ctx, bson.M{"_id": article}, bson.M{"$inc": bson.M{"favoritesCount": 1}})
+		return mapError(err)
+	})
+}
@@ -0,0 +1,29 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// identityStore identities collection, unique on provider and subject
+type identityStore Store
+
+func (s *identityStore) Find(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+	var identity models.Identity
+	err := (*Store)(s).collection("identities").FindOne(ctx, bson.M{"provider": provider, "subject": subject}).Decode(&identity)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &identity, nil
+}
+
+func (s *identityStore) Create(ctx context.Context, identity *models.Identity) error {
+	if identity.ID.IsZero() {
+		identity.ID = primitive.NewObjectID()
+	}
+	_, err := (*Store)(s).collection("identities").InsertOne(ctx, identity)
+	return mapError(err)
+}
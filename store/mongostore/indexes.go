@@ -0,0 +1,209 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index expected index on a collection
+type index struct {
+	collection string
+	name       string
+	keys       bson.D
+	unique     bool
+	// field reported in DuplicateError for unique indexes
+	field string
+	// expireAfter seconds after the date in keys when the server deletes
+	// the document, nil for no TTL
+	expireAfter *int32
+}
+
+// ttl expireAfter of seconds
+func ttl(seconds int32) *int32 {
+	return &seconds
+}
+
+var indexes = []index{
+	{collection: "users", name: "email_1", keys: bson.D{{Key: "email", Value: 1}}, unique: true, field: "email"},
+	{collection: "users", name: "username_1", keys: bson.D{{Key: "username", Value: 1}}, unique: true, field: "username"},
+	{collection: "users", name: "role_1_username_1", keys: bson.D{{Key: "role", Value: 1}, {Key: "username", Value: 1}}},
+	// live articles all lack deletedAt, so only they clash on slug. Two
+	// deletions of one slug within a millisecond would clash as well.
+	{collection: "articles", name: "slug_1_deletedAt_1", keys: bson.D{{Key: "slug", Value: 1}, {Key: "deletedAt", Value: 1}}, unique: true, field: "slug"},
+	{collection: "articles", name: "tagList_1", keys: bson.D{{Key: "tagList", Value: 1}}},
+	{collection: "articles", name: "author_1", keys: bson.D{{Key: "author", Value: 1}}},
+	{collection: "articles", name: "createdAt_-1", keys: bson.D{{Key: "createdAt", Value: -1}}},
+	{collection: "articles", name: "deletedAt_1", keys: bson.D{{Key: "deletedAt", Value: 1}}},
+	{collection: "comments", name: "article_1", keys: bson.D{{Key: "article", Value: 1}}},
+	{collection: "comments", name: "author_1", keys: bson.D{{Key: "author", Value: 1}}},
+	{collection: "archived_comments", name: "article_1", keys: bson.D{{Key: "article", Value: 1}}},
+	{collection: "refresh_tokens", name: "hash_1", keys: bson.D{{Key: "hash", Value: 1}}, unique: true, field: "hash"},
+	{collection: "refresh_tokens", name: "family_1", keys: bson.D{{Key: "family", Value: 1}}},
+	{collection: "refresh_tokens", name: "user_1", keys: bson.D{{Key: "user", Value: 1}}},
+	{collection: "revocations", name: "expiresAt_1", keys: bson.D{{Key: "expiresAt", Value: 1}}, expireAfter: ttl(0)},
+	{collection: "password_resets", name: "hash_1", keys: bson.D{{Key: "hash", Value: 1}}, unique: true, field: "hash"},
+	{collection: "password_resets", name: "email_1_createdAt_1", keys: bson.D{{Key: "email", Value: 1}, {Key: "createdAt", Value: 1}}},
+	{collection: "password_resets", name: "user_1", keys: bson.D{{Key: "user", Value: 1}}},
+	{collection: "password_resets", name: "createdAt_1", keys: bson.D{{Key: "createdAt", Value: 1}},
+		expireAfter: ttl(int32(store.PasswordResetRetention / time.Second))},
+	{collection: "identities", name: "provider_1_subject_1", keys: bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}}, unique: true, field: "subject"},
+	{collection: "identities", name: "user_1", keys: bson.D{{Key: "user", Value: 1}}},
+	{collection: "access_tokens", name: "hash_1", keys: bson.D{{Key: "hash", Value: 1}}, unique: true, field: "hash"},
+	{collection: "access_tokens", name: "user_1_createdAt_-1", keys: bson.D{{Key: "user", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{collection: "audit_log", name: "createdAt_-1", keys: bson.D{{Key: "createdAt", Value: -1}}},
+	{collection: "audit_log", name: "actor_1_createdAt_-1", keys: bson.D{{Key: "actor", Value: 1}, {Key: "createdAt", Value: -1}}},
+	{collection: "login_attempts", name: "expiresAt_1", keys: bson.D{{Key: "expiresAt", Value: 1}}, expireAfter: ttl(0)},
+}
+
+// existingIndex index as listed by the server
+type existingIndex struct {
+	Name   string `bson:"name"`
+	Keys   bson.D `bson:"key"`
+	Unique bool   `bson:"unique"`
+	// ExpireAfter expireAfterSeconds of TTL indexes
+	ExpireAfter *int32 `bson:"expireAfterSeconds"`
+}
+
+func ttlEqual(a *int32, b *int32) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ttlString expireAfter for drift reports
+func ttlString(seconds *int32) string {
+	if seconds == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%ds", *seconds)
+}
+
+func keysEqual(a bson.D, b bson.D) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Key != b[i].Key || fmt.Sprint(a[i].Value) != fmt.Sprint(b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Store) listIndexes(ctx context.Context, collection string) ([]existingIndex, error) {
+	cursor, err := s.collection(collection).Indexes().List(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var existing []existingIndex
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, mapError(err)
+	}
+	return existing, nil
+}
+
+// EnsureIndexes create missing indexes and report indexes that drifted
+// from their expected definition. Existing indexes are never dropped.
+func (s *Store) EnsureIndexes(ctx context.Context) (*store.IndexReport, error) {
+	report := &store.IndexReport{}
+	expected := make(map[string][]index)
+	var collections []string
+	for _, idx := range indexes {
+		if _, ok := expected[idx.collection]; !ok {
+			collections = append(collections, idx.collection)
+		}
+		expected[idx.collection] = append(expected[idx.collection], idx)
+	}
+
+	for _, collection := range collections {
+		existing, err := s.listIndexes(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string]existingIndex, len(existing))
+		for _, e := range existing {
+			byName[e.Name] = e
+		}
+
+		known := map[string]bool{"_id_": true}
+		for _, idx := range expected[collection] {
+			known[idx.name] = true
+			qualified := collection + "." + idx.name
+			if e, ok := byName[idx.name]; ok {
+				if !keysEqual(e.Keys, idx.keys) || e.Unique != idx.unique || !ttlEqual(e.ExpireAfter, idx.expireAfter) {
+					report.Drift = append(report.Drift, fmt.Sprintf("%s: expected keys %v unique=%v ttl=%s, found keys %v unique=%v ttl=%s",
+						qualified, idx.keys, idx.unique, ttlString(idx.expireAfter), e.Keys, e.Unique, ttlString(e.ExpireAfter)))
+				}
+				continue
+			}
+
+			opts := options.Index().SetName(idx.name).SetUnique(idx.unique)
+			if idx.expireAfter != nil {
+				opts.SetExpireAfterSeconds(*idx.expireAfter)
+			}
+			model := mongo.IndexModel{Keys: idx.keys, Options: opts}
+			if _, err := s.collection(collection).Indexes().CreateOne(ctx, model); err != nil {
+				err = mapError(err)
+				if errors.Is(err, store.ErrUnavailable) {
+					return nil, err
+				}
+				report.Drift = append(report.Drift, fmt.Sprintf("%s: create failed: %v", qualified, err))
+				continue
+			}
+			report.Created = append(report.Created, qualified)
+		}
+
+		for _, e := range existing {
+			if !known[e.Name] {
+				report.Drift = append(report.Drift, fmt.Sprintf("%s.%s: unexpected index on %v", collection, e.Name, e.Keys))
+			}
+		}
+	}
+	return report, nil
+}
+
+var duplicateIndexPattern = regexp.MustCompile(`index: (\S+) dup key`)
+
+// duplicateError DuplicateError for a duplicate key error message, nil if
+// it names no unique index we know
+func duplicateError(message string) error {
+	match := duplicateIndexPattern.FindStringSubmatch(message)
+	if match == nil {
+		return nil
+	}
+	for _, idx := range indexes {
+		if idx.unique && idx.name == match[1] {
+			return &store.DuplicateError{Field: idx.field}
+		}
+	}
+	return nil
+}
+
+// duplicateKey DuplicateError when err is a duplicate key error
+func duplicateKey(err error) error {
+	const duplicateKeyCode = 11000
+	var writeException mongo.WriteException
+	var commandError mongo.CommandError
+	switch {
+	case errors.As(err, &writeException):
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == duplicateKeyCode {
+				return duplicateError(writeError.Message)
+			}
+		}
+	case errors.As(err, &commandError):
+		if commandError.Code == duplicateKeyCode {
+			return duplicateError(commandError.Message)
+		}
+	}
+	return nil
+}
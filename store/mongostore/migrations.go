@@ -0,0 +1,290 @@
+package mongostore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration versioned data migration, up and down describe what they
+// change and only write when dryRun is false
+type migration struct {
+	version int
+	name    string
+	up      func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error)
+	down    func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error)
+}
+
+// migrations ordered by version, append only
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "rename comment timestamps to camelCase",
+		up: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			return renameFields(ctx, db.Collection("comments"), dryRun, "created_at", "createdAt", "updated_at", "updatedAt")
+		},
+		down: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			return renameFields(ctx, db.Collection("comments"), dryRun, "createdAt", "created_at", "updatedAt", "updated_at")
+		},
+	},
+	{
+		version: 2,
+		name:    "move users image:omitempty to image",
+		up: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			// UpdateUser wrote an empty "image:omitempty" whenever no image
+			// was given, drop those before moving the real values over
+			userCollection := db.Collection("users")
+			filter := bson.M{"image:omitempty": ""}
+			change := "users: unset empty image:omitempty"
+			var changes []string
+			if dryRun {
+				n, err := userCollection.CountDocuments(ctx, filter)
+				if err != nil {
+					return nil, mapError(err)
+				}
+				changes = append(changes, fmt.Sprintf("%s in %d documents", change, n))
+			} else {
+				result, err := userCollection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"image:omitempty": ""}})
+				if err != nil {
+					return nil, mapError(err)
+				}
+				changes = append(changes, fmt.Sprintf("%s in %d documents", change, result.ModifiedCount))
+			}
+			renamed, err := renameFields(ctx, userCollection, dryRun, "image:omitempty", "image")
+			return append(changes, renamed...), err
+		},
+		down: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			// the broken field is not worth restoring
+			return []string{"users: nothing to revert"}, nil
+		},
+	},
+	{
+		version: 3,
+		name:    "mark existing users emailVerified",
+		up: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			// users registered before verification existed count as verified
+			return setMissing(ctx, db.Collection("users"), dryRun, "emailVerified", true)
+		},
+		down: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			return unsetFields(ctx, db.Collection("users"), dryRun, "emailVerified")
+		},
+	},
+	{
+		version: 4,
+		name:    "give existing users the user role",
+		up: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			return setMissing(ctx, db.Collection("users"), dryRun, "role", string(models.RoleUser))
+		},
+		down: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			return unsetFields(ctx, db.Collection("users"), dryRun, "role")
+		},
+	},
+	{
+		version: 5,
+		name:    "release slugs of soft deleted articles",
+		up: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			// EnsureIndexes creates slug_1_deletedAt_1 in its place
+			return dropIndex(ctx, db.Collection("articles"), dryRun, "slug_1")
+		},
+		down: func(ctx context.Context, db *mongo.Database, dryRun bool) ([]string, error) {
+			changes, err := dropIndex(ctx, db.Collection("articles"), dryRun, "slug_1_deletedAt_1")
+			if err != nil || dryRun {
+				return append(changes, "articles: create unique index slug_1"), err
+			}
+			// fails while a deleted article shares its slug with another
+			// article, purge or remove those first
+			_, err = db.Collection("articles").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "slug", Value: 1}},
+				Options: options.Index().SetName("slug_1").SetUnique(true),
+			})
+			return append(changes, "articles: create unique index slug_1"), mapError(err)
+		},
+	},
+}
+
+// dropIndex drop index name of collection if it exists
+func dropIndex(ctx context.Context, collection *mongo.Collection, dryRun bool, name string) ([]string, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var existing []existingIndex
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, mapError(err)
+	}
+	for _, e := range existing {
+		if e.Name != name {
+			continue
+		}
+		change := fmt.Sprintf("%s: drop index %s", collection.Name(), name)
+		if !dryRun {
+			if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+				return nil, mapError(err)
+			}
+		}
+		return []string{change}, nil
+	}
+	return []string{fmt.Sprintf("%s: no index %s to drop", collection.Name(), name)}, nil
+}
+
+// renameFields rename field pairs from, to in documents that have from
+func renameFields(ctx context.Context, collection *mongo.Collection, dryRun bool, pairs ...string) ([]string, error) {
+	var changes []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		from, to := pairs[i], pairs[i+1]
+		filter := bson.M{from: bson.M{"$exists": true}}
+		change := fmt.Sprintf("%s: rename %s to %s", collection.Name(), from, to)
+		if dryRun {
+			n, err := collection.CountDocuments(ctx, filter)
+			if err != nil {
+				return changes, mapError(err)
+			}
+			changes = append(changes, fmt.Sprintf("%s in %d documents", change, n))
+			continue
+		}
+		result, err := collection.UpdateMany(ctx, filter, bson.M{"$rename": bson.M{from: to}})
+		if err != nil {
+			return changes, mapError(err)
+		}
+		changes = append(changes, fmt.Sprintf("%s in %d documents", change, result.ModifiedCount))
+	}
+	return changes, nil
+}
+
+// setMissing set field to value in documents without it
+func setMissing(ctx context.Context, collection *mongo.Collection, dryRun bool, field string, value interface{}) ([]string, error) {
+	filter := bson.M{field: bson.M{"$exists": false}}
+	change := fmt.Sprintf("%s: set %s to %v", collection.Name(), field, value)
+	if dryRun {
+		n, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		return []string{fmt.Sprintf("%s in %d documents", change, n)}, nil
+	}
+	result, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: value}})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return []string{fmt.Sprintf("%s in %d documents", change, result.ModifiedCount)}, nil
+}
+
+// unsetFields remove fields from every document
+func unsetFields(ctx context.Context, collection *mongo.Collection, dryRun bool, fields ...string) ([]string, error) {
+	var changes []string
+	for _, field := range fields {
+		filter := bson.M{field: bson.M{"$exists": true}}
+		change := fmt.Sprintf("%s: unset %s", collection.Name(), field)
+		if dryRun {
+			n, err := collection.CountDocuments(ctx, filter)
+			if err != nil {
+				return changes, mapError(err)
+			}
+			changes = append(changes, fmt.Sprintf("%s in %d documents", change, n))
+			continue
+		}
+		result, err := collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{field: ""}})
+		if err != nil {
+			return changes, mapError(err)
+		}
+		changes = append(changes, fmt.Sprintf("%s in %d documents", change, result.ModifiedCount))
+	}
+	return changes, nil
+}
+
+// migrationRecord document in schema_migrations
+type migrationRecord struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// applied applied_at of recorded versions
+func (s *Store) applied(ctx context.Context) (map[int]time.Time, error) {
+	cursor, err := s.collection("schema_migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var records []migrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, mapError(err)
+	}
+	applied := make(map[int]time.Time, len(records))
+	for _, record := range records {
+		applied[record.Version] = record.AppliedAt
+	}
+	return applied, nil
+}
+
+// MigrationStatus state of every known migration
+func (s *Store) MigrationStatus(ctx context.Context) ([]store.MigrationStatus, error) {
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]store.MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = store.MigrationStatus{Version: m.version, Name: m.name, AppliedAt: applied[m.version]}
+	}
+	return status, nil
+}
+
+// MigrateUp apply pending migrations in order
+func (s *Store) MigrateUp(ctx context.Context, dryRun bool) ([]store.MigrationStep, error) {
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var steps []store.MigrationStep
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			continue
+		}
+		changes, err := m.up(ctx, s.db, dryRun)
+		if err != nil {
+			return steps, fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		if !dryRun {
+			record := migrationRecord{Version: m.version, Name: m.name, AppliedAt: time.Now()}
+			_, err := s.collection("schema_migrations").ReplaceOne(ctx, bson.M{"_id": m.version}, record,
+				options.Replace().SetUpsert(true))
+			if err != nil {
+				return steps, mapError(err)
+			}
+		}
+		steps = append(steps, store.MigrationStep{Version: m.version, Name: m.name, Changes: changes})
+	}
+	return steps, nil
+}
+
+// MigrateDown revert the last n applied migrations, newest first
+func (s *Store) MigrateDown(ctx context.Context, n int, dryRun bool) ([]store.MigrationStep, error) {
+	applied, err := s.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var steps []store.MigrationStep
+	for i := len(migrations) - 1; i >= 0 && len(steps) < n; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		changes, err := m.down(ctx, s.db, dryRun)
+		if err != nil {
+			return steps, fmt.Errorf("migration %d: %w", m.version, err)
+		}
+		if !dryRun {
+			if _, err := s.collection("schema_migrations").DeleteOne(ctx, bson.M{"_id": m.version}); err != nil {
+				return steps, mapError(err)
+			}
+		}
+		steps = append(steps, store.MigrationStep{Version: m.version, Name: m.name, Changes: changes})
+	}
+	return steps, nil
+}
@@ -0,0 +1,243 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Options mongodb connection options
+type Options struct {
+	URI      string
+	Database string
+	// MaxPoolSize max connections per server, 0 uses driver default
+	MaxPoolSize uint64
+	MinPoolSize uint64
+	// ConnectTimeout timeout for establishing a connection
+	ConnectTimeout time.Duration
+	// ServerSelectionTimeout how long an operation waits for a usable server
+	ServerSelectionTimeout time.Duration
+	// SocketTimeout timeout for reads and writes on a connection
+	SocketTimeout   time.Duration
+	MaxConnIdleTime time.Duration
+	// ReadPreference one of primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest
+	ReadPreference string
+}
+
+// Store mongodb store
+type Store struct {
+	client *mongo.Client
+	db     *mongo.Database
+}
+
+// Connect create pooled client from opts and ping the server
+func Connect(ctx context.Context, opts Options) (*Store, error) {
+	clientOptions := options.Client().ApplyURI(opts.URI)
+	if opts.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(opts.MaxPoolSize)
+	}
+	if opts.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(opts.MinPoolSize)
+	}
+	if opts.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(opts.ConnectTimeout)
+	}
+	if opts.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(opts.ServerSelectionTimeout)
+	}
+	if opts.SocketTimeout > 0 {
+		clientOptions.SetSocketTimeout(opts.SocketTimeout)
+	}
+	if opts.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(opts.MaxConnIdleTime)
+	}
+	if opts.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(opts.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		rp, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetReadPreference(rp)
+	}
+	if err := clientOptions.Validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := mongo.NewClient(clientOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Connect(ctx); err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return nil, mapError(err)
+	}
+	return New(client, opts.Database), nil
+}
+
+// New create store from connected client using database
+func New(client *mongo.Client, database string) *Store {
+	return &Store{client: client, db: client.Database(database)}
+}
+
+// Users user store
+func (s *Store) Users() store.UserStore {
+	return (*userStore)(s)
+}
+
+// Articles article store
+func (s *Store) Articles() store.ArticleStore {
+	return (*articleStore)(s)
+}
+
+// Comments comment store
+func (s *Store) Comments() store.CommentStore {
+	return (*commentStore)(s)
+}
+
+// RefreshTokens refresh token store
+func (s *Store) RefreshTokens() store.RefreshTokenStore {
+	return (*refreshTokenStore)(s)
+}
+
+// Revocations access token revocation store
+func (s *Store) Revocations() store.RevocationStore {
+	return (*revocationStore)(s)
+}
+
+// PasswordResets password reset store
+func (s *Store) PasswordResets() store.PasswordResetStore {
+	return (*passwordResetStore)(s)
+}
+
+// TwoFactors two-factor store
+func (s *Store) TwoFactors() store.TwoFactorStore {
+	return (*twoFactorStore)(s)
+}
+
+// Identities identity store
+func (s *Store) Identities() store.IdentityStore {
+	return (*identityStore)(s)
+}
+
+// AccessTokens personal access token store
+func (s *Store) AccessTokens() store.AccessTokenStore {
+	return (*accessTokenStore)(s)
+}
+
+// Audit audit log store
+func (s *Store) Audit() store.AuditStore {
+	return (*auditStore)(s)
+}
+
+// LoginAttempts failed login counter store
+func (s *Store) LoginAttempts() store.LoginAttemptStore {
+	return (*loginAttemptStore)(s)
+}
+
+// Close disconnect client, waiting for in-use connections until ctx is done
+func (s *Store) Close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
+
+// Wipe delete all documents of users, articles and comments, indexes stay
+func (s *Store) Wipe(ctx context.Context) error {
+	for _, name := range []string{"login_attempts", "audit_log", "access_tokens", "identities", "two_factor", "password_resets", "revocations", "refresh_tokens", "archived_comments", "comments", "articles", "users"} {
+		if _, err := s.collection(name).DeleteMany(ctx, bson.M{}); err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+// collection collection of name
+func (s *Store) collection(name string) *mongo.Collection {
+	return s.db.Collection(name)
+}
+
+// withTransaction run fn in a multi-document transaction, retried on
+// transient errors. Standalone servers have no transactions, there fn runs
+// directly and each of its updates is still atomic on its own.
+func (s *Store) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := s.client.StartSession()
+	if err != nil {
+		return mapError(err)
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	if transactionsUnsupported(err) {
+		return fn(ctx)
+	}
+	return mapError(err)
+}
+
+// transactionsUnsupported whether err means the deployment is a standalone
+// server without transaction support
+func transactionsUnsupported(err error) bool {
+	var commandError mongo.CommandError
+	if !errors.As(err, &commandError) {
+		return false
+	}
+	// IllegalOperation: Transaction numbers are only allowed on a replica
+	// set member or mongos
+	return commandError.Code == 20 || strings.Contains(commandError.Message, "Transaction numbers")
+}
+
+// mapError translate driver errors into store errors
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return store.ErrNotFound
+	}
+	if duplicate := duplicateKey(err); duplicate != nil {
+		return duplicate
+	}
+	if unavailable(err) {
+		return fmt.Errorf("%w: %v", store.ErrUnavailable, err)
+	}
+	return err
+}
+
+// unavailable whether err means the server could not be reached
+func unavailable(err error) bool {
+	var commandError mongo.CommandError
+	var connectionError topology.ConnectionError
+	switch {
+	case errors.Is(err, mongo.ErrClientDisconnected),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, topology.ErrServerSelectionTimeout),
+		errors.As(err, &connectionError):
+		return true
+	case errors.As(err, &commandError):
+		return commandError.HasErrorLabel("NetworkError")
+	}
+	// server selection errors are not wrapped by the driver
+	return strings.HasPrefix(err.Error(), "server selection error")
+}
+
+// authorLookup $lookup and $unwind stages joining author from users
+func authorLookup() []bson.D {
+	return []bson.D{
+		{{Key: "$lookup", Value: bson.D{{Key: "from", Value: "users"}, {Key: "localField", Value: "author"}, {Key: "foreignField", Value: "_id"}, {Key: "as", Value: "author"}}}},
+		{{Key: "$unwind", Value: bson.D{{Key: "path", Value: "$author"}, {Key: "preserveNullAndEmptyArrays", Value: false}}}},
+	}
+}
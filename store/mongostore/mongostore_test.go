@@ -0,0 +1,59 @@
+package mongostore
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/storetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// open store on a fresh database of the server at MONGODB_TEST_URI,
+// skipping the test when it is not set
+func open(t *testing.T) *Store {
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := Connect(ctx, Options{URI: uri, Database: "conduit_test_" + primitive.NewObjectID().Hex()})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if _, err := s.EnsureIndexes(ctx); err != nil {
+		t.Fatalf("ensure indexes: %v", err)
+	}
+	t.Cleanup(func() {
+		s.db.Drop(context.Background())
+		s.Close(context.Background())
+	})
+	return s
+}
+
+func TestConcurrentFavorites(t *testing.T) {
+	storetest.ConcurrentFavorites(t, open(t))
+}
+
+func TestConcurrentLoginFailures(t *testing.T) {
+	storetest.ConcurrentLoginFailures(t, open(t))
+}
+
+func TestSlugReuse(t *testing.T) {
+	storetest.SlugReuse(t, func() store.Store { return open(t) })
+}
+
+func TestDeletedFavorites(t *testing.T) {
+	storetest.DeletedFavorites(t, func() store.Store { return open(t) })
+}
+
+func TestCascade(t *testing.T) {
+	storetest.Cascade(t, open(t))
+}
+
+func TestCommentByID(t *testing.T) {
+	storetest.CommentByID(t, open(t))
+}
@@ -0,0 +1,61 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// passwordResetStore password_resets collection, the TTL index on
+// createdAt drops resets past store.PasswordResetRetention
+type passwordResetStore Store
+
+func (s *passwordResetStore) Create(ctx context.Context, reset *models.PasswordReset) error {
+	if reset.ID.IsZero() {
+		reset.ID = primitive.NewObjectID()
+	}
+	_, err := (*Store)(s).collection("password_resets").InsertOne(ctx, reset)
+	return mapError(err)
+}
+
+func (s *passwordResetStore) CountSince(ctx context.Context, email string, since time.Time) (int64, error) {
+	n, err := (*Store)(s).collection("password_resets").CountDocuments(ctx,
+		bson.M{"email": email, "createdAt": bson.M{"$gte": since}})
+	return n, mapError(err)
+}
+
+func (s *passwordResetStore) Find(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := (*Store)(s).collection("password_resets").FindOne(ctx, bson.M{"hash": hash, "usedAt": nil}).Decode(&reset)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &reset, nil
+}
+
+func (s *passwordResetStore) Use(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := (*Store)(s).withTransaction(ctx, func(ctx context.Context) error {
+		collection := (*Store)(s).collection("password_resets")
+		now := time.Now()
+		// the update of the token itself claims it, a concurrent Use finds
+		// it used
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"hash": hash, "usedAt": nil},
+			bson.M{"$set": bson.M{"usedAt": now}}).Decode(&reset)
+		if err != nil {
+			return mapError(err)
+		}
+		_, err = collection.UpdateMany(ctx,
+			bson.M{"user": reset.User, "usedAt": nil},
+			bson.M{"$set": bson.M{"usedAt": now}})
+		return mapError(err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
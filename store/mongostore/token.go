@@ -0,0 +1,90 @@
+package mongostore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type refreshTokenStore Store
+
+func (s *refreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := (*Store)(s).collection("refresh_tokens").InsertOne(ctx, token)
+	return mapError(err)
+}
+
+func (s *refreshTokenStore) Find(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := (*Store)(s).collection("refresh_tokens").FindOne(ctx, bson.M{"hash": hash}).Decode(&token); err != nil {
+		return nil, mapError(err)
+	}
+	return &token, nil
+}
+
+func (s *refreshTokenStore) Use(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	collection := (*Store)(s).collection("refresh_tokens")
+	var token models.RefreshToken
+	// usedAt and revokedAt null also match the fields being absent
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"hash": hash, "usedAt": nil, "revokedAt": nil},
+		bson.M{"$set": bson.M{"usedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+	if err == nil {
+		return &token, nil
+	}
+	if err := mapError(err); err != store.ErrNotFound {
+		return nil, err
+	}
+	if err := collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&token); err != nil {
+		return nil, mapError(err)
+	}
+	return &token, store.ErrTokenReused
+}
+
+func (s *refreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	_, err := (*Store)(s).collection("refresh_tokens").UpdateMany(ctx,
+		bson.M{"family": family, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return mapError(err)
+}
+
+func (s *refreshTokenStore) RevokeUser(ctx context.Context, user primitive.ObjectID) error {
+	_, err := (*Store)(s).collection("refresh_tokens").UpdateMany(ctx,
+		bson.M{"user": user, "revokedAt": nil},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return mapError(err)
+}
+
+// revocationStore revocations collection, expired documents are removed by
+// the TTL index on expiresAt
+type revocationStore Store
+
+func (s *revocationStore) Revoke(ctx context.Context, revocation *models.Revocation) error {
+	_, err := (*Store)(s).collection("revocations").ReplaceOne(ctx,
+		bson.M{"_id": revocation.ID}, revocation, options.Replace().SetUpsert(true))
+	return mapError(err)
+}
+
+func (s *revocationStore) Find(ctx context.Context, ids ...string) ([]*models.Revocation, error) {
+	// the TTL monitor runs about once a minute, filter what it has not
+	// removed yet
+	cursor, err := (*Store)(s).collection("revocations").Find(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "expiresAt": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	var found []*models.Revocation
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, mapError(err)
+	}
+	return found, nil
+}
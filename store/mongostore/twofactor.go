@@ -0,0 +1,65 @@
+package mongostore
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// twoFactorStore two_factor collection, keyed by the user id
+type twoFactorStore Store
+
+func (s *twoFactorStore) Find(ctx context.Context, user primitive.ObjectID) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := (*Store)(s).collection("two_factor").FindOne(ctx, bson.M{"_id": user}).Decode(&twoFactor)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &twoFactor, nil
+}
+
+func (s *twoFactorStore) Save(ctx context.Context, twoFactor *models.TwoFactor) error {
+	if twoFactor.RecoveryCodes == nil {
+		twoFactor.RecoveryCodes = []string{}
+	}
+	_, err := (*Store)(s).collection("two_factor").ReplaceOne(ctx, bson.M{"_id": twoFactor.User}, twoFactor,
+		options.Replace().SetUpsert(true))
+	return mapError(err)
+}
+
+func (s *twoFactorStore) Delete(ctx context.Context, user primitive.ObjectID) error {
+	_, err := (*Store)(s).collection("two_factor").DeleteOne(ctx, bson.M{"_id": user})
+	return mapError(err)
+}
+
+func (s *twoFactorStore) UseStep(ctx context.Context, user primitive.ObjectID, step int64) error {
+	// the filter on lastStep makes concurrent uses of one code race for a
+	// single match
+	result, err := (*Store)(s).collection("two_factor").UpdateOne(ctx,
+		bson.M{"_id": user, "lastStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"lastStep": step}})
+	if err != nil {
+		return mapError(err)
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *twoFactorStore) UseRecoveryCode(ctx context.Context, user primitive.ObjectID, hash string) error {
+	result, err := (*Store)(s).collection("two_factor").UpdateOne(ctx,
+		bson.M{"_id": user, "recoveryCodes": hash},
+		bson.M{"$pull": bson.M{"recoveryCodes": hash}})
+	if err != nil {
+		return mapError(err)
+	}
+	if result.MatchedCount == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
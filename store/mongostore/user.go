@@ -0,0 +1,129 @@
+package mongostore
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type userStore Store
+
+func (s *userStore) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	userCollection := (*Store)(s).collection("users")
+	var user models.User
+	if err := userCollection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, mapError(err)
+	}
+	return &user, nil
+}
+
+func (s *userStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"_id": id})
+}
+
+func (s *userStore) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"email": email})
+}
+
+func (s *userStore) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.findOne(ctx, bson.M{"username": username})
+}
+
+func (s *userStore) List(ctx context.Context, filter store.UserFilter) ([]*models.User, int64, error) {
+	userCollection := (*Store)(s).collection("users")
+	query := bson.M{}
+	if filter.Query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{bson.M{"username": pattern}, bson.M{"email": pattern}}
+	}
+	if filter.Role != "" {
+		query["role"] = filter.Role
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "username", Value: 1}}).
+		SetSkip(int64(filter.Offset)).
+		SetProjection(bson.M{"following": 0, "favorites": 0})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	cursor, err := userCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	users := []*models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, mapError(err)
+	}
+	counts, err := userCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, mapError(err)
+	}
+	return users, counts, nil
+}
+
+func (s *userStore) Create(ctx context.Context, user *models.User) error {
+	userCollection := (*Store)(s).collection("users")
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	_, err := userCollection.InsertOne(ctx, user)
+	return mapError(err)
+}
+
+func (s *userStore) Update(ctx context.Context, id primitive.ObjectID, update store.UserUpdate) (*models.User, error) {
+	set := bson.M{}
+	if update.Email != nil {
+		set["email"] = *update.Email
+	}
+	if update.Username != nil {
+		set["username"] = *update.Username
+	}
+	if update.Password != nil {
+		set["password"] = *update.Password
+	}
+	if update.Bio != nil {
+		set["bio"] = *update.Bio
+	}
+	if update.Image != nil {
+		set["image"] = *update.Image
+	}
+	if update.EmailVerified != nil {
+		set["emailVerified"] = *update.EmailVerified
+	}
+	if update.Role != nil {
+		set["role"] = *update.Role
+	}
+	if len(set) == 0 {
+		return s.FindByID(ctx, id)
+	}
+
+	return s.updateOne(ctx, id, bson.M{"$set": set})
+}
+
+// updateOne apply update to user id and return the updated user
+func (s *userStore) updateOne(ctx context.Context, id primitive.ObjectID, update bson.M) (*models.User, error) {
+	userCollection := (*Store)(s).collection("users")
+	var user models.User
+	err := userCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &user, nil
+}
+
+func (s *userStore) Follow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error) {
+	return s.updateOne(ctx, id, bson.M{"$addToSet": bson.M{"following": followee}})
+}
+
+func (s *userStore) Unfollow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error) {
+	return s.updateOne(ctx, id, bson.M{"$pull": bson.M{"following": followee}})
+}
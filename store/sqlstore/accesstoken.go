@@ -0,0 +1,102 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// accessTokenStore access_tokens table, scopes are stored space separated
+type accessTokenStore Store
+
+const accessTokenColumns = `id, user_id, name, hash, scopes, created_at, expires_at, last_used_at`
+
+func scanAccessToken(row interface{ Scan(...interface{}) error }) (*models.AccessToken, error) {
+	var token models.AccessToken
+	var id, user, scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(&id, &user, &token.Name, &token.Hash, &scopes, &token.CreatedAt, &expiresAt, &lastUsedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if token.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if token.User, err = parseID(user); err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return &token, nil
+}
+
+func (s *accessTokenStore) Create(ctx context.Context, token *models.AccessToken) error {
+	db := (*Store)(s)
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	var expiresAt interface{}
+	if token.ExpiresAt != nil {
+		expiresAt = token.ExpiresAt.UTC()
+	}
+	_, err := db.exec(ctx, db.db, `INSERT INTO access_tokens (id, user_id, name, hash, scopes, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		token.ID.Hex(), token.User.Hex(), token.Name, token.Hash, strings.Join(token.Scopes, " "), token.CreatedAt.UTC(), expiresAt)
+	return err
+}
+
+func (s *accessTokenStore) FindByHash(ctx context.Context, hash string) (*models.AccessToken, error) {
+	db := (*Store)(s)
+	return scanAccessToken(db.queryRow(ctx, db.db, `SELECT `+accessTokenColumns+` FROM access_tokens WHERE hash = ?`, hash))
+}
+
+func (s *accessTokenStore) List(ctx context.Context, user primitive.ObjectID) ([]*models.AccessToken, error) {
+	db := (*Store)(s)
+	rows, err := db.query(ctx, db.db, `SELECT `+accessTokenColumns+` FROM access_tokens WHERE user_id = ? ORDER BY created_at DESC`, user.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := []*models.AccessToken{}
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, mapError(rows.Err())
+}
+
+func (s *accessTokenStore) Delete(ctx context.Context, user primitive.ObjectID, id primitive.ObjectID) error {
+	db := (*Store)(s)
+	result, err := db.exec(ctx, db.db, `DELETE FROM access_tokens WHERE id = ? AND user_id = ?`, id.Hex(), user.Hex())
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+func (s *accessTokenStore) DeleteUser(ctx context.Context, user primitive.ObjectID) error {
+	db := (*Store)(s)
+	_, err := db.exec(ctx, db.db, `DELETE FROM access_tokens WHERE user_id = ?`, user.Hex())
+	return err
+}
+
+func (s *accessTokenStore) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	db := (*Store)(s)
+	result, err := db.exec(ctx, db.db, `UPDATE access_tokens SET last_used_at = ? WHERE id = ?`, at.UTC(), id.Hex())
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
@@ -0,0 +1,357 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type articleStore Store
+
+const articleColumns = `a.id, a.slug, a.title, a.description, a.body, a.favorites_count, a.created_at, a.updated_at, a.deleted_at, a.author_id`
+
+const authorColumns = `u.id, u.email, u.username, u.bio, u.image`
+
+// scanArticle scan articleColumns followed by extra destinations
+func scanArticle(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.Article, error) {
+	var article models.Article
+	var id, author string
+	var deletedAt sql.NullTime
+	dest := []interface{}{&id, &article.Slug, &article.Title, &article.Description, &article.Body,
+		&article.FavoritesCount, &article.CreatedAt, &article.UpdatedAt, &deletedAt, &author}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, mapError(err)
+	}
+	if deletedAt.Valid {
+		article.DeletedAt = &deletedAt.Time
+	}
+	var err error
+	if article.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if article.Author, err = parseID(author); err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+// scanArticleWithAuthor scan articleColumns and authorColumns
+func scanArticleWithAuthor(row interface{ Scan(...interface{}) error }) (*models.ArticleWithAuthor, error) {
+	var author models.User
+	var authorID string
+	article, err := scanArticle(row, &authorID, &author.Email, &author.Username, &author.Bio, &author.Image)
+	if err != nil {
+		return nil, err
+	}
+	if author.ID, err = parseID(authorID); err != nil {
+		return nil, err
+	}
+	return &models.ArticleWithAuthor{ArticleBase: article.ArticleBase, Author: author}, nil
+}
+
+// articleWhere where clause and args for filter on articles aliased a
+func articleWhere(filter store.ArticleFilter) (string, []interface{}) {
+	conditions := []string{`a.deleted_at IS NULL`}
+	var args []interface{}
+	if filter.Authors != nil {
+		in, inArgs := inClause(filter.Authors)
+		conditions = append(conditions, `a.author_id IN `+in)
+		args = append(args, inArgs...)
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM article_tags t WHERE t.article_id = a.id AND t.tag = ?)`)
+		args = append(args, filter.Tag)
+	}
+	if filter.IDs != nil {
+		in, inArgs := inClause(filter.IDs)
+		conditions = append(conditions, `a.id IN `+in)
+		args = append(args, inArgs...)
+	}
+	return ` WHERE ` + strings.Join(conditions, ` AND `), args
+}
+
+// loadTags fill tag lists of articles
+func (s *articleStore) loadTags(ctx context.Context, q querier, articles ...*models.ArticleBase) error {
+	if len(articles) == 0 {
+		return nil
+	}
+	byID := make(map[primitive.ObjectID]*models.ArticleBase, len(articles))
+	ids := make([]primitive.ObjectID, len(articles))
+	for i, article := range articles {
+		byID[article.ID] = article
+		ids[i] = article.ID
+	}
+	in, args := inClause(ids)
+	rows, err := (*Store)(s).query(ctx, q, `SELECT article_id, tag FROM article_tags WHERE article_id IN `+in+` ORDER BY article_id, position`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hex, tag string
+		if err := rows.Scan(&hex, &tag); err != nil {
+			return mapError(err)
+		}
+		id, err := parseID(hex)
+		if err != nil {
+			return err
+		}
+		if article, ok := byID[id]; ok {
+			article.TagList = append(article.TagList, tag)
+		}
+	}
+	return mapError(rows.Err())
+}
+
+// replaceTags replace tag rows of article
+func (s *articleStore) replaceTags(ctx context.Context, tx *sql.Tx, id primitive.ObjectID, tags []string) error {
+	db := (*Store)(s)
+	if _, err := db.exec(ctx, tx, `DELETE FROM article_tags WHERE article_id = ?`, id.Hex()); err != nil {
+		return err
+	}
+	for i, tag := range tags {
+		if _, err := db.exec(ctx, tx, `INSERT INTO article_tags (article_id, position, tag) VALUES (?, ?, ?)`, id.Hex(), i, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *articleStore) List(ctx context.Context, filter store.ArticleFilter) ([]models.ArticleWithAuthor, int64, error) {
+	db := (*Store)(s)
+	where, args := articleWhere(filter)
+
+	var counts int64
+	if err := db.queryRow(ctx, db.db, `SELECT COUNT(*) FROM articles a`+where, args...).Scan(&counts); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	query := `SELECT ` + articleColumns + `, ` + authorColumns + ` FROM articles a JOIN users u ON u.id = a.author_id` +
+		where + ` ORDER BY a.created_at DESC, a.id DESC`
+	switch {
+	case filter.Limit > 0:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	case filter.Offset > 0 && db.dialect == SQLite:
+		query += ` LIMIT -1 OFFSET ?`
+		args = append(args, filter.Offset)
+	case filter.Offset > 0:
+		query += ` OFFSET ?`
+		args = append(args, filter.Offset)
+	}
+	rows, err := db.query(ctx, db.db, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	articles := []models.ArticleWithAuthor{}
+	for rows.Next() {
+		article, err := scanArticleWithAuthor(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		articles = append(articles, *article)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, mapError(err)
+	}
+	rows.Close()
+
+	bases := make([]*models.ArticleBase, len(articles))
+	for i := range articles {
+		bases[i] = &articles[i].ArticleBase
+	}
+	if err := s.loadTags(ctx, db.db, bases...); err != nil {
+		return nil, 0, err
+	}
+	return articles, counts, nil
+}
+
+func (s *articleStore) findOne(ctx context.Context, where string, arg interface{}) (*models.Article, error) {
+	db := (*Store)(s)
+	article, err := scanArticle(db.queryRow(ctx, db.db, `SELECT `+articleColumns+` FROM articles a WHERE `+where, arg))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(ctx, db.db, &article.ArticleBase); err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+func (s *articleStore) FindBySlug(ctx context.Context, slug string) (*models.Article, error) {
+	return s.findOne(ctx, `a.slug = ? AND a.deleted_at IS NULL`, slug)
+}
+
+func (s *articleStore) FindBySlugWithAuthor(ctx context.Context, slug string) (*models.ArticleWithAuthor, error) {
+	db := (*Store)(s)
+	article, err := scanArticleWithAuthor(db.queryRow(ctx, db.db, `SELECT `+articleColumns+`, `+authorColumns+
+		` FROM articles a JOIN users u ON u.id = a.author_id WHERE a.slug = ? AND a.deleted_at IS NULL`, slug))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(ctx, db.db, &article.ArticleBase); err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+func (s *articleStore) Create(ctx context.Context, article *models.Article) error {
+	db := (*Store)(s)
+	if article.ID.IsZero() {
+		article.ID = primitive.NewObjectID()
+	}
+	if article.CreatedAt.IsZero() {
+		article.CreatedAt = time.Now()
+	}
+	if article.UpdatedAt.IsZero() {
+		article.UpdatedAt = article.CreatedAt
+	}
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		var deletedAt interface{}
+		if article.DeletedAt != nil {
+			deletedAt = article.DeletedAt.UTC()
+		}
+		_, err := db.exec(ctx, tx, `INSERT INTO articles (id, slug, title, description, body, favorites_count, created_at, updated_at, deleted_at, author_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			article.ID.Hex(), article.Slug, article.Title, article.Description, article.Body,
+			article.FavoritesCount, article.CreatedAt.UTC(), article.UpdatedAt.UTC(), deletedAt, article.Author.Hex())
+		if err != nil {
+			return err
+		}
+		return s.replaceTags(ctx, tx, article.ID, article.TagList)
+	})
+}
+
+func (s *articleStore) Update(ctx context.Context, slug string, author primitive.ObjectID, update store.ArticleUpdate) (*models.Article, error) {
+	db := (*Store)(s)
+	sets := []string{`updated_at = ?`}
+	args := []interface{}{time.Now().UTC()}
+	if update.Title != nil {
+		sets = append(sets, `title = ?`)
+		args = append(args, *update.Title)
+	}
+	if update.Description != nil {
+		sets = append(sets, `description = ?`)
+		args = append(args, *update.Description)
+	}
+	if update.Body != nil {
+		sets = append(sets, `body = ?`)
+		args = append(args, *update.Body)
+	}
+
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		var hex string
+		if err := db.queryRow(ctx, tx, `SELECT id FROM articles WHERE slug = ? AND author_id = ? AND deleted_at IS NULL`, slug, author.Hex()).Scan(&hex); err != nil {
+			return mapError(err)
+		}
+		if _, err := db.exec(ctx, tx, `UPDATE articles SET `+strings.Join(sets, ", ")+` WHERE id = ?`, append(args, hex)...); err != nil {
+			return err
+		}
+		if update.TagList == nil {
+			return nil
+		}
+		id, err := parseID(hex)
+		if err != nil {
+			return err
+		}
+		return s.replaceTags(ctx, tx, id, update.TagList)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.FindBySlug(ctx, slug)
+}
+
+func (s *articleStore) Delete(ctx context.Context, slug string, author primitive.ObjectID) error {
+	db := (*Store)(s)
+	result, err := db.exec(ctx, db.db, `UPDATE articles SET deleted_at = ? WHERE slug = ? AND author_id = ? AND deleted_at IS NULL`,
+		time.Now().UTC(), slug, author.Hex())
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+func (s *articleStore) Restore(ctx context.Context, slug string, author primitive.ObjectID, deletedAfter time.Time) (*models.Article, error) {
+	db := (*Store)(s)
+	// the latest deletion when the slug was reused and deleted again, a
+	// live article that took the slug since fails the unique index
+	result, err := db.exec(ctx, db.db, `UPDATE articles SET deleted_at = NULL WHERE id = (
+			SELECT id FROM articles WHERE slug = ? AND author_id = ? AND deleted_at > ? ORDER BY deleted_at DESC LIMIT 1
+		)`,
+		slug, author.Hex(), deletedAfter.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if err := affected(result); err != nil {
+		return nil, err
+	}
+	return s.FindBySlug(ctx, slug)
+}
+
+// setFavorite insert or delete the favorites row of user and article id,
+// moving favorites_count by one only when a row actually changed
+func (s *articleStore) setFavorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID, favorite bool) (*models.Article, error) {
+	db := (*Store)(s)
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		if err := db.queryRow(ctx, tx, `SELECT 1 FROM articles WHERE id = ? AND deleted_at IS NULL`, id.Hex()).Scan(&exists); err != nil {
+			return mapError(err)
+		}
+		if err := db.queryRow(ctx, tx, `SELECT 1 FROM users WHERE id = ?`, user.Hex()).Scan(&exists); err != nil {
+			return mapError(err)
+		}
+		query := `INSERT INTO favorites (user_id, article_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
+		inc := 1
+		if !favorite {
+			query = `DELETE FROM favorites WHERE user_id = ? AND article_id = ?`
+			inc = -1
+		}
+		result, err := db.exec(ctx, tx, query, user.Hex(), id.Hex())
+		if err != nil {
+			return err
+		}
+		if affected(result) != nil {
+			return nil
+		}
+		_, err = db.exec(ctx, tx, `UPDATE articles SET favorites_count = favorites_count + ? WHERE id = ?`, inc, id.Hex())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.findOne(ctx, `a.id = ? AND a.deleted_at IS NULL`, id.Hex())
+}
+
+func (s *articleStore) Favorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error) {
+	return s.setFavorite(ctx, id, user, true)
+}
+
+func (s *articleStore) Unfavorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error) {
+	return s.setFavorite(ctx, id, user, false)
+}
+
+func (s *articleStore) Tags(ctx context.Context) ([]string, error) {
+	db := (*Store)(s)
+	rows, err := db.query(ctx, db.db, `SELECT DISTINCT t.tag FROM article_tags t JOIN articles a ON a.id = t.article_id
+		WHERE a.deleted_at IS NULL ORDER BY t.tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, mapError(err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, mapError(rows.Err())
+}
@@ -0,0 +1,70 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+)
+
+type loginAttemptStore Store
+
+const loginAttemptColumns = `id, failures, last_failure, previous_failure, expires_at`
+
+func scanLoginAttempt(row interface{ Scan(...interface{}) error }) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	var previous sql.NullTime
+	if err := row.Scan(&attempt.Key, &attempt.Failures, &attempt.LastFailure, &previous, &attempt.ExpiresAt); err != nil {
+		return nil, mapError(err)
+	}
+	if previous.Valid {
+		attempt.PreviousFailure = &previous.Time
+	}
+	return &attempt, nil
+}
+
+func (s *loginAttemptStore) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	db := (*Store)(s)
+	return scanLoginAttempt(db.queryRow(ctx, db.db, `SELECT `+loginAttemptColumns+` FROM login_attempts WHERE id = ? AND expires_at > ?`,
+		key, time.Now().UTC()))
+}
+
+// Fail count failure as one upsert, deleting expired rows on the way. The
+// upsert keeps the row locked until commit, so the select reads the count
+// of this call.
+func (s *loginAttemptStore) Fail(ctx context.Context, key string, at time.Time, ttl time.Duration) (*models.LoginAttempt, error) {
+	db := (*Store)(s)
+	var attempt *models.LoginAttempt
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := db.exec(ctx, tx, `DELETE FROM login_attempts WHERE expires_at <= ?`, at.UTC()); err != nil {
+			return err
+		}
+		_, err := db.exec(ctx, tx, `INSERT INTO login_attempts (`+loginAttemptColumns+`) VALUES (?, 1, ?, NULL, ?)
+			ON CONFLICT (id) DO UPDATE SET failures = login_attempts.failures + 1,
+				previous_failure = login_attempts.last_failure,
+				last_failure = excluded.last_failure, expires_at = excluded.expires_at`,
+			key, at.UTC(), at.Add(ttl).UTC())
+		if err != nil {
+			return err
+		}
+		attempt, err = scanLoginAttempt(db.queryRow(ctx, tx, `SELECT `+loginAttemptColumns+` FROM login_attempts WHERE id = ?`, key))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (s *loginAttemptStore) Reset(ctx context.Context, keys ...string) error {
+	db := (*Store)(s)
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		for _, key := range keys {
+			if _, err := db.exec(ctx, tx, `DELETE FROM login_attempts WHERE id = ?`, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
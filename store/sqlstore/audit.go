@@ -0,0 +1,92 @@
+package sqlstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// auditStore audit_log table, actor_id has no foreign key so entries
+// outlive their actor
+type auditStore Store
+
+const auditColumns = `id, actor_id, actor_username, action, target, detail, created_at`
+
+func scanAuditEntry(row interface{ Scan(...interface{}) error }) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var id, actor string
+	err := row.Scan(&id, &actor, &entry.ActorUsername, &entry.Action, &entry.Target, &entry.Detail, &entry.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if entry.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if entry.Actor, err = parseID(actor); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *auditStore) Create(ctx context.Context, entry *models.AuditEntry) error {
+	db := (*Store)(s)
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	_, err := db.exec(ctx, db.db, `INSERT INTO audit_log (`+auditColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID.Hex(), entry.Actor.Hex(), entry.ActorUsername, entry.Action, entry.Target, entry.Detail, entry.CreatedAt.UTC())
+	return err
+}
+
+func (s *auditStore) List(ctx context.Context, filter store.AuditFilter) ([]*models.AuditEntry, int64, error) {
+	db := (*Store)(s)
+	var conditions []string
+	var args []interface{}
+	if !filter.Actor.IsZero() {
+		conditions = append(conditions, `actor_id = ?`)
+		args = append(args, filter.Actor.Hex())
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, `action = ?`)
+		args = append(args, filter.Action)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	var counts int64
+	if err := db.queryRow(ctx, db.db, `SELECT COUNT(*) FROM audit_log`+where, args...).Scan(&counts); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log` + where + ` ORDER BY created_at DESC, id DESC`
+	switch {
+	case filter.Limit > 0:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	case filter.Offset > 0 && db.dialect == SQLite:
+		query += ` LIMIT -1 OFFSET ?`
+		args = append(args, filter.Offset)
+	case filter.Offset > 0:
+		query += ` OFFSET ?`
+		args = append(args, filter.Offset)
+	}
+	rows, err := db.query(ctx, db.db, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	entries := []*models.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, counts, mapError(rows.Err())
+}
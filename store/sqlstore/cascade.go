@@ -0,0 +1,128 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// cascade one unit of work inside tx
+type cascade struct {
+	*Store
+	tx     *sql.Tx
+	policy store.CommentPolicy
+	now    time.Time
+	report store.CascadeReport
+}
+
+// cascade run fn in a transaction and report what it changed
+func (s *Store) cascade(ctx context.Context, comments store.CommentPolicy, fn func(c *cascade) error) (*store.CascadeReport, error) {
+	c := &cascade{Store: s, policy: comments, now: time.Now().UTC()}
+	err := s.withTx(ctx, func(tx *sql.Tx) error {
+		c.tx = tx
+		return fn(c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c.report, nil
+}
+
+// exec statement in the cascade transaction, adding rows affected to n
+func (c *cascade) exec(ctx context.Context, n *int64, query string, args ...interface{}) error {
+	result, err := c.Store.exec(ctx, c.tx, query, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if n != nil {
+		*n += rows
+	}
+	return nil
+}
+
+// removeComments delete or archive the comments matching where
+func (c *cascade) removeComments(ctx context.Context, where string, args ...interface{}) error {
+	if c.policy == store.ArchiveComments {
+		err := c.exec(ctx, &c.report.ArchivedComments, `INSERT INTO archived_comments
+			(id, article_id, author_id, body, created_at, updated_at, archived_at)
+			SELECT id, article_id, author_id, body, created_at, updated_at, ? FROM comments WHERE `+where,
+			append([]interface{}{c.now}, args...)...)
+		if err != nil {
+			return err
+		}
+		return c.exec(ctx, nil, `DELETE FROM comments WHERE `+where, args...)
+	}
+	return c.exec(ctx, &c.report.Comments, `DELETE FROM comments WHERE `+where, args...)
+}
+
+// removeArticles remove the articles matching where with their comments,
+// favorites and tags. The foreign keys would cascade as well, deleting
+// explicitly is what lets the report count.
+func (c *cascade) removeArticles(ctx context.Context, where string, args ...interface{}) error {
+	ids := `SELECT id FROM articles WHERE ` + where
+	if err := c.removeComments(ctx, `article_id IN (`+ids+`)`, args...); err != nil {
+		return err
+	}
+	if err := c.exec(ctx, &c.report.Favorites, `DELETE FROM favorites WHERE article_id IN (`+ids+`)`, args...); err != nil {
+		return err
+	}
+	if err := c.exec(ctx, nil, `DELETE FROM article_tags WHERE article_id IN (`+ids+`)`, args...); err != nil {
+		return err
+	}
+	return c.exec(ctx, &c.report.Articles, `DELETE FROM articles WHERE `+where, args...)
+}
+
+func (s *articleStore) Remove(ctx context.Context, id primitive.ObjectID, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	return (*Store)(s).cascade(ctx, comments, func(c *cascade) error {
+		if err := c.removeArticles(ctx, `id = ?`, id.Hex()); err != nil {
+			return err
+		}
+		if c.report.Articles == 0 {
+			return store.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (s *articleStore) Purge(ctx context.Context, deletedBefore time.Time, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	return (*Store)(s).cascade(ctx, comments, func(c *cascade) error {
+		return c.removeArticles(ctx, `deleted_at < ?`, deletedBefore.UTC())
+	})
+}
+
+func (s *userStore) Remove(ctx context.Context, id primitive.ObjectID, comments store.CommentPolicy) (*store.CascadeReport, error) {
+	return (*Store)(s).cascade(ctx, comments, func(c *cascade) error {
+		var exists int
+		if err := c.queryRow(ctx, c.tx, `SELECT 1 FROM users WHERE id = ?`, id.Hex()).Scan(&exists); err != nil {
+			return mapError(err)
+		}
+		if err := c.removeArticles(ctx, `author_id = ?`, id.Hex()); err != nil {
+			return err
+		}
+		if err := c.removeComments(ctx, `author_id = ?`, id.Hex()); err != nil {
+			return err
+		}
+		err := c.exec(ctx, &c.report.Favorites, `UPDATE articles SET favorites_count = favorites_count - 1
+			WHERE id IN (SELECT article_id FROM favorites WHERE user_id = ?)`, id.Hex())
+		if err != nil {
+			return err
+		}
+		if err := c.exec(ctx, nil, `DELETE FROM favorites WHERE user_id = ?`, id.Hex()); err != nil {
+			return err
+		}
+		if err := c.exec(ctx, &c.report.Follows, `DELETE FROM follows WHERE followee_id = ?`, id.Hex()); err != nil {
+			return err
+		}
+		if err := c.exec(ctx, nil, `DELETE FROM follows WHERE follower_id = ?`, id.Hex()); err != nil {
+			return err
+		}
+		return c.exec(ctx, &c.report.Users, `DELETE FROM users WHERE id = ?`, id.Hex())
+	})
+}
@@ -0,0 +1,89 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type commentStore Store
+
+func (s *commentStore) Create(ctx context.Context, comment *models.Comment) error {
+	db := (*Store)(s)
+	if comment.ID.IsZero() {
+		comment.ID = primitive.NewObjectID()
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now()
+	}
+	if comment.UpdatedAt.IsZero() {
+		comment.UpdatedAt = comment.CreatedAt
+	}
+	_, err := db.exec(ctx, db.db, `INSERT INTO comments (id, article_id, author_id, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		comment.ID.Hex(), comment.Article.Hex(), comment.Author.Hex(), comment.Body, comment.CreatedAt.UTC(), comment.UpdatedAt.UTC())
+	return err
+}
+
+func (s *commentStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error) {
+	db := (*Store)(s)
+	var comment models.Comment
+	var commentID, articleID, authorID string
+	err := db.queryRow(ctx, db.db, `SELECT id, article_id, author_id, body, created_at, updated_at FROM comments WHERE id = ?`, id.Hex()).
+		Scan(&commentID, &articleID, &authorID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if comment.ID, err = parseID(commentID); err != nil {
+		return nil, err
+	}
+	if comment.Article, err = parseID(articleID); err != nil {
+		return nil, err
+	}
+	if comment.Author, err = parseID(authorID); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (s *commentStore) Delete(ctx context.Context, id primitive.ObjectID, author primitive.ObjectID) error {
+	db := (*Store)(s)
+	result, err := db.exec(ctx, db.db, `DELETE FROM comments WHERE id = ? AND author_id = ?`, id.Hex(), author.Hex())
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+func (s *commentStore) ListByArticle(ctx context.Context, article primitive.ObjectID) ([]models.CommentWithAuthor, error) {
+	db := (*Store)(s)
+	rows, err := db.query(ctx, db.db, `SELECT c.id, c.article_id, c.body, c.created_at, c.updated_at, `+authorColumns+`
+		FROM comments c JOIN users u ON u.id = c.author_id
+		WHERE c.article_id = ? ORDER BY c.created_at, c.id`, article.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []models.CommentWithAuthor{}
+	for rows.Next() {
+		var comment models.CommentWithAuthor
+		var id, articleID, authorID string
+		err := rows.Scan(&id, &articleID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt,
+			&authorID, &comment.Author.Email, &comment.Author.Username, &comment.Author.Bio, &comment.Author.Image)
+		if err != nil {
+			return nil, mapError(err)
+		}
+		if comment.ID, err = parseID(id); err != nil {
+			return nil, err
+		}
+		if comment.Article, err = parseID(articleID); err != nil {
+			return nil, err
+		}
+		if comment.Author.ID, err = parseID(authorID); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, mapError(rows.Err())
+}
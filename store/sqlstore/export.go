@@ -0,0 +1,163 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportBatch rows read per query, relations are loaded per batch so no
+// result set stays open while fn runs
+const exportBatch = 500
+
+// EachUser call fn for every user ordered by id
+func (s *Store) EachUser(ctx context.Context, fn func(user *models.User) error) error {
+	users := (*userStore)(s)
+	after := ""
+	for {
+		rows, err := s.query(ctx, s.db, `SELECT `+userColumns+` FROM users WHERE id > ? ORDER BY id LIMIT ?`, after, exportBatch)
+		if err != nil {
+			return err
+		}
+		var batch []*models.User
+		for rows.Next() {
+			user, err := scanUser(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, user)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return mapError(err)
+		}
+		for _, user := range batch {
+			if user.Following, err = users.relatedIDs(ctx, s.db, `SELECT followee_id FROM follows WHERE follower_id = ? ORDER BY followee_id`, user.ID); err != nil {
+				return err
+			}
+			if user.Favorites, err = users.relatedIDs(ctx, s.db, `SELECT article_id FROM favorites WHERE user_id = ? ORDER BY article_id`, user.ID); err != nil {
+				return err
+			}
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatch {
+			return nil
+		}
+		after = batch[len(batch)-1].ID.Hex()
+	}
+}
+
+// EachArticle call fn for every article ordered by id
+func (s *Store) EachArticle(ctx context.Context, fn func(article *models.Article) error) error {
+	after := ""
+	for {
+		rows, err := s.query(ctx, s.db, `SELECT `+articleColumns+` FROM articles a WHERE a.id > ? ORDER BY a.id LIMIT ?`, after, exportBatch)
+		if err != nil {
+			return err
+		}
+		var batch []*models.Article
+		for rows.Next() {
+			article, err := scanArticle(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, article)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return mapError(err)
+		}
+		bases := make([]*models.ArticleBase, len(batch))
+		for i, article := range batch {
+			bases[i] = &article.ArticleBase
+		}
+		if err := (*articleStore)(s).loadTags(ctx, s.db, bases...); err != nil {
+			return err
+		}
+		for _, article := range batch {
+			if err := fn(article); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatch {
+			return nil
+		}
+		after = batch[len(batch)-1].ID.Hex()
+	}
+}
+
+// EachComment call fn for every comment ordered by id
+func (s *Store) EachComment(ctx context.Context, fn func(comment *models.Comment) error) error {
+	after := ""
+	for {
+		rows, err := s.query(ctx, s.db, `SELECT id, article_id, author_id, body, created_at, updated_at FROM comments
+			WHERE id > ? ORDER BY id LIMIT ?`, after, exportBatch)
+		if err != nil {
+			return err
+		}
+		var batch []*models.Comment
+		for rows.Next() {
+			comment, err := scanComment(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, comment)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return mapError(err)
+		}
+		for _, comment := range batch {
+			if err := fn(comment); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatch {
+			return nil
+		}
+		after = batch[len(batch)-1].ID.Hex()
+	}
+}
+
+// scanComment scan id, article_id, author_id, body, created_at, updated_at
+func scanComment(row interface{ Scan(...interface{}) error }) (*models.Comment, error) {
+	var comment models.Comment
+	var id, articleID, authorID string
+	if err := row.Scan(&id, &articleID, &authorID, &comment.Body, &comment.CreatedAt, &comment.UpdatedAt); err != nil {
+		return nil, mapError(err)
+	}
+	var err error
+	if comment.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if comment.Article, err = parseID(articleID); err != nil {
+		return nil, err
+	}
+	if comment.Author, err = parseID(authorID); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ImportFavorite add article, live or soft deleted, to the favorites of
+// user. The foreign keys reject unknown users and articles.
+func (s *Store) ImportFavorite(ctx context.Context, user primitive.ObjectID, article primitive.ObjectID) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := s.exec(ctx, tx, `INSERT INTO favorites (user_id, article_id) VALUES (?, ?) ON CONFLICT DO NOTHING`, user.Hex(), article.Hex())
+		if err != nil {
+			return err
+		}
+		if affected(result) != nil {
+			return nil
+		}
+		_, err = s.exec(ctx, tx, `UPDATE articles SET favorites_count = favorites_count + 1 WHERE id = ?`, article.Hex())
+		return err
+	})
+}
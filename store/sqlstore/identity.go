@@ -0,0 +1,38 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type identityStore Store
+
+func (s *identityStore) Find(ctx context.Context, provider string, subject string) (*models.Identity, error) {
+	db := (*Store)(s)
+	identity := models.Identity{Provider: provider, Subject: subject}
+	var id, user string
+	err := db.queryRow(ctx, db.db, `SELECT id, user_id, email, created_at FROM identities WHERE provider = ? AND subject = ?`,
+		provider, subject).Scan(&id, &user, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if identity.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if identity.User, err = parseID(user); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (s *identityStore) Create(ctx context.Context, identity *models.Identity) error {
+	db := (*Store)(s)
+	if identity.ID.IsZero() {
+		identity.ID = primitive.NewObjectID()
+	}
+	_, err := db.exec(ctx, db.db, `INSERT INTO identities (id, provider, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		identity.ID.Hex(), identity.Provider, identity.Subject, identity.User.Hex(), identity.Email, identity.CreatedAt.UTC())
+	return err
+}
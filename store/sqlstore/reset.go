@@ -0,0 +1,92 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type passwordResetStore Store
+
+func (s *passwordResetStore) Create(ctx context.Context, reset *models.PasswordReset) error {
+	db := (*Store)(s)
+	if reset.ID.IsZero() {
+		reset.ID = primitive.NewObjectID()
+	}
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := db.exec(ctx, tx, `DELETE FROM password_resets WHERE created_at < ?`,
+			time.Now().Add(-store.PasswordResetRetention).UTC()); err != nil {
+			return err
+		}
+		_, err := db.exec(ctx, tx, `INSERT INTO password_resets (id, hash, user_id, email, created_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			reset.ID.Hex(), reset.Hash, reset.User.Hex(), reset.Email, reset.CreatedAt.UTC(), reset.ExpiresAt.UTC())
+		return err
+	})
+}
+
+func (s *passwordResetStore) CountSince(ctx context.Context, email string, since time.Time) (int64, error) {
+	db := (*Store)(s)
+	var n int64
+	err := db.queryRow(ctx, db.db, `SELECT COUNT(*) FROM password_resets WHERE email = ? AND created_at >= ?`,
+		email, since.UTC()).Scan(&n)
+	return n, mapError(err)
+}
+
+func (s *passwordResetStore) Find(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	db := (*Store)(s)
+	var reset models.PasswordReset
+	var id, user string
+	err := db.queryRow(ctx, db.db, `SELECT id, hash, user_id, email, created_at, expires_at FROM password_resets
+		WHERE hash = ? AND used_at IS NULL`, hash).
+		Scan(&id, &reset.Hash, &user, &reset.Email, &reset.CreatedAt, &reset.ExpiresAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if reset.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if reset.User, err = parseID(user); err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (s *passwordResetStore) Use(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	db := (*Store)(s)
+	var reset models.PasswordReset
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		// the update of the token itself claims it, a concurrent Use finds
+		// it used
+		result, err := db.exec(ctx, tx, `UPDATE password_resets SET used_at = ? WHERE hash = ? AND used_at IS NULL`, now, hash)
+		if err != nil {
+			return err
+		}
+		if err := affected(result); err != nil {
+			return err
+		}
+		var id, user string
+		err = db.queryRow(ctx, tx, `SELECT id, hash, user_id, email, created_at, expires_at FROM password_resets WHERE hash = ?`, hash).
+			Scan(&id, &reset.Hash, &user, &reset.Email, &reset.CreatedAt, &reset.ExpiresAt)
+		if err != nil {
+			return mapError(err)
+		}
+		if reset.ID, err = parseID(id); err != nil {
+			return err
+		}
+		if reset.User, err = parseID(user); err != nil {
+			return err
+		}
+		_, err = db.exec(ctx, tx, `UPDATE password_resets SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, now, user)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
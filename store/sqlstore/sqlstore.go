@@ -0,0 +1,275 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Dialects supported sql drivers
+const (
+	SQLite   = "sqlite3"
+	Postgres = "postgres"
+)
+
+// Store sql store
+type Store struct {
+	db      *sql.DB
+	dialect string
+}
+
+// querier common methods of *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Open open database of dialect at dsn and ping it
+func Open(ctx context.Context, dialect string, dsn string) (*Store, error) {
+	switch dialect {
+	case SQLite:
+		if !strings.Contains(dsn, "_foreign_keys") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&_foreign_keys=1"
+			} else {
+				dsn += "?_foreign_keys=1"
+			}
+		}
+	case Postgres:
+	default:
+		return nil, fmt.Errorf("error: unsupported sql dialect %q", dialect)
+	}
+
+	db, err := sql.Open(dialect, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if dialect == SQLite {
+		// sqlite allows a single writer, serialize access instead of
+		// failing with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, mapError(err)
+	}
+	return New(db, dialect), nil
+}
+
+// New create store from opened db
+func New(db *sql.DB, dialect string) *Store {
+	return &Store{db: db, dialect: dialect}
+}
+
+// Users user store
+func (s *Store) Users() store.UserStore {
+	return (*userStore)(s)
+}
+
+// Articles article store
+func (s *Store) Articles() store.ArticleStore {
+	return (*articleStore)(s)
+}
+
+// Comments comment store
+func (s *Store) Comments() store.CommentStore {
+	return (*commentStore)(s)
+}
+
+// RefreshTokens refresh token store
+func (s *Store) RefreshTokens() store.RefreshTokenStore {
+	return (*refreshTokenStore)(s)
+}
+
+// Revocations access token revocation store
+func (s *Store) Revocations() store.RevocationStore {
+	return (*revocationStore)(s)
+}
+
+// PasswordResets password reset store
+func (s *Store) PasswordResets() store.PasswordResetStore {
+	return (*passwordResetStore)(s)
+}
+
+// TwoFactors two-factor store
+func (s *Store) TwoFactors() store.TwoFactorStore {
+	return (*twoFactorStore)(s)
+}
+
+// Identities identity store
+func (s *Store) Identities() store.IdentityStore {
+	return (*identityStore)(s)
+}
+
+// AccessTokens personal access token store
+func (s *Store) AccessTokens() store.AccessTokenStore {
+	return (*accessTokenStore)(s)
+}
+
+// Audit audit log store
+func (s *Store) Audit() store.AuditStore {
+	return (*auditStore)(s)
+}
+
+// LoginAttempts failed login counter store
+func (s *Store) LoginAttempts() store.LoginAttemptStore {
+	return (*loginAttemptStore)(s)
+}
+
+// Close close database
+func (s *Store) Close(ctx context.Context) error {
+	return s.db.Close()
+}
+
+// Wipe delete all rows in one transaction, schema_migrations stays
+func (s *Store) Wipe(ctx context.Context) error {
+	return s.withTx(ctx, func(tx *sql.Tx) error {
+		for _, table := range []string{"login_attempts", "audit_log", "access_tokens", "identities", "two_factor_recovery_codes", "two_factor", "password_resets", "revocations", "refresh_tokens", "archived_comments", "comments", "favorites", "article_tags", "articles", "follows", "users"} {
+			if _, err := s.exec(ctx, tx, `DELETE FROM `+table); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// rebind rewrite ? placeholders for the dialect
+func (s *Store) rebind(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *Store) exec(ctx context.Context, q querier, query string, args ...interface{}) (sql.Result, error) {
+	result, err := q.ExecContext(ctx, s.rebind(query), args...)
+	return result, mapError(err)
+}
+
+func (s *Store) query(ctx context.Context, q querier, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := q.QueryContext(ctx, s.rebind(query), args...)
+	return rows, mapError(err)
+}
+
+func (s *Store) queryRow(ctx context.Context, q querier, query string, args ...interface{}) *sql.Row {
+	return q.QueryRowContext(ctx, s.rebind(query), args...)
+}
+
+// withTx run fn in a transaction, committing when it returns nil
+func (s *Store) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return mapError(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return mapError(tx.Commit())
+}
+
+// mapError translate driver errors into store errors
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if duplicate := duplicateKey(err); duplicate != nil {
+		return duplicate
+	}
+	var netError net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.As(err, &netError) {
+		return fmt.Errorf("%w: %v", store.ErrUnavailable, err)
+	}
+	return err
+}
+
+// uniqueConstraints field reported for each unique index
+var uniqueConstraints = map[string]string{
+	"users_email_key":    "email",
+	"users_username_key": "username",
+	"articles_slug_key":  "slug",
+
+	"refresh_tokens_hash_key":  "hash",
+	"password_resets_hash_key": "hash",
+
+	"identities_provider_subject_key": "subject",
+	"access_tokens_hash_key":          "hash",
+}
+
+// duplicateKey DuplicateError when err is a unique constraint violation
+func duplicateKey(err error) error {
+	var sqliteError sqlite3.Error
+	var pqError *pq.Error
+	switch {
+	case errors.As(err, &sqliteError):
+		// UNIQUE constraint failed: users.email
+		if sqliteError.ExtendedCode != sqlite3.ErrConstraintUnique {
+			return nil
+		}
+		message := sqliteError.Error()
+		column := message[strings.LastIndex(message, ".")+1:]
+		for _, field := range uniqueConstraints {
+			if field == column {
+				return &store.DuplicateError{Field: field}
+			}
+		}
+	case errors.As(err, &pqError):
+		if field, ok := uniqueConstraints[pqError.Constraint]; ok && pqError.Code == "23505" {
+			return &store.DuplicateError{Field: field}
+		}
+	}
+	return nil
+}
+
+// affected ErrNotFound when result touched no rows
+func affected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return mapError(err)
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// inClause placeholders and args for ids
+func inClause(ids []primitive.ObjectID) (string, []interface{}) {
+	if len(ids) == 0 {
+		return "(NULL)", nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id.Hex()
+	}
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")", args
+}
+
+// parseID object id from hex column
+func parseID(hex string) (primitive.ObjectID, error) {
+	return primitive.ObjectIDFromHex(strings.TrimSpace(hex))
+}
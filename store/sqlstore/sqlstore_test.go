@@ -0,0 +1,65 @@
+package sqlstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/storetest"
+)
+
+// open migrated sqlite store in a temporary directory, or postgres at
+// POSTGRES_TEST_URL when set
+func open(t *testing.T) *Store {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "conduit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	dialect, dsn := SQLite, filepath.Join(dir, "conduit.db")
+	if url := os.Getenv("POSTGRES_TEST_URL"); url != "" {
+		dialect, dsn = Postgres, url
+	}
+	s, err := Open(ctx, dialect, dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := s.MigrateUp(ctx, false); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if dialect == Postgres {
+			s.MigrateDown(ctx, len(migrations), false)
+		}
+		s.Close(ctx)
+	})
+	return s
+}
+
+func TestConcurrentFavorites(t *testing.T) {
+	storetest.ConcurrentFavorites(t, open(t))
+}
+
+func TestConcurrentLoginFailures(t *testing.T) {
+	storetest.ConcurrentLoginFailures(t, open(t))
+}
+
+func TestSlugReuse(t *testing.T) {
+	storetest.SlugReuse(t, func() store.Store { return open(t) })
+}
+
+func TestDeletedFavorites(t *testing.T) {
+	storetest.DeletedFavorites(t, func() store.Store { return open(t) })
+}
+
+func TestCascade(t *testing.T) {
+	storetest.Cascade(t, open(t))
+}
+
+func TestCommentByID(t *testing.T) {
+	storetest.CommentByID(t, open(t))
+}
@@ -0,0 +1,128 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type refreshTokenStore Store
+
+func (s *refreshTokenStore) Create(ctx context.Context, token *models.RefreshToken) error {
+	db := (*Store)(s)
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := db.exec(ctx, db.db, `INSERT INTO refresh_tokens (id, hash, family, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		token.ID.Hex(), token.Hash, token.Family, token.User.Hex(), token.CreatedAt.UTC(), token.ExpiresAt.UTC())
+	return err
+}
+
+func (s *refreshTokenStore) Find(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	db := (*Store)(s)
+	var token models.RefreshToken
+	var id, user string
+	var usedAt, revokedAt sql.NullTime
+	err := db.queryRow(ctx, db.db, `SELECT id, hash, family, user_id, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens WHERE hash = ?`, hash).
+		Scan(&id, &token.Hash, &token.Family, &user, &token.CreatedAt, &token.ExpiresAt, &usedAt, &revokedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	if token.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	if token.User, err = parseID(user); err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+func (s *refreshTokenStore) Use(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	db := (*Store)(s)
+	result, err := db.exec(ctx, db.db, `UPDATE refresh_tokens SET used_at = ?
+		WHERE hash = ? AND used_at IS NULL AND revoked_at IS NULL`, time.Now().UTC(), hash)
+	if err != nil {
+		return nil, err
+	}
+	used := affected(result)
+	if used != nil && used != store.ErrNotFound {
+		return nil, used
+	}
+	token, err := s.Find(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if used == store.ErrNotFound {
+		return token, store.ErrTokenReused
+	}
+	return token, nil
+}
+
+func (s *refreshTokenStore) RevokeFamily(ctx context.Context, family string) error {
+	db := (*Store)(s)
+	_, err := db.exec(ctx, db.db, `UPDATE refresh_tokens SET revoked_at = ? WHERE family = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), family)
+	return err
+}
+
+func (s *refreshTokenStore) RevokeUser(ctx context.Context, user primitive.ObjectID) error {
+	db := (*Store)(s)
+	_, err := db.exec(ctx, db.db, `UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`,
+		time.Now().UTC(), user.Hex())
+	return err
+}
+
+type revocationStore Store
+
+// Revoke upsert revocation, deleting expired rows on the way
+func (s *revocationStore) Revoke(ctx context.Context, revocation *models.Revocation) error {
+	db := (*Store)(s)
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := db.exec(ctx, tx, `DELETE FROM revocations WHERE expires_at <= ?`, time.Now().UTC()); err != nil {
+			return err
+		}
+		_, err := db.exec(ctx, tx, `INSERT INTO revocations (id, revoked_at, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET revoked_at = excluded.revoked_at, expires_at = excluded.expires_at`,
+			revocation.ID, revocation.RevokedAt.UTC(), revocation.ExpiresAt.UTC())
+		return err
+	})
+}
+
+func (s *revocationStore) Find(ctx context.Context, ids ...string) ([]*models.Revocation, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	db := (*Store)(s)
+	args := []interface{}{time.Now().UTC()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := db.query(ctx, db.db, `SELECT id, revoked_at, expires_at FROM revocations
+		WHERE expires_at > ? AND id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var found []*models.Revocation
+	for rows.Next() {
+		var r models.Revocation
+		if err := rows.Scan(&r.ID, &r.RevokedAt, &r.ExpiresAt); err != nil {
+			return nil, mapError(err)
+		}
+		found = append(found, &r)
+	}
+	return found, mapError(rows.Err())
+}
@@ -0,0 +1,92 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type twoFactorStore Store
+
+func (s *twoFactorStore) Find(ctx context.Context, user primitive.ObjectID) (*models.TwoFactor, error) {
+	db := (*Store)(s)
+	twoFactor := models.TwoFactor{User: user, RecoveryCodes: []string{}}
+	err := db.queryRow(ctx, db.db, `SELECT secret, enabled, last_step, created_at FROM two_factor WHERE user_id = ?`, user.Hex()).
+		Scan(&twoFactor.Secret, &twoFactor.Enabled, &twoFactor.LastStep, &twoFactor.CreatedAt)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	rows, err := db.query(ctx, db.db, `SELECT hash FROM two_factor_recovery_codes WHERE user_id = ?`, user.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, mapError(err)
+		}
+		twoFactor.RecoveryCodes = append(twoFactor.RecoveryCodes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapError(err)
+	}
+	return &twoFactor, nil
+}
+
+func (s *twoFactorStore) Save(ctx context.Context, twoFactor *models.TwoFactor) error {
+	db := (*Store)(s)
+	user := twoFactor.User.Hex()
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		if err := db.queryRow(ctx, tx, `SELECT 1 FROM users WHERE id = ?`, user).Scan(&exists); err != nil {
+			return mapError(err)
+		}
+		_, err := db.exec(ctx, tx, `INSERT INTO two_factor (user_id, secret, enabled, last_step, created_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, enabled = excluded.enabled,
+				last_step = excluded.last_step, created_at = excluded.created_at`,
+			user, twoFactor.Secret, twoFactor.Enabled, twoFactor.LastStep, twoFactor.CreatedAt.UTC())
+		if err != nil {
+			return err
+		}
+		if _, err := db.exec(ctx, tx, `DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, user); err != nil {
+			return err
+		}
+		for _, hash := range twoFactor.RecoveryCodes {
+			if _, err := db.exec(ctx, tx, `INSERT INTO two_factor_recovery_codes (user_id, hash) VALUES (?, ?)`, user, hash); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *twoFactorStore) Delete(ctx context.Context, user primitive.ObjectID) error {
+	db := (*Store)(s)
+	// recovery codes go with the cascade of their foreign key
+	_, err := db.exec(ctx, db.db, `DELETE FROM two_factor WHERE user_id = ?`, user.Hex())
+	return err
+}
+
+func (s *twoFactorStore) UseStep(ctx context.Context, user primitive.ObjectID, step int64) error {
+	db := (*Store)(s)
+	// the condition on last_step makes concurrent uses of one code race for
+	// a single row
+	result, err := db.exec(ctx, db.db, `UPDATE two_factor SET last_step = ? WHERE user_id = ? AND last_step < ?`,
+		step, user.Hex(), step)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
+
+func (s *twoFactorStore) UseRecoveryCode(ctx context.Context, user primitive.ObjectID, hash string) error {
+	db := (*Store)(s)
+	result, err := db.exec(ctx, db.db, `DELETE FROM two_factor_recovery_codes WHERE user_id = ? AND hash = ?`, user.Hex(), hash)
+	if err != nil {
+		return err
+	}
+	return affected(result)
+}
@@ -0,0 +1,246 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type userStore Store
+
+const userColumns = `id, email, username, password, bio, image, email_verified, role`
+
+// scanUser scan userColumns
+func scanUser(row interface{ Scan(...interface{}) error }) (*models.User, error) {
+	var user models.User
+	var id string
+	if err := row.Scan(&id, &user.Email, &user.Username, &user.Password, &user.Bio, &user.Image, &user.EmailVerified, &user.Role); err != nil {
+		return nil, mapError(err)
+	}
+	var err error
+	if user.ID, err = parseID(id); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// relatedIDs ids in column of table where key column matches id
+func (s *userStore) relatedIDs(ctx context.Context, q querier, query string, id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	rows, err := (*Store)(s).query(ctx, q, query, id.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []primitive.ObjectID
+	for rows.Next() {
+		var hex string
+		if err := rows.Scan(&hex); err != nil {
+			return nil, mapError(err)
+		}
+		related, err := parseID(hex)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, related)
+	}
+	return ids, mapError(rows.Err())
+}
+
+func (s *userStore) findOne(ctx context.Context, where string, arg interface{}) (*models.User, error) {
+	db := (*Store)(s)
+	user, err := scanUser(db.queryRow(ctx, db.db, `SELECT `+userColumns+` FROM users WHERE `+where, arg))
+	if err != nil {
+		return nil, err
+	}
+	if user.Following, err = s.relatedIDs(ctx, db.db, `SELECT followee_id FROM follows WHERE follower_id = ?`, user.ID); err != nil {
+		return nil, err
+	}
+	if user.Favorites, err = s.relatedIDs(ctx, db.db, `SELECT article_id FROM favorites WHERE user_id = ?`, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userStore) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return s.findOne(ctx, `id = ?`, id.Hex())
+}
+
+func (s *userStore) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.findOne(ctx, `email = ?`, email)
+}
+
+func (s *userStore) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.findOne(ctx, `username = ?`, username)
+}
+
+// likeEscaper escape LIKE wildcards with backslashes
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *userStore) List(ctx context.Context, filter store.UserFilter) ([]*models.User, int64, error) {
+	db := (*Store)(s)
+	var conditions []string
+	var args []interface{}
+	if filter.Query != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(filter.Query)) + "%"
+		conditions = append(conditions, `(LOWER(username) LIKE ? ESCAPE '\' OR LOWER(email) LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, `role = ?`)
+		args = append(args, filter.Role)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = ` WHERE ` + strings.Join(conditions, ` AND `)
+	}
+
+	var counts int64
+	if err := db.queryRow(ctx, db.db, `SELECT COUNT(*) FROM users`+where, args...).Scan(&counts); err != nil {
+		return nil, 0, mapError(err)
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where + ` ORDER BY username`
+	switch {
+	case filter.Limit > 0:
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	case filter.Offset > 0 && db.dialect == SQLite:
+		query += ` LIMIT -1 OFFSET ?`
+		args = append(args, filter.Offset)
+	case filter.Offset > 0:
+		query += ` OFFSET ?`
+		args = append(args, filter.Offset)
+	}
+	rows, err := db.query(ctx, db.db, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	users := []*models.User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, counts, mapError(rows.Err())
+}
+
+func (s *userStore) Create(ctx context.Context, user *models.User) error {
+	db := (*Store)(s)
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	return db.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := db.exec(ctx, tx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			user.ID.Hex(), user.Email, user.Username, user.Password, user.Bio, user.Image, user.EmailVerified, user.Role)
+		if err != nil {
+			return err
+		}
+		if err := s.replaceIDs(ctx, tx, `follows`, `follower_id`, `followee_id`, user.ID, user.Following); err != nil {
+			return err
+		}
+		return s.replaceIDs(ctx, tx, `favorites`, `user_id`, `article_id`, user.ID, user.Favorites)
+	})
+}
+
+func (s *userStore) Update(ctx context.Context, id primitive.ObjectID, update store.UserUpdate) (*models.User, error) {
+	var sets []string
+	var args []interface{}
+	if update.Email != nil {
+		sets = append(sets, `email = ?`)
+		args = append(args, *update.Email)
+	}
+	if update.Username != nil {
+		sets = append(sets, `username = ?`)
+		args = append(args, *update.Username)
+	}
+	if update.Password != nil {
+		sets = append(sets, `password = ?`)
+		args = append(args, *update.Password)
+	}
+	if update.Bio != nil {
+		sets = append(sets, `bio = ?`)
+		args = append(args, *update.Bio)
+	}
+	if update.Image != nil {
+		sets = append(sets, `image = ?`)
+		args = append(args, *update.Image)
+	}
+	if update.EmailVerified != nil {
+		sets = append(sets, `email_verified = ?`)
+		args = append(args, *update.EmailVerified)
+	}
+	if update.Role != nil {
+		sets = append(sets, `role = ?`)
+		args = append(args, *update.Role)
+	}
+	if len(sets) > 0 {
+		db := (*Store)(s)
+		result, err := db.exec(ctx, db.db, `UPDATE users SET `+strings.Join(sets, ", ")+` WHERE id = ?`, append(args, id.Hex())...)
+		if err != nil {
+			return nil, err
+		}
+		if err := affected(result); err != nil {
+			return nil, err
+		}
+	}
+	return s.FindByID(ctx, id)
+}
+
+// replaceIDs replace rows of relation table owned by id with ids
+func (s *userStore) replaceIDs(ctx context.Context, tx *sql.Tx, table string, ownerColumn string, idColumn string, id primitive.ObjectID, ids []primitive.ObjectID) error {
+	db := (*Store)(s)
+	if _, err := db.exec(ctx, tx, `DELETE FROM `+table+` WHERE `+ownerColumn+` = ?`, id.Hex()); err != nil {
+		return err
+	}
+	seen := make(map[primitive.ObjectID]bool)
+	for _, related := range ids {
+		if seen[related] {
+			continue
+		}
+		seen[related] = true
+		_, err := db.exec(ctx, tx, `INSERT INTO `+table+` (`+ownerColumn+`, `+idColumn+`) VALUES (?, ?)`, id.Hex(), related.Hex())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// follow insert or delete the follows row of id and followee
+func (s *userStore) follow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID, follow bool) (*models.User, error) {
+	db := (*Store)(s)
+	err := db.withTx(ctx, func(tx *sql.Tx) error {
+		var exists int
+		if err := db.queryRow(ctx, tx, `SELECT 1 FROM users WHERE id = ?`, id.Hex()).Scan(&exists); err != nil {
+			return mapError(err)
+		}
+		query := `INSERT INTO follows (follower_id, followee_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
+		if !follow {
+			query = `DELETE FROM follows WHERE follower_id = ? AND followee_id = ?`
+		}
+		_, err := db.exec(ctx, tx, query, id.Hex(), followee.Hex())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.FindByID(ctx, id)
+}
+
+func (s *userStore) Follow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error) {
+	return s.follow(ctx, id, followee, true)
+}
+
+func (s *userStore) Unfollow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error) {
+	return s.follow(ctx, id, followee, false)
+}
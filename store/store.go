@@ -0,0 +1,366 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrNotFound no record matched the query
+var ErrNotFound = errors.New("error: not found")
+
+// ErrUnavailable backend could not be reached, the request may be retried
+var ErrUnavailable = errors.New("error: store unavailable")
+
+// ErrTokenReused refresh token was presented after it had been used or
+// revoked
+var ErrTokenReused = errors.New("error: refresh token reused")
+
+// DuplicateError a unique field clashed with an existing record
+type DuplicateError struct {
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return e.Field + " has already been taken"
+}
+
+// IndexReport outcome of ensuring indexes
+type IndexReport struct {
+	// Created indexes that were missing and have been built
+	Created []string
+	// Drift indexes that differ from the expected definition, are
+	// unexpected or could not be built
+	Drift []string
+}
+
+// Indexer implemented by stores that manage indexes at runtime
+type Indexer interface {
+	EnsureIndexes(ctx context.Context) (*IndexReport, error)
+}
+
+// MigrationStatus state of a versioned migration
+type MigrationStatus struct {
+	Version int
+	Name    string
+	// AppliedAt zero while the migration is pending
+	AppliedAt time.Time
+}
+
+// MigrationStep migration applied or, on a dry run, planned
+type MigrationStep struct {
+	Version int
+	Name    string
+	// Changes summary of what the step changes
+	Changes []string
+}
+
+// Migrator implemented by stores with versioned migrations
+type Migrator interface {
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+	// MigrateUp apply pending migrations in order
+	MigrateUp(ctx context.Context, dryRun bool) ([]MigrationStep, error)
+	// MigrateDown revert the last steps applied migrations
+	MigrateDown(ctx context.Context, steps int, dryRun bool) ([]MigrationStep, error)
+}
+
+// Wiper implemented by stores that can delete all of their data
+type Wiper interface {
+	// Wipe delete every user, article and comment, keeping schema and indexes
+	Wipe(ctx context.Context) error
+}
+
+// Exporter implemented by stores that can stream all of their data. fn
+// must not call back into the store.
+type Exporter interface {
+	// EachUser call fn for every user ordered by id, stopping at the
+	// first error
+	EachUser(ctx context.Context, fn func(user *models.User) error) error
+	EachArticle(ctx context.Context, fn func(article *models.Article) error) error
+	EachComment(ctx context.Context, fn func(comment *models.Comment) error) error
+}
+
+// Importer implemented by stores that can load a dump
+type Importer interface {
+	// ImportFavorite add article to the favorites of user and increment
+	// its favorites count as one atomic change, like Favorite but for soft
+	// deleted articles too. Importing a favorite twice is a no-op.
+	ImportFavorite(ctx context.Context, user primitive.ObjectID, article primitive.ObjectID) error
+}
+
+// CommentPolicy what becomes of the comments of a removed article or user
+type CommentPolicy int
+
+const (
+	// DeleteComments delete the comments for good
+	DeleteComments CommentPolicy = iota
+	// ArchiveComments move the comments to the comment archive, out of
+	// every listing
+	ArchiveComments
+)
+
+// CascadeReport records changed by one cascade
+type CascadeReport struct {
+	Users    int64 `json:"users"`
+	Articles int64 `json:"articles"`
+	// Comments deleted comments
+	Comments int64 `json:"comments"`
+	// ArchivedComments comments moved to the archive
+	ArchivedComments int64 `json:"archivedComments"`
+	// Favorites favorites references removed, from users and from the
+	// counts of surviving articles
+	Favorites int64 `json:"favorites"`
+	// Follows entries removed from the following of other users
+	Follows int64 `json:"follows"`
+}
+
+// Store storage backend
+type Store interface {
+	Users() UserStore
+	Articles() ArticleStore
+	Comments() CommentStore
+	RefreshTokens() RefreshTokenStore
+	Revocations() RevocationStore
+	PasswordResets() PasswordResetStore
+	TwoFactors() TwoFactorStore
+	Identities() IdentityStore
+	AccessTokens() AccessTokenStore
+	Audit() AuditStore
+	LoginAttempts() LoginAttemptStore
+	// Close release connections held by the store
+	Close(ctx context.Context) error
+}
+
+// UserUpdate fields to update on a user, nil fields are left untouched
+type UserUpdate struct {
+	Email    *string
+	Username *string
+	Password *string
+	Bio      *string
+	Image    *string
+	// EmailVerified also set it to false when changing Email
+	EmailVerified *bool
+	Role          *models.Role
+}
+
+// UserFilter filter for listing users
+type UserFilter struct {
+	// Query case insensitive substring of username or email, empty
+	// matches any user
+	Query string
+	// Role empty matches any role
+	Role   models.Role
+	Limit  int
+	Offset int
+}
+
+// UserStore user repository
+type UserStore interface {
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	// List users matching filter by username, with the total count.
+	// Following and Favorites are left empty.
+	List(ctx context.Context, filter UserFilter) ([]*models.User, int64, error)
+	// Create insert user and fill in its id, a clashing email or username
+	// returns *DuplicateError. An empty role becomes models.RoleUser.
+	Create(ctx context.Context, user *models.User) error
+	// Update apply update and return the updated user
+	Update(ctx context.Context, id primitive.ObjectID, update UserUpdate) (*models.User, error)
+	// Follow add followee to the following of user id and return the
+	// updated user, following twice is a no-op
+	Follow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error)
+	// Unfollow remove followee from the following of user id and return the
+	// updated user
+	Unfollow(ctx context.Context, id primitive.ObjectID, followee primitive.ObjectID) (*models.User, error)
+	// Remove delete user id with all of their articles, their comments
+	// handled by policy, their favorites and their entries in the following
+	// of others, as one unit of work
+	Remove(ctx context.Context, id primitive.ObjectID, comments CommentPolicy) (*CascadeReport, error)
+}
+
+// ArticleFilter filter for listing articles
+type ArticleFilter struct {
+	Tag string
+	// Authors nil matches any author, empty matches none
+	Authors []primitive.ObjectID
+	// IDs nil matches any article, empty matches none
+	IDs    []primitive.ObjectID
+	Limit  int
+	Offset int
+}
+
+// ArticleUpdate fields to update on an article, nil fields are left untouched
+type ArticleUpdate struct {
+	Title       *string
+	Description *string
+	Body        *string
+	TagList     []string
+}
+
+// ArticleStore article repository
+type ArticleStore interface {
+	// List articles matching filter newest first, with the total count.
+	// Soft deleted articles are hidden here and from every lookup below.
+	List(ctx context.Context, filter ArticleFilter) ([]models.ArticleWithAuthor, int64, error)
+	FindBySlug(ctx context.Context, slug string) (*models.Article, error)
+	FindBySlugWithAuthor(ctx context.Context, slug string) (*models.ArticleWithAuthor, error)
+	// Create insert article and fill in its id and timestamps, a clashing
+	// slug returns *DuplicateError
+	Create(ctx context.Context, article *models.Article) error
+	// Update update article owned by author and return it
+	Update(ctx context.Context, slug string, author primitive.ObjectID, update ArticleUpdate) (*models.Article, error)
+	// Delete soft delete article owned by author, hiding it from every
+	// lookup until it is restored or purged
+	Delete(ctx context.Context, slug string, author primitive.ObjectID) error
+	// Restore undo Delete of article owned by author when it was deleted
+	// after deletedAfter
+	Restore(ctx context.Context, slug string, author primitive.ObjectID, deletedAfter time.Time) (*models.Article, error)
+	// Remove hard delete article id, live or soft deleted, with its comments
+	// handled by policy and its favorites references, as one unit of work
+	Remove(ctx context.Context, id primitive.ObjectID, comments CommentPolicy) (*CascadeReport, error)
+	// Purge Remove every article soft deleted before deletedBefore as one
+	// unit of work
+	Purge(ctx context.Context, deletedBefore time.Time, comments CommentPolicy) (*CascadeReport, error)
+	// Favorite add article id to the favorites of user and increment its
+	// favorites count as one atomic change, favoriting twice is a no-op
+	Favorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error)
+	// Unfavorite remove article id from the favorites of user and decrement
+	// its favorites count as one atomic change
+	Unfavorite(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error)
+	// Tags distinct tags over all articles
+	Tags(ctx context.Context) ([]string, error)
+}
+
+// CommentStore comment repository
+type CommentStore interface {
+	// Create insert comment and fill in its id and timestamps
+	Create(ctx context.Context, comment *models.Comment) error
+	// FindByID comment of id, ErrNotFound when there is none
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Comment, error)
+	// Delete delete comment owned by author
+	Delete(ctx context.Context, id primitive.ObjectID, author primitive.ObjectID) error
+	// ListByArticle comments of article oldest first
+	ListByArticle(ctx context.Context, article primitive.ObjectID) ([]models.CommentWithAuthor, error)
+}
+
+// RefreshTokenStore server side refresh tokens, looked up by hash
+type RefreshTokenStore interface {
+	// Create insert token and fill in its id
+	Create(ctx context.Context, token *models.RefreshToken) error
+	// Find token of hash without using it, used and revoked ones too,
+	// ErrNotFound when unknown
+	Find(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// Use mark the token of hash used and return it, once. A token already
+	// used or revoked returns itself with ErrTokenReused so the caller can
+	// revoke its family.
+	Use(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// RevokeFamily revoke every token of family
+	RevokeFamily(ctx context.Context, family string) error
+	// RevokeUser revoke every token of user
+	RevokeUser(ctx context.Context, user primitive.ObjectID) error
+}
+
+// RevocationStore revoked access tokens. Revocations past their ExpiresAt
+// are dropped automatically.
+type RevocationStore interface {
+	// Revoke record revocation, replacing an earlier one with the same id
+	Revoke(ctx context.Context, revocation *models.Revocation) error
+	// Find unexpired revocations among ids
+	Find(ctx context.Context, ids ...string) ([]*models.Revocation, error)
+}
+
+// PasswordResetRetention how long password resets are kept after their
+// creation, used or not, bounding the rate limit window over them
+const PasswordResetRetention = 24 * time.Hour
+
+// PasswordResetStore password reset tokens, looked up by hash
+type PasswordResetStore interface {
+	// Create insert reset and fill in its id, dropping resets past
+	// PasswordResetRetention
+	Create(ctx context.Context, reset *models.PasswordReset) error
+	// CountSince number of resets created for email since
+	CountSince(ctx context.Context, email string, since time.Time) (int64, error)
+	// Find reset of hash without using it, ErrNotFound when unknown or
+	// already used
+	Find(ctx context.Context, hash string) (*models.PasswordReset, error)
+	// Use mark the reset of hash used together with every other open reset
+	// of its user and return it, ErrNotFound when unknown or already used
+	Use(ctx context.Context, hash string) (*models.PasswordReset, error)
+}
+
+// TwoFactorStore TOTP second factors, one per user
+type TwoFactorStore interface {
+	// Find second factor of user, ErrNotFound when they never enrolled
+	Find(ctx context.Context, user primitive.ObjectID) (*models.TwoFactor, error)
+	// Save insert twoFactor or replace the one of its user
+	Save(ctx context.Context, twoFactor *models.TwoFactor) error
+	// Delete remove the second factor of user
+	Delete(ctx context.Context, user primitive.ObjectID) error
+	// UseStep record step as the last accepted, ErrNotFound unless it is
+	// later than the current LastStep
+	UseStep(ctx context.Context, user primitive.ObjectID, step int64) error
+	// UseRecoveryCode remove hash from the recovery codes of user,
+	// ErrNotFound when it is not one of them
+	UseRecoveryCode(ctx context.Context, user primitive.ObjectID, hash string) error
+}
+
+// IdentityStore accounts of users at OpenID Connect providers
+type IdentityStore interface {
+	// Find identity of subject at provider, ErrNotFound when not linked
+	Find(ctx context.Context, provider string, subject string) (*models.Identity, error)
+	// Create link identity, setting its ID. Returns *DuplicateError on
+	// subject when the subject is linked already.
+	Create(ctx context.Context, identity *models.Identity) error
+}
+
+// AccessTokenStore personal access tokens
+type AccessTokenStore interface {
+	// Create insert token, setting its ID. Returns *DuplicateError on hash.
+	Create(ctx context.Context, token *models.AccessToken) error
+	// FindByHash token of hash, ErrNotFound when unknown
+	FindByHash(ctx context.Context, hash string) (*models.AccessToken, error)
+	// List tokens of user, newest first
+	List(ctx context.Context, user primitive.ObjectID) ([]*models.AccessToken, error)
+	// Delete token id of user, ErrNotFound when user has no such token
+	Delete(ctx context.Context, user primitive.ObjectID, id primitive.ObjectID) error
+	// DeleteUser delete every token of user
+	DeleteUser(ctx context.Context, user primitive.ObjectID) error
+	// Touch set LastUsedAt of token id
+	Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+// AuditFilter filter for listing the audit log
+type AuditFilter struct {
+	// Actor zero matches any actor
+	Actor primitive.ObjectID
+	// Action empty matches any action
+	Action string
+	Limit  int
+	Offset int
+}
+
+// AuditStore append only log of privileged actions
+type AuditStore interface {
+	// Create insert entry and fill in its id
+	Create(ctx context.Context, entry *models.AuditEntry) error
+	// List entries matching filter newest first, with the total count
+	List(ctx context.Context, filter AuditFilter) ([]*models.AuditEntry, int64, error)
+}
+
+// LoginAttemptStore failed login counters by key. Counters past their
+// ExpiresAt are dropped automatically.
+type LoginAttemptStore interface {
+	// Find unexpired attempts of key, ErrNotFound when there are none
+	Find(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// Fail count a failed login of key at at, starting over when the
+	// earlier failures expired, and keep the count until at plus ttl.
+	// Returns the updated attempts. Concurrent calls each see their own
+	// count, so callers may count an attempt before knowing it failed.
+	Fail(ctx context.Context, key string, at time.Time, ttl time.Duration) (*models.LoginAttempt, error)
+	// Reset forget the failures of keys
+	Reset(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,442 @@
+// Package storetest behaviour every store.Store backend must share, run from
+// the test files of each backend
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jameslahm/conduit-server-gin/models"
+	"github.com/jameslahm/conduit-server-gin/store"
+	"github.com/jameslahm/conduit-server-gin/store/dump"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConcurrentFavorites favorite one article from many users at once, each
+// of them twice, and expect an exact favorites count, then unfavorite
+// concurrently back to zero
+func ConcurrentFavorites(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	const users = 50
+
+	author := &models.User{Email: "author@example.com", Username: "author"}
+	if err := s.Users().Create(ctx, author); err != nil {
+		t.Fatalf("create author: %v", err)
+	}
+	article := &models.Article{}
+	article.Slug = "hammered"
+	article.Title = "Hammered"
+	article.Author = author.ID
+	if err := s.Articles().Create(ctx, article); err != nil {
+		t.Fatalf("create article: %v", err)
+	}
+	ids := make([]primitive.ObjectID, users)
+	for i := range ids {
+		user := &models.User{Email: fmt.Sprintf("user%d@example.com", i), Username: fmt.Sprintf("user%d", i)}
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		ids[i] = user.ID
+	}
+
+	hammer := func(fn func(ctx context.Context, id primitive.ObjectID, user primitive.ObjectID) (*models.Article, error)) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 2*users)
+		for _, id := range append(ids, ids...) {
+			wg.Add(1)
+			go func(user primitive.ObjectID) {
+				defer wg.Done()
+				if _, err := fn(ctx, article.ID, user); err != nil {
+					errs <- err
+				}
+			}(id)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatal(err)
+		}
+	}
+	count := func() int {
+		found, err := s.Articles().FindBySlug(ctx, article.Slug)
+		if err != nil {
+			t.Fatalf("find article: %v", err)
+		}
+		return found.FavoritesCount
+	}
+
+	hammer(s.Articles().Favorite)
+	if got := count(); got != users {
+		t.Fatalf("favoritesCount after favorite = %d, want %d", got, users)
+	}
+	for _, id := range ids {
+		user, err := s.Users().FindByID(ctx, id)
+		if err != nil {
+			t.Fatalf("find user: %v", err)
+		}
+		if len(user.Favorites) != 1 || user.Favorites[0] != article.ID {
+			t.Fatalf("favorites of %s = %v, want [%s]", user.Username, user.Favorites, article.ID.Hex())
+		}
+	}
+
+	hammer(s.Articles().Unfavorite)
+	if got := count(); got != 0 {
+		t.Fatalf("favoritesCount after unfavorite = %d, want 0", got)
+	}
+}
+
+// ConcurrentLoginFailures count failed logins of one key from many
+// goroutines at once and expect none lost, then expect an expired count to
+// restart and a reset to clear it
+func ConcurrentLoginFailures(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	const failures = 50
+	key := models.IPAttemptKey("192.0.2.1")
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, failures)
+	for i := 0; i < failures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.LoginAttempts().Fail(ctx, key, now, time.Hour); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	attempt, err := s.LoginAttempts().Find(ctx, key)
+	if err != nil {
+		t.Fatalf("find attempts: %v", err)
+	}
+	if attempt.Failures != failures {
+		t.Fatalf("failures = %d, want %d", attempt.Failures, failures)
+	}
+
+	later := now.Add(2 * time.Hour)
+	attempt, err = s.LoginAttempts().Fail(ctx, key, later, time.Hour)
+	if err != nil {
+		t.Fatalf("fail after expiry: %v", err)
+	}
+	if attempt.Failures != 1 || attempt.PreviousFailure != nil {
+		t.Fatalf("after expiry failures = %d, previous %v, want 1 and none", attempt.Failures, attempt.PreviousFailure)
+	}
+	attempt, err = s.LoginAttempts().Fail(ctx, key, later.Add(time.Minute), time.Hour)
+	if err != nil {
+		t.Fatalf("fail again: %v", err)
+	}
+	// stores may keep times to the millisecond only
+	if attempt.PreviousFailure == nil || attempt.PreviousFailure.Sub(later) > time.Millisecond || later.Sub(*attempt.PreviousFailure) > time.Millisecond {
+		t.Fatalf("previous failure %v, want %v", attempt.PreviousFailure, later)
+	}
+
+	if err := s.LoginAttempts().Reset(ctx, key, models.IPAttemptKey("192.0.2.2")); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if _, err := s.LoginAttempts().Find(ctx, key); err != store.ErrNotFound {
+		t.Fatalf("find after reset = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+// SlugReuse delete an article and expect its slug free for a new one, then
+// expect restoring it to clash with the new article, and restoring after
+// deleting the new one to bring back the latest deletion. A dump of the
+// deleted and the live article of one slug must import into a second
+// store from open.
+func SlugReuse(t *testing.T, open func() store.Store) {
+	ctx := context.Background()
+	s := open()
+	author := &models.User{Email: "author@example.com", Username: "author"}
+	if err := s.Users().Create(ctx, author); err != nil {
+		t.Fatalf("create author: %v", err)
+	}
+	create := func(title string) *models.Article {
+		article := &models.Article{}
+		article.Slug = "reused"
+		article.Title = title
+		article.Author = author.ID
+		if err := s.Articles().Create(ctx, article); err != nil {
+			t.Fatalf("create %s: %v", title, err)
+		}
+		return article
+	}
+	del := func(article *models.Article) {
+		if err := s.Articles().Delete(ctx, article.Slug, author.ID); err != nil {
+			t.Fatalf("delete %s: %v", article.Title, err)
+		}
+		// stores may keep deletion times to the millisecond only
+		time.Sleep(2 * time.Millisecond)
+	}
+	var duplicate *store.DuplicateError
+	since := time.Now().Add(-time.Hour)
+
+	first := create("first")
+	del(first)
+	second := create("second")
+	if _, err := s.Articles().Restore(ctx, "reused", author.ID, since); !errors.As(err, &duplicate) || duplicate.Field != "slug" {
+		t.Fatalf("restore over a live article = %v, want duplicate slug", err)
+	}
+	del(second)
+	restored, err := s.Articles().Restore(ctx, "reused", author.ID, since)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.ID != second.ID {
+		t.Fatalf("restored %s, want the latest deletion %s", restored.Title, second.Title)
+	}
+	third := &models.Article{}
+	third.Slug = "reused"
+	third.Author = author.ID
+	if err := s.Articles().Create(ctx, third); !errors.As(err, &duplicate) || duplicate.Field != "slug" {
+		t.Fatalf("create over a live article = %v, want duplicate slug", err)
+	}
+
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if _, err := dump.Export(ctx, s.(store.Exporter), dir); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	imported := open()
+	if _, err := dump.Import(ctx, imported, dir); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	live, err := imported.Articles().FindBySlug(ctx, "reused")
+	if err != nil {
+		t.Fatalf("find imported: %v", err)
+	}
+	if live.ID != second.ID {
+		t.Fatalf("imported live article %s, want %s", live.Title, second.Title)
+	}
+}
+
+// DeletedFavorites dump a soft deleted article with a favorite and import
+// it into a second store from open, then expect restoring it to bring the
+// favorite and its count back
+func DeletedFavorites(t *testing.T, open func() store.Store) {
+	ctx := context.Background()
+	s := open()
+	author := &models.User{Email: "author@example.com", Username: "author"}
+	fan := &models.User{Email: "fan@example.com", Username: "fan"}
+	for _, user := range []*models.User{author, fan} {
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("create %s: %v", user.Username, err)
+		}
+	}
+	article := &models.Article{}
+	article.Slug = "favorited"
+	article.Author = author.ID
+	if err := s.Articles().Create(ctx, article); err != nil {
+		t.Fatalf("create article: %v", err)
+	}
+	if _, err := s.Articles().Favorite(ctx, article.ID, fan.ID); err != nil {
+		t.Fatalf("favorite: %v", err)
+	}
+	since := time.Now().Add(-time.Hour)
+	if err := s.Articles().Delete(ctx, article.Slug, author.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if _, err := dump.Export(ctx, s.(store.Exporter), dir); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	imported := open()
+	if _, err := dump.Import(ctx, imported, dir); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	restored, err := imported.Articles().Restore(ctx, article.Slug, author.ID, since)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.FavoritesCount != 1 {
+		t.Fatalf("favoritesCount after restoring = %d, want 1", restored.FavoritesCount)
+	}
+	found, err := imported.Users().FindByID(ctx, fan.ID)
+	if err != nil {
+		t.Fatalf("find fan: %v", err)
+	}
+	if len(found.Favorites) != 1 || found.Favorites[0] != article.ID {
+		t.Fatalf("favorites of fan = %v, want [%s]", found.Favorites, article.ID.Hex())
+	}
+}
+
+// Cascade remove a user with comments archived and expect their articles,
+// the comments on them and by them, their favorites and their place in the
+// following of others gone, then remove an article with comments deleted.
+// Both reports must count exactly what changed.
+func Cascade(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	users := make(map[string]*models.User)
+	for _, name := range []string{"ada", "bob", "cy"} {
+		user := &models.User{Email: name + "@example.com", Username: name}
+		if err := s.Users().Create(ctx, user); err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		users[name] = user
+	}
+	ada, bob, cy := users["ada"], users["bob"], users["cy"]
+	articles := make(map[string]*models.Article)
+	for slug, author := range map[string]*models.User{"ada-1": ada, "ada-2": ada, "bob-1": bob} {
+		article := &models.Article{}
+		article.Slug = slug
+		article.Author = author.ID
+		if err := s.Articles().Create(ctx, article); err != nil {
+			t.Fatalf("create %s: %v", slug, err)
+		}
+		articles[slug] = article
+	}
+	comment := func(slug string, author *models.User) *models.Comment {
+		comment := &models.Comment{Author: author.ID}
+		comment.Article = articles[slug].ID
+		comment.Body = author.Username + " on " + slug
+		if err := s.Comments().Create(ctx, comment); err != nil {
+			t.Fatalf("comment: %v", err)
+		}
+		return comment
+	}
+	onAda := comment("ada-1", bob)
+	comment("ada-2", cy)
+	byAda := comment("bob-1", ada)
+	kept := comment("bob-1", cy)
+	for _, favorite := range []struct {
+		user *models.User
+		slug string
+	}{{bob, "ada-1"}, {cy, "ada-1"}, {cy, "ada-2"}, {ada, "bob-1"}, {cy, "bob-1"}} {
+		if _, err := s.Articles().Favorite(ctx, articles[favorite.slug].ID, favorite.user.ID); err != nil {
+			t.Fatalf("favorite: %v", err)
+		}
+	}
+	for _, follow := range [][2]*models.User{{bob, ada}, {cy, ada}, {ada, bob}} {
+		if _, err := s.Users().Follow(ctx, follow[0].ID, follow[1].ID); err != nil {
+			t.Fatalf("follow: %v", err)
+		}
+	}
+
+	report, err := s.Users().Remove(ctx, ada.ID, store.ArchiveComments)
+	if err != nil {
+		t.Fatalf("remove ada: %v", err)
+	}
+	// favorites: two of ada-1, one of ada-2 and ada's own of bob-1
+	want := store.CascadeReport{Users: 1, Articles: 2, ArchivedComments: 3, Favorites: 4, Follows: 2}
+	if *report != want {
+		t.Fatalf("report of removing ada = %+v, want %+v", *report, want)
+	}
+	if _, err := s.Users().FindByID(ctx, ada.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("find ada = %v, want ErrNotFound", err)
+	}
+	for _, slug := range []string{"ada-1", "ada-2"} {
+		if _, err := s.Articles().FindBySlug(ctx, slug); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("find %s = %v, want ErrNotFound", slug, err)
+		}
+	}
+	for _, archived := range []*models.Comment{onAda, byAda} {
+		if _, err := s.Comments().FindByID(ctx, archived.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("find archived comment %q = %v, want ErrNotFound", archived.Body, err)
+		}
+	}
+	listed, err := s.Comments().ListByArticle(ctx, articles["bob-1"].ID)
+	if err != nil {
+		t.Fatalf("list comments: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != kept.ID {
+		t.Fatalf("comments of bob-1 = %+v, want only %q", listed, kept.Body)
+	}
+	survivor, err := s.Articles().FindBySlug(ctx, "bob-1")
+	if err != nil {
+		t.Fatalf("find bob-1: %v", err)
+	}
+	if survivor.FavoritesCount != 1 {
+		t.Fatalf("favoritesCount of bob-1 = %d, want 1", survivor.FavoritesCount)
+	}
+	for name, want := range map[string][]primitive.ObjectID{"bob": nil, "cy": {articles["bob-1"].ID}} {
+		user, err := s.Users().FindByID(ctx, users[name].ID)
+		if err != nil {
+			t.Fatalf("find %s: %v", name, err)
+		}
+		if len(user.Following) != 0 {
+			t.Fatalf("%s still follows %v", name, user.Following)
+		}
+		if len(user.Favorites) != len(want) || len(want) == 1 && user.Favorites[0] != want[0] {
+			t.Fatalf("favorites of %s = %v, want %v", name, user.Favorites, want)
+		}
+	}
+
+	report, err = s.Articles().Remove(ctx, articles["bob-1"].ID, store.DeleteComments)
+	if err != nil {
+		t.Fatalf("remove bob-1: %v", err)
+	}
+	want = store.CascadeReport{Articles: 1, Comments: 1, Favorites: 1}
+	if *report != want {
+		t.Fatalf("report of removing bob-1 = %+v, want %+v", *report, want)
+	}
+	if _, err := s.Comments().FindByID(ctx, kept.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("find deleted comment = %v, want ErrNotFound", err)
+	}
+	found, err := s.Users().FindByID(ctx, cy.ID)
+	if err != nil {
+		t.Fatalf("find cy: %v", err)
+	}
+	if len(found.Favorites) != 0 {
+		t.Fatalf("favorites of cy = %v, want none", found.Favorites)
+	}
+	if _, err := s.Articles().Remove(ctx, articles["bob-1"].ID, store.DeleteComments); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("remove again = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Users().Remove(ctx, ada.ID, store.DeleteComments); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("remove ada again = %v, want ErrNotFound", err)
+	}
+}
+
+// CommentByID find a comment by id with its article and author, and expect
+// ErrNotFound once it was deleted
+func CommentByID(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	author := &models.User{Email: "author@example.com", Username: "author"}
+	if err := s.Users().Create(ctx, author); err != nil {
+		t.Fatalf("create author: %v", err)
+	}
+	article := &models.Article{}
+	article.Slug = "commented"
+	article.Author = author.ID
+	if err := s.Articles().Create(ctx, article); err != nil {
+		t.Fatalf("create article: %v", err)
+	}
+	comment := &models.Comment{Author: author.ID}
+	comment.Article = article.ID
+	comment.Body = "first"
+	if err := s.Comments().Create(ctx, comment); err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+
+	found, err := s.Comments().FindByID(ctx, comment.ID)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if found.ID != comment.ID || found.Article != article.ID || found.Author != author.ID || found.Body != "first" {
+		t.Fatalf("found %+v, want %+v", found, comment)
+	}
+	if err := s.Comments().Delete(ctx, comment.ID, author.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.Comments().FindByID(ctx, comment.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("find deleted = %v, want ErrNotFound", err)
+	}
+}
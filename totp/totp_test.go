@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret base32 of the SHA1 seed "12345678901234567890" of RFC 6238
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238(t *testing.T) {
+	// appendix B, SHA1, the last 6 of its 8 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		got, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("code at %d: %s, want %s", tt.unix, got, tt.want)
+		}
+		// secrets are read regardless of case
+		if lower, _ := Code(strings.ToLower(rfcSecret), step); lower != tt.want {
+			t.Errorf("code of the lower case secret at %d: %s", tt.unix, lower)
+		}
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("code of a malformed secret")
+	}
+}
+
+func TestValidateWindow(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, err := Code(rfcSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := Validate(rfcSecret, code, now)
+		if want := offset >= -Skew && offset <= Skew; ok != want {
+			t.Errorf("code of step %+d: ok %v, want %v", offset, ok, want)
+		}
+		if ok && got != step+offset {
+			t.Errorf("code of step %+d: step %d, want %d", offset, got, step+offset)
+		}
+	}
+
+	// authenticator apps show codes grouped, "005 924"
+	if _, ok := Validate(rfcSecret, "005 924", now); !ok {
+		t.Error("code with a space rejected")
+	}
+	for _, code := range []string{"", "5924", "0059240", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := encoding.DecodeString(secret); err != nil || len(key) != secretSize {
+		t.Fatalf("secret %s: %d bytes, %v", secret, len(key), err)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Fatal("same secret twice")
+	}
+}